@@ -0,0 +1,177 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// autoCherryPick is the policy for automatic cherry-picks of merged PRs. A PR is cherry-picked if it
+// carries all of the labels, has a release note of one of the categories or belongs to one of the
+// milestones.
+type autoCherryPick struct {
+	// Labels which a PR must all carry to be cherry-picked, e.g. kind/bug and needs-cherry-pick.
+	Labels []string `json:"labels,omitempty"`
+	// ReleaseNoteCategories of which a PR must have a release note to be cherry-picked, e.g. bugfix.
+	ReleaseNoteCategories []string `json:"releaseNoteCategories,omitempty"`
+	// Milestones of which a PR must belong to one to be cherry-picked.
+	Milestones []string `json:"milestones,omitempty"`
+	// BranchPattern is a regular expression matching the release branches.
+	BranchPattern string `json:"branchPattern"`
+	// LatestBranches is the number of most recent release branches the PR is cherry-picked to.
+	LatestBranches int `json:"latestBranches"`
+
+	// The compiled BranchPattern is set by compile when the configuration is loaded.
+	branchPattern *regexp.Regexp
+}
+
+// compile validates the policy and compiles its branch pattern.
+func (a *autoCherryPick) compile() error {
+	if len(a.Labels) == 0 && len(a.ReleaseNoteCategories) == 0 && len(a.Milestones) == 0 {
+		return fmt.Errorf("labels, releaseNoteCategories or milestones must be set")
+	}
+	if a.BranchPattern == "" {
+		return fmt.Errorf("branchPattern must be set")
+	}
+	pattern, err := regexp.Compile(a.BranchPattern)
+	if err != nil {
+		return fmt.Errorf("invalid branchPattern %q: %w", a.BranchPattern, err)
+	}
+	a.branchPattern = pattern
+	if a.LatestBranches < 1 {
+		return fmt.Errorf("latestBranches must be at least 1, got %d", a.LatestBranches)
+	}
+	return nil
+}
+
+// reason returns why the PR is cherry-picked automatically or an empty string if it is not.
+func (a *autoCherryPick) reason(pr github.PullRequest, labels []github.Label) string {
+	if len(a.Labels) > 0 {
+		names := make([]string, 0, len(labels))
+		for _, label := range labels {
+			names = append(names, label.Name)
+		}
+		hasAll := true
+		for _, label := range a.Labels {
+			hasAll = hasAll && slices.Contains(names, label)
+		}
+		if hasAll {
+			return fmt.Sprintf("it has the label(s) `%s`", strings.Join(a.Labels, "`, `"))
+		}
+	}
+	for _, match := range releaseNoteRe.FindAllStringSubmatch(pr.Body, -1) {
+		if slices.Contains(a.ReleaseNoteCategories, match[2]) {
+			return fmt.Sprintf("it has a `%s` release note", match[2])
+		}
+	}
+	if pr.Milestone != nil && slices.Contains(a.Milestones, pr.Milestone.Title) {
+		return fmt.Sprintf("it belongs to the milestone `%s`", pr.Milestone.Title)
+	}
+	return ""
+}
+
+// autoCherryPickBranches returns the release branches a merged PR is cherry-picked to automatically
+// together with the reason, if the repository has an automatic cherry-pick policy which applies to
+// the PR. PRs merged into release branches, e.g. the cherry-picks themselves, are not cherry-picked
+// automatically, so that cherry-picks do not cascade onto older release branches.
+func (s *Server) autoCherryPickBranches(org, repo string, pr github.PullRequest, labels []github.Label) ([]string, string, error) {
+	policy := s.settings(org, repo).autoCherryPick
+	if policy == nil {
+		return nil, "", nil
+	}
+	pattern := policy.branchPattern
+	if pattern.MatchString(pr.Base.Ref) {
+		return nil, "", nil
+	}
+	reason := policy.reason(pr, labels)
+	if reason == "" {
+		return nil, "", nil
+	}
+
+	branches, err := s.ghc.GetBranches(org, repo, false)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list branches of %s/%s: %w", org, repo, err)
+	}
+	var releaseBranches []string
+	for _, branch := range branches {
+		if pattern.MatchString(branch.Name) {
+			releaseBranches = append(releaseBranches, branch.Name)
+		}
+	}
+	sortBranchesByVersion(releaseBranches)
+	if len(releaseBranches) > policy.LatestBranches {
+		releaseBranches = releaseBranches[:policy.LatestBranches]
+	}
+	return releaseBranches, reason, nil
+}
+
+var numberRe = regexp.MustCompile(`\d+`)
+
+// versionNumbers returns the numbers in the name of a branch or milestone, e.g. [1 10] for release-v1.10.
+func versionNumbers(name string) []int {
+	var v []int
+	for _, n := range numberRe.FindAllString(name, -1) {
+		i, _ := strconv.Atoi(n)
+		v = append(v, i)
+	}
+	return v
+}
+
+// sortBranchesByVersion sorts the branches by the numbers in their names, most recent first, e.g.
+// release-v1.10 before release-v1.9.
+func sortBranchesByVersion(branches []string) {
+	sort.SliceStable(branches, func(i, j int) bool {
+		if c := slices.Compare(versionNumbers(branches[i]), versionNumbers(branches[j])); c != 0 {
+			return c > 0
+		}
+		return branches[i] > branches[j]
+	})
+}
+
+// requestAutoCherryPicks adds the automatic cherry-picks of a merged PR to the requests, unless they
+// were requested already, and posts a summary comment. The returned comments are attached to the
+// cherry-pick jobs, so that their responses refer to the policy.
+func (s *Server) requestAutoCherryPicks(log logrus.FieldLogger, pr github.PullRequest, branches []string, reason string, requestorToComments map[string]map[string]*github.IssueComment) {
+	org, repo, num := pr.Base.Repo.Owner.Login, pr.Base.Repo.Name, pr.Number
+
+	var added []string
+	for _, branch := range branches {
+		requested := false
+		for _, requestedBranches := range requestorToComments {
+			if _, ok := requestedBranches[branch]; ok {
+				requested = true
+			}
+		}
+		if requested {
+			continue
+		}
+		if requestorToComments[pr.User.Login] == nil {
+			requestorToComments[pr.User.Login] = make(map[string]*github.IssueComment)
+		}
+		requestorToComments[pr.User.Login][branch] = &github.IssueComment{
+			User:    pr.User,
+			Body:    fmt.Sprintf("Automatic cherry-pick onto `%s`, because %s.", branch, reason),
+			HTMLURL: pr.HTMLURL,
+		}
+		added = append(added, fmt.Sprintf("`%s`", branch))
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	resp := fmt.Sprintf("The present PR is cherry-picked automatically onto %s, because %s.", strings.Join(added, ", "), reason)
+	log.Info(resp)
+	if err := s.ghc.CreateComment(org, repo, num, resp); err != nil {
+		log.WithError(err).Warn("Failed to create summary comment of automatic cherry-picks.")
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestAutoCherryPickReason(t *testing.T) {
+	t.Parallel()
+	policy := &autoCherryPick{
+		Labels:                []string{"kind/bug", "needs-cherry-pick"},
+		ReleaseNoteCategories: []string{"bugfix", "breaking"},
+		Milestones:            []string{"v1.10"},
+	}
+
+	tests := []struct {
+		name     string
+		pr       github.PullRequest
+		labels   []string
+		expected string
+	}{
+		{
+			name:     "all labels",
+			labels:   []string{"kind/bug", "needs-cherry-pick", "lgtm"},
+			expected: "it has the label(s) `kind/bug`, `needs-cherry-pick`",
+		},
+		{
+			name:   "some labels",
+			labels: []string{"kind/bug"},
+		},
+		{
+			name:     "bugfix release note",
+			pr:       github.PullRequest{Body: "Fix X\n\n```bugfix user\nFix X\n```\n"},
+			expected: "it has a `bugfix` release note",
+		},
+		{
+			name: "other release note",
+			pr:   github.PullRequest{Body: "Add X\n\n```feature user\nAdd X\n```\n"},
+		},
+		{
+			name:     "milestone",
+			pr:       github.PullRequest{Milestone: &github.Milestone{Title: "v1.10"}},
+			expected: "it belongs to the milestone `v1.10`",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var labels []github.Label
+			for _, label := range tc.labels {
+				labels = append(labels, github.Label{Name: label})
+			}
+			if got := policy.reason(tc.pr, labels); got != tc.expected {
+				t.Errorf("expected reason %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAutoCherryPickBranches(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{}
+	for _, branch := range []string{"master", "release-v1.8", "release-v1.9", "release-v1.10", "release-v1.11", "feature"} {
+		ghc.branches = append(ghc.branches, github.Branch{Name: branch})
+	}
+	s := &Server{
+		ghc: ghc,
+		config: &configAgent{config: mustCompileConfig(t, overrides{AutoCherryPick: &autoCherryPick{
+			ReleaseNoteCategories: []string{"bugfix"},
+			BranchPattern:         `^release-v\d+\.\d+$`,
+			LatestBranches:        3,
+		}})},
+	}
+
+	pr := github.PullRequest{Body: "```bugfix operator\nFix X\n```"}
+	pr.Base.Ref = "master"
+	branches, reason, err := s.autoCherryPickBranches("foo", "bar", pr, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"release-v1.11", "release-v1.10", "release-v1.9"}, branches); diff != "" {
+		t.Errorf("unexpected branches (-want +got):\n%s", diff)
+	}
+	if reason == "" {
+		t.Error("expected a reason")
+	}
+
+	// PRs merged into release branches are not cherry-picked further.
+	pr.Base.Ref = "release-v1.11"
+	if branches, _, err := s.autoCherryPickBranches("foo", "bar", pr, nil); err != nil || branches != nil {
+		t.Errorf("expected no branches for PR merged into a release branch, got %v, %v", branches, err)
+	}
+
+	pr.Base.Ref = "master"
+	pr.Body = "```other operator\nRefactor X\n```"
+	if branches, _, err := s.autoCherryPickBranches("foo", "bar", pr, nil); err != nil || branches != nil {
+		t.Errorf("expected no branches without error, got %v, %v", branches, err)
+	}
+}
+
+func TestAutoCherryPickDoesNotCascade(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{}
+	for _, branch := range []string{"master", "release-v1.9", "release-v1.10"} {
+		ghc.branches = append(ghc.branches, github.Branch{Name: branch})
+	}
+	s := &Server{
+		ghc:      ghc,
+		jobs:     js,
+		allowAll: true,
+		config: &configAgent{config: mustCompileConfig(t, overrides{AutoCherryPick: &autoCherryPick{
+			ReleaseNoteCategories: []string{"bugfix"},
+			BranchPattern:         `^release-v\d+\.\d+$`,
+			LatestBranches:        2,
+		}})},
+	}
+
+	// The automatic cherry-pick of #2 onto release-v1.10 merges and carries the bugfix release note of #2.
+	mergeSHA := "abcdef"
+	pre := github.PullRequestEvent{
+		Action: github.PullRequestActionClosed,
+		PullRequest: github.PullRequest{
+			Number:   3,
+			Title:    "[release-v1.10] Fix X",
+			Body:     "This is an automated cherry-pick of #2\n\n```bugfix operator\nFix X\n```",
+			Merged:   true,
+			MergeSHA: &mergeSHA,
+			User:     github.User{Login: "ci-robot"},
+			Base:     github.PullRequestBranch{Ref: "release-v1.10", Repo: github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"}},
+		},
+	}
+	if _, err := s.handlePullRequest(logrus.WithField("test", t.Name()), pre); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jobs := js.list(); len(jobs) != 0 {
+		t.Errorf("expected no cherry-pick jobs for the merged cherry-pick PR, got %+v", jobs)
+	}
+	if len(ghc.comments) != 0 {
+		t.Errorf("expected no comments, got %v", ghc.comments)
+	}
+}
+
+func TestRequestAutoCherryPicks(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{}
+	s := &Server{ghc: ghc}
+
+	pr := github.PullRequest{Number: 2, User: github.User{Login: "developer"}, HTMLURL: "https://github.com/foo/bar/pull/2"}
+	pr.Base.Repo.Owner.Login = "foo"
+	pr.Base.Repo.Name = "bar"
+	requested := &github.IssueComment{User: github.User{Login: "wiseguy"}, Body: "/cherrypick release-v1.10"}
+	requestorToComments := map[string]map[string]*github.IssueComment{
+		"wiseguy": {"release-v1.10": requested},
+	}
+
+	s.requestAutoCherryPicks(logrus.WithField("test", t.Name()), pr, []string{"release-v1.10", "release-v1.9"}, "it has a `bugfix` release note", requestorToComments)
+
+	if requestorToComments["wiseguy"]["release-v1.10"] != requested {
+		t.Error("expected explicit request to be kept")
+	}
+	if _, ok := requestorToComments["developer"]["release-v1.10"]; ok {
+		t.Error("expected already requested branch not to be requested again")
+	}
+	comment := requestorToComments["developer"]["release-v1.9"]
+	if comment == nil || comment.HTMLURL != pr.HTMLURL || !strings.Contains(comment.Body, "release-v1.9") {
+		t.Fatalf("expected automatic request of release-v1.9, got %+v", comment)
+	}
+	if len(ghc.comments) != 1 || !strings.Contains(ghc.comments[0], "automatically onto `release-v1.9`, because it has a `bugfix` release note.") {
+		t.Errorf("expected summary comment, got %v", ghc.comments)
+	}
+}
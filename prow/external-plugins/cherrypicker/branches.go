@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// maxSuggestions is the number of similar branches suggested for an unknown target branch.
+const maxSuggestions = 3
+
+// invalidTargetBranches checks the target branches of the given commands against the branches of the
+// target repository and its target branch pattern. It returns a description per invalid target branch.
+func (s *Server) invalidTargetBranches(org, repo string, commands cherrypickCommands) (map[string]string, error) {
+	// org/repo -> branches
+	branchesByRepo := make(map[string][]string)
+	listBranches := func(org, repo string) ([]string, error) {
+		if existing, ok := branchesByRepo[org+"/"+repo]; ok {
+			return existing, nil
+		}
+		branches, err := s.ghc.GetBranches(org, repo, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branches of %s/%s: %w", org, repo, err)
+		}
+		existing := make([]string, 0, len(branches))
+		for _, branch := range branches {
+			existing = append(existing, branch.Name)
+		}
+		branchesByRepo[org+"/"+repo] = existing
+		return existing, nil
+	}
+
+	invalid := make(map[string]string)
+	for target, chain := range commands {
+		t := parseTarget(org, repo, target)
+		targetOrg, targetRepo := t.orgRepo(org, repo)
+		existing, err := listBranches(targetOrg, targetRepo)
+		if err != nil {
+			if !t.crossRepo() {
+				return nil, err
+			}
+			invalid[target] = fmt.Sprintf("`%s/%s` cannot be accessed", targetOrg, targetRepo)
+			continue
+		}
+
+		pattern := s.settings(targetOrg, targetRepo).targetBranchPattern
+		for _, branch := range append([]string{t.branch}, chain...) {
+			if pattern != nil && !pattern.MatchString(branch) {
+				invalid[target] = fmt.Sprintf("`%s` is not an allowed cherry-pick target (allowed branches must match `%s`)", t.withBranch(branch), pattern.String())
+				break
+			}
+			if !slices.Contains(existing, branch) {
+				msg := fmt.Sprintf("`%s` does not exist", t.withBranch(branch))
+				if suggestions := similarBranches(branch, existing); len(suggestions) > 0 {
+					for i := range suggestions {
+						suggestions[i] = t.withBranch(suggestions[i])
+					}
+					msg += fmt.Sprintf(", did you mean `%s`?", strings.Join(suggestions, "`, `"))
+				}
+				invalid[target] = msg
+				break
+			}
+		}
+	}
+	return invalid, nil
+}
+
+// invalidTargetBranchesMessage formats the descriptions of invalid target branches as response.
+func invalidTargetBranchesMessage(invalid map[string]string) string {
+	var b strings.Builder
+	b.WriteString("I cannot cherry-pick the present PR on top of the following branches:\n")
+	for _, target := range sets.List(sets.KeySet(invalid)) {
+		fmt.Fprintf(&b, "- %s\n", invalid[target])
+	}
+	return b.String()
+}
+
+// similarBranches returns the branches which are closest to the given unknown branch.
+func similarBranches(branch string, branches []string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	// Allow roughly one edit per four characters, e.g. `release-v1.9` for `release-1.9`.
+	maxDistance := max(len(branch)/4, 1)
+
+	var candidates []candidate
+	for _, name := range branches {
+		if d := levenshtein(strings.ToLower(branch), strings.ToLower(name)); d <= maxDistance {
+			candidates = append(candidates, candidate{name: name, distance: d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance == candidates[j].distance {
+			return candidates[i].name < candidates[j].name
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestInvalidTargetBranches(t *testing.T) {
+	t.Parallel()
+	branches := []github.Branch{{Name: "master"}, {Name: "release-v1.9"}, {Name: "release-v1.10"}, {Name: "feature"}}
+	testCases := []struct {
+		name     string
+		pattern  *regexp.Regexp
+		commands cherrypickCommands
+		want     map[string]string
+	}{
+		{
+			name:     "existing branches",
+			commands: cherrypickCommands{"release-v1.9": nil, "release-v1.10": []string{"release-v1.9"}},
+			want:     map[string]string{},
+		},
+		{
+			name:     "typo",
+			commands: cherrypickCommands{"release-1.9": nil, "release-v1.10": nil},
+			want:     map[string]string{"release-1.9": "`release-1.9` does not exist, did you mean `release-v1.9`?"},
+		},
+		{
+			name:     "unknown branch in chain",
+			commands: cherrypickCommands{"release-v1.10": []string{"release-v1.8"}},
+			want:     map[string]string{"release-v1.10": "`release-v1.8` does not exist, did you mean `release-v1.9`, `release-v1.10`?"},
+		},
+		{
+			name:     "no similar branch",
+			commands: cherrypickCommands{"something-else": nil},
+			want:     map[string]string{"something-else": "`something-else` does not exist"},
+		},
+		{
+			name:     "branch does not match pattern",
+			pattern:  regexp.MustCompile(`^release-v\d+\.\d+$`),
+			commands: cherrypickCommands{"feature": nil, "release-v1.9": nil},
+			want:     map[string]string{"feature": "`feature` is not an allowed cherry-pick target (allowed branches must match `^release-v\\d+\\.\\d+$`)"},
+		},
+		{
+			name:     "branch of other repository",
+			commands: cherrypickCommands{"baz/qux:release-v1.9": nil, "baz/qux:release-1.9": nil},
+			want:     map[string]string{"baz/qux:release-1.9": "`baz/qux:release-1.9` does not exist, did you mean `baz/qux:release-v1.9`?"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := &Server{ghc: &fghc{branches: branches}, targetBranchPattern: tc.pattern}
+			got, err := s.invalidTargetBranches("foo", "bar", tc.commands)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected invalid branches (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimilarBranches(t *testing.T) {
+	t.Parallel()
+	branches := []string{"master", "main", "release-1.9", "release-1.19", "release-v1.9"}
+	if diff := cmp.Diff([]string{"release-1.9", "release-1.19", "release-v1.9"}, similarBranches("release-1.9x", branches)); diff != "" {
+		t.Errorf("unexpected suggestions (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"master"}, similarBranches("mater", branches)); diff != "" {
+		t.Errorf("unexpected suggestions (-want +got):\n%s", diff)
+	}
+	if got := similarBranches("develop", branches); got != nil {
+		t.Errorf("expected no suggestions, got %v", got)
+	}
+}
+
+func TestHandleIssueCommentInvalidBranch(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{
+		prs:      []github.PullRequest{{Number: 2, Base: github.PullRequestBranch{Ref: "master"}, User: github.User{Login: "developer"}}},
+		branches: []github.Branch{{Name: "master"}, {Name: "release-1.9"}},
+	}
+	s := &Server{ghc: ghc, allowAll: true, log: logrus.WithField("test", t.Name())}
+
+	ic := github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"},
+		Issue:  github.Issue{Number: 2, State: "open", PullRequest: &struct{}{}},
+		Comment: github.IssueComment{
+			Body: "/cherrypick release-v1.9",
+			User: github.User{Login: "wiseguy"},
+		},
+	}
+	if _, err := s.handleIssueComment(s.log, ic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 1 {
+		t.Fatalf("expected one comment, got %v", ghc.comments)
+	}
+	if want := "`release-v1.9` does not exist, did you mean `release-1.9`?"; !strings.Contains(ghc.comments[0], want) {
+		t.Errorf("expected comment to contain %q, got %q", want, ghc.comments[0])
+	}
+	if strings.Contains(ghc.comments[0], "once the present PR merges") {
+		t.Errorf("expected no cherry-pick to be promised, got %q", ghc.comments[0])
+	}
+}
@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// chainStatusMarker identifies the comment with the status of chained cherry-picks on the original PR.
+const chainStatusMarker = "<!-- cherrypicker: chain status -->"
+
+// chained returns true if the job is a step of a chained cherry-pick.
+func (j *cherryPickJob) chained() bool {
+	return j.ChainOrigin != 0 || len(j.ChainBranches) > 0
+}
+
+// origin returns the number of the PR on which the chain of the job was requested.
+func (j *cherryPickJob) origin() int {
+	if j.ChainOrigin != 0 {
+		return j.ChainOrigin
+	}
+	return j.Number
+}
+
+// chainStatus describes the state of a step of a chained cherry-pick.
+func (j *cherryPickJob) chainStatus() string {
+	switch j.State {
+	case jobStateSucceeded:
+		if j.ResultMerged {
+			return fmt.Sprintf("merged #%d", j.ResultPR)
+		}
+		return fmt.Sprintf("open #%d", j.ResultPR)
+	case jobStateConflicted:
+		return "conflict"
+	case jobStateFailed:
+		return "failed"
+	case jobStatePending:
+		return "pending"
+	default:
+		return "in progress"
+	}
+}
+
+// recordMergedCherryPick marks the job which created the given cherry-pick PR as merged and returns it.
+// The cherry-pick PR belongs to the target repository of the job, which differs from the repository of
+// the original PR for targets in other repositories. If the job is a step of a chained cherry-pick, the
+// status on the original PR is updated.
+func (s *Server) recordMergedCherryPick(log logrus.FieldLogger, pr github.PullRequest) *cherryPickJob {
+	org, repo := pr.Base.Repo.Owner.Login, pr.Base.Repo.Name
+	for _, job := range s.jobs.list() {
+		if targetOrg, targetRepo := job.targetOrgRepo(); targetOrg != org || targetRepo != repo || job.ResultPR != pr.Number {
+			continue
+		}
+		job.ResultMerged = true
+		if err := s.jobs.put(job); err != nil {
+			log.WithError(err).Warn("Failed to persist merged cherry-pick job.")
+		}
+		s.reportCommitStatus(log, &job)
+		if job.chained() {
+			if err := s.updateChainStatus(job.Org, job.Repo, job.origin()); err != nil {
+				log.WithError(err).Warn("Failed to update status of chained cherry-picks.")
+			}
+		}
+		return &job
+	}
+	return nil
+}
+
+// chainOrigin returns the number of the original PR if the cherry-pick of a PR created by parent onto
+// targetBranch continues a chain. Otherwise, it returns 0.
+func chainOrigin(parent *cherryPickJob, targetBranch string) int {
+	if parent == nil || len(parent.ChainBranches) == 0 || parent.ChainBranches[0] != targetBranch {
+		return 0
+	}
+	return parent.origin()
+}
+
+// updateChainStatus creates or updates the comment with the status of all chained cherry-picks of the
+// original PR num.
+func (s *Server) updateChainStatus(org, repo string, num int) error {
+	jobs := s.jobs.list()
+	var rows []string
+	for _, first := range jobs {
+		if first.Org != org || first.Repo != repo || first.Number != num || first.ChainOrigin != 0 || len(first.ChainBranches) == 0 {
+			continue
+		}
+		rows = append(rows, fmt.Sprintf("| `%s` | %s |", first.TargetBranch, first.chainStatus()))
+		for _, branch := range first.ChainBranches {
+			status := "pending"
+			for _, job := range jobs {
+				if job.Org == org && job.Repo == repo && job.ChainOrigin == num && job.TargetBranch == branch {
+					status = job.chainStatus()
+				}
+			}
+			rows = append(rows, fmt.Sprintf("| `%s` | %s |", branch, status))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	status := fmt.Sprintf("%s\nStatus of the chained cherry-picks of the present PR:\n\n| Target branch | Status |\n| --- | --- |\n%s\n", chainStatusMarker, strings.Join(rows, "\n"))
+
+	comments, err := s.ghc.ListIssueComments(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("failed to list comments: %w", err)
+	}
+	for _, comment := range comments {
+		if comment.User.Login == s.botUser.Login && strings.Contains(comment.Body, chainStatusMarker) {
+			if comment.Body == status {
+				return nil
+			}
+			return s.ghc.EditComment(org, repo, comment.ID, status)
+		}
+	}
+	return s.ghc.CreateComment(org, repo, num, status)
+}
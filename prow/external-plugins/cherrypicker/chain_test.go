@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestChainStatus(t *testing.T) {
+	t.Parallel()
+	jobs, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{}
+	s := &Server{ghc: ghc, jobs: jobs, botUser: &github.UserData{Login: "ci-robot"}}
+	log := logrus.WithField("test", t.Name())
+
+	// #2 is cherry-picked onto release-1.6, release-1.5 and release-1.4.
+	first := cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "release-1.6", ChainBranches: []string{"release-1.5", "release-1.4"}, State: jobStateSucceeded, ResultPR: 3}
+	if err := jobs.put(first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.updateChainStatus("foo", "bar", 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 1 || !strings.Contains(ghc.comments[0], "| `release-1.6` | open #3 |\n| `release-1.5` | pending |\n| `release-1.4` | pending |") {
+		t.Fatalf("expected status comment, got %v", ghc.comments)
+	}
+
+	// #3 merges and continues the chain onto release-1.5.
+	pr := github.PullRequest{Number: 3}
+	pr.Base.Repo.Owner.Login = "foo"
+	pr.Base.Repo.Name = "bar"
+	ghc.prComments = []github.IssueComment{{ID: 1, User: github.User{Login: "ci-robot"}, Body: chainStatusMarker}}
+	parent := s.recordMergedCherryPick(log, pr)
+	if parent == nil || !parent.ResultMerged {
+		t.Fatalf("expected merged parent job, got %+v", parent)
+	}
+	if origin := chainOrigin(parent, "release-1.5"); origin != 2 {
+		t.Errorf("expected chain origin 2, got %d", origin)
+	}
+	if origin := chainOrigin(parent, "release-1.4"); origin != 0 {
+		t.Errorf("expected release-1.4 not to continue the chain yet, got origin %d", origin)
+	}
+
+	next := cherryPickJob{Org: "foo", Repo: "bar", Number: 3, TargetBranch: "release-1.5", ChainBranches: []string{"release-1.4"}, ChainOrigin: 2, State: jobStateConflicted}
+	if err := jobs.put(next); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.updateChainStatus("foo", "bar", next.origin()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 1 {
+		t.Errorf("expected status comment to be edited instead of created, got %v", ghc.comments)
+	}
+	if body := ghc.prComments[0].Body; !strings.Contains(body, "| `release-1.6` | merged #3 |\n| `release-1.5` | conflict |\n| `release-1.4` | pending |") {
+		t.Errorf("expected updated status comment, got %q", body)
+	}
+}
+
+func TestRecordMergedCrossRepoCherryPick(t *testing.T) {
+	t.Parallel()
+	jobs, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{ghc: &fghc{}, jobs: jobs}
+	log := logrus.WithField("test", t.Name())
+
+	// #2 of foo/bar is cherry-picked onto other/fork in #3, which has the same number as a PR of foo/bar.
+	if err := jobs.put(cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetOrg: "other", TargetRepo: "fork", TargetBranch: "release-1.6", State: jobStateSucceeded, ResultPR: 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pr := github.PullRequest{Number: 3}
+	pr.Base.Repo.Owner.Login = "foo"
+	pr.Base.Repo.Name = "bar"
+	if job := s.recordMergedCherryPick(log, pr); job != nil {
+		t.Fatalf("expected PR of the original repository not to be recorded, got %+v", job)
+	}
+
+	pr.Base.Repo.Owner.Login = "other"
+	pr.Base.Repo.Name = "fork"
+	if job := s.recordMergedCherryPick(log, pr); job == nil || !job.ResultMerged {
+		t.Fatalf("expected merged job, got %+v", job)
+	}
+	if got := jobs.list()[0]; !got.ResultMerged {
+		t.Errorf("expected merged job to be persisted, got %+v", got)
+	}
+}
@@ -0,0 +1,255 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/github"
+	"sigs.k8s.io/prow/pkg/plugins"
+)
+
+const (
+	cancelCommand = "cancel"
+	listCommand   = "list"
+	// cancelAll cancels the cherry-picks onto all branches.
+	cancelAll = "all"
+)
+
+var (
+	cherryPickCancelRe = regexp.MustCompile(`(?m)^(?:/cherrypick|/cherry-pick)\s+cancel\s+(.+)$`)
+	cherryPickListRe   = regexp.MustCompile(`(?m)^(?:/cherrypick|/cherry-pick)\s+list\s*$`)
+)
+
+// isReservedCommand returns true if the first argument of a cherrypick command is a sub-command
+// instead of a target branch.
+func isReservedCommand(arg string) bool {
+	return arg == cancelCommand || arg == listCommand
+}
+
+// parseCancel returns the branches of all cancel commands in the comment. A cancelled branch
+// "all" cancels all requested cherry-picks.
+func parseCancel(comment github.IssueComment) []string {
+	var branches []string
+	for _, match := range cherryPickCancelRe.FindAllStringSubmatch(comment.Body, -1) {
+		branches = append(branches, strings.Fields(match[1])...)
+	}
+	return branches
+}
+
+// isListCommand returns true if the comment contains a list command.
+func isListCommand(comment github.IssueComment) bool {
+	return cherryPickListRe.MatchString(comment.Body)
+}
+
+// cherryPickRequests collects the cherry-pick requests from the given comments in their order. A
+// cancel command removes the earlier requests of the cancelled branches, if its author is trusted.
+// It returns the requests per requestor and target branch and the chain branches per target branch.
+func cherryPickRequests(comments []github.IssueComment, trusted func(login string) (bool, error)) (map[string]map[string]*github.IssueComment, map[string][]string, error) {
+	// requestor -> target branch -> issue comment
+	requestorToComments := make(map[string]map[string]*github.IssueComment)
+	// target branch -> chain branches (eg. "release-1.6" -> []string{"release-1.5", "release-1.4"})
+	targetBranchToChainBranches := make(map[string][]string)
+
+	for _, comment := range comments {
+		for targetBranch, chainedBranches := range parseComment(comment) {
+			if requestorToComments[comment.User.Login] == nil {
+				requestorToComments[comment.User.Login] = make(map[string]*github.IssueComment)
+			}
+			requestorToComments[comment.User.Login][targetBranch] = &comment
+
+			if len(chainedBranches) > 0 {
+				targetBranchToChainBranches[targetBranch] = chainedBranches
+			}
+		}
+
+		cancelled := parseCancel(comment)
+		if len(cancelled) == 0 {
+			continue
+		}
+		ok, err := trusted(comment.User.Login)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		for _, branch := range cancelled {
+			for requestor, branches := range requestorToComments {
+				if branch == cancelAll {
+					delete(requestorToComments, requestor)
+					continue
+				}
+				delete(branches, branch)
+				if len(branches) == 0 {
+					delete(requestorToComments, requestor)
+				}
+			}
+			if branch == cancelAll {
+				clear(targetBranchToChainBranches)
+			} else {
+				delete(targetBranchToChainBranches, branch)
+			}
+		}
+	}
+	return requestorToComments, targetBranchToChainBranches, nil
+}
+
+// trustedFunc returns a function which checks whether a user may request and cancel cherry-picks.
+func (s *Server) trustedFunc(org, repo string) func(login string) (bool, error) {
+	allowAll := s.settings(org, repo).allowAll
+	return func(login string) (bool, error) {
+		if allowAll {
+			return true, nil
+		}
+		return s.isTrustedUser(org, repo, login)
+	}
+}
+
+// handleCancel cancels the cherry-picks onto the given branches, which were requested on an open PR.
+func (s *Server) handleCancel(log logrus.FieldLogger, ic github.IssueCommentEvent, cancelled []string) error {
+	org, repo, num := ic.Repo.Owner.Login, ic.Repo.Name, ic.Issue.Number
+
+	ok, err := s.trustedFunc(org, repo)(ic.Comment.User.Login)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		resp := fmt.Sprintf(notOrgMemberMessageTemplate, org, org, org, ic.Comment.User.Login)
+		log.Info(resp)
+		return s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+	}
+
+	if ic.Issue.State == "closed" {
+		resp := "the present PR is already closed, so its cherry-picks cannot be cancelled anymore. Close the cherry-pick PRs instead."
+		log.Info(resp)
+		return s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+	}
+
+	labels, err := s.ghc.GetIssueLabels(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("failed to get issue labels: %w", err)
+	}
+	labelPrefix := s.settings(org, repo).labelPrefix
+
+	var resp string
+	var remainingLabels []string
+	if sets.New(cancelled...).Has(cancelAll) {
+		if err := s.jobs.removePending(org, repo, num, ""); err != nil {
+			log.WithError(err).Warn("Failed to remove pending cherry-pick jobs.")
+		}
+		resp = "I will not cherry-pick the present PR on top of any branch."
+		for _, label := range labels {
+			if strings.HasPrefix(label.Name, labelPrefix) {
+				remainingLabels = append(remainingLabels, fmt.Sprintf("`%s`", label.Name))
+			}
+		}
+	} else {
+		var branchNames []string
+		for _, branch := range sets.List(sets.New(cancelled...)) {
+			if err := s.jobs.removePending(org, repo, num, branch); err != nil {
+				log.WithError(err).Warn("Failed to remove pending cherry-pick job.")
+			}
+			branchNames = append(branchNames, fmt.Sprintf("`%s`", branch))
+			for _, label := range labels {
+				if label.Name == labelPrefix+branch {
+					remainingLabels = append(remainingLabels, fmt.Sprintf("`%s`", label.Name))
+				}
+			}
+		}
+		resp = fmt.Sprintf("I will not cherry-pick the present PR on top of %s.", strings.Join(branchNames, ", "))
+	}
+	if len(remainingLabels) > 0 {
+		resp += fmt.Sprintf(" Remove the label(s) %s as well, otherwise the cherry-picks are still created once the present PR merges.", strings.Join(remainingLabels, ", "))
+	}
+
+	log.Info(resp)
+	return s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+}
+
+// handleList replies with the cherry-picks of the PR. For open PRs these are the requested target
+// branches, for closed PRs the cherry-pick jobs.
+func (s *Server) handleList(log logrus.FieldLogger, ic github.IssueCommentEvent, pr *github.PullRequest) error {
+	org, repo, num := ic.Repo.Owner.Login, ic.Repo.Name, ic.Issue.Number
+
+	if ic.Issue.State == "closed" {
+		var rows []string
+		for _, job := range s.jobs.list() {
+			if job.Org != org || job.Repo != repo || job.Number != num {
+				continue
+			}
+			result := ""
+			if job.ResultPR != 0 {
+				result = job.targetRef(job.ResultPR)
+			}
+			rows = append(rows, fmt.Sprintf("| `%s` | @%s | %s | %s |", job.target(), job.Requestor, job.State, result))
+		}
+		resp := "there are no cherry-picks of the present PR."
+		if len(rows) > 0 {
+			resp = "the present PR has the following cherry-picks:\n\n| Target branch | Requested by | State | PR |\n| --- | --- | --- | --- |\n" + strings.Join(rows, "\n")
+		}
+		return s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+	}
+
+	comments, err := s.ghc.ListIssueComments(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("failed to list comments: %w", err)
+	}
+	comments = append([]github.IssueComment{{Body: pr.Body, User: pr.User}}, comments...)
+	trusted := s.trustedFunc(org, repo)
+	requestorToComments, _, err := cherryPickRequests(comments, trusted)
+	if err != nil {
+		return err
+	}
+
+	// target branch -> requestors
+	targets := make(map[string]sets.Set[string])
+	for requestor, branches := range requestorToComments {
+		ok, err := trusted(requestor)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for branch := range branches {
+			if targets[branch] == nil {
+				targets[branch] = sets.New[string]()
+			}
+			targets[branch].Insert("@" + requestor)
+		}
+	}
+
+	labels, err := s.ghc.GetIssueLabels(org, repo, num)
+	if err != nil {
+		return fmt.Errorf("failed to get issue labels: %w", err)
+	}
+	labelPrefix := s.settings(org, repo).labelPrefix
+	for _, label := range labels {
+		if strings.HasPrefix(label.Name, labelPrefix) {
+			branch := label.Name[len(labelPrefix):]
+			if targets[branch] == nil {
+				targets[branch] = sets.New[string]()
+			}
+			targets[branch].Insert(fmt.Sprintf("label `%s`", label.Name))
+		}
+	}
+
+	resp := "there are no pending cherry-picks of the present PR."
+	if len(targets) > 0 {
+		var b strings.Builder
+		b.WriteString("once the present PR merges, I will cherry-pick it on top of the following branches:\n\n| Target branch | Requested by |\n| --- | --- |\n")
+		for _, branch := range sets.List(sets.KeySet(targets)) {
+			fmt.Fprintf(&b, "| `%s` | %s |\n", branch, strings.Join(sets.List(targets[branch]), ", "))
+		}
+		resp = b.String()
+	}
+	log.Info("Listed cherry-picks.")
+	return s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+}
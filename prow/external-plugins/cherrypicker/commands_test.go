@@ -0,0 +1,172 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestCherryPickRequests(t *testing.T) {
+	t.Parallel()
+	comment := func(login, body string) github.IssueComment {
+		return github.IssueComment{User: github.User{Login: login}, Body: body}
+	}
+	trusted := func(login string) (bool, error) {
+		return login != "stranger", nil
+	}
+
+	testCases := []struct {
+		name       string
+		comments   []github.IssueComment
+		wantTarget map[string][]string
+		wantChains map[string][]string
+	}{
+		{
+			name: "list and cancel are no target branches",
+			comments: []github.IssueComment{
+				comment("alice", "/cherrypick release-1.0\n/cherrypick list"),
+				comment("bob", "/cherrypick cancel release-2.0"),
+			},
+			wantTarget: map[string][]string{"alice": {"release-1.0"}},
+			wantChains: map[string][]string{},
+		},
+		{
+			name: "cancel removes earlier requests of all requestors",
+			comments: []github.IssueComment{
+				comment("alice", "/cherrypick release-1.0 release-0.9"),
+				comment("bob", "/cherrypick release-1.0\n/cherrypick release-1.1"),
+				comment("alice", "/cherrypick cancel release-1.0"),
+			},
+			wantTarget: map[string][]string{"bob": {"release-1.1"}},
+			wantChains: map[string][]string{},
+		},
+		{
+			name: "later requests are not cancelled",
+			comments: []github.IssueComment{
+				comment("alice", "/cherrypick release-1.0"),
+				comment("alice", "/cherrypick cancel all"),
+				comment("bob", "/cherrypick release-1.1"),
+			},
+			wantTarget: map[string][]string{"bob": {"release-1.1"}},
+			wantChains: map[string][]string{},
+		},
+		{
+			name: "untrusted users cannot cancel",
+			comments: []github.IssueComment{
+				comment("alice", "/cherrypick release-1.0 release-0.9"),
+				comment("stranger", "/cherrypick cancel all"),
+			},
+			wantTarget: map[string][]string{"alice": {"release-1.0"}},
+			wantChains: map[string][]string{"release-1.0": {"release-0.9"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			requests, chains, err := cherryPickRequests(tc.comments, trusted)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := make(map[string][]string)
+			for requestor, branches := range requests {
+				got[requestor] = sets.List(sets.KeySet(branches))
+			}
+			if diff := cmp.Diff(tc.wantTarget, got); diff != "" {
+				t.Errorf("unexpected requests (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantChains, chains); diff != "" {
+				t.Errorf("unexpected chain branches (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleCancel(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, branch := range []string{"release-1.0", "release-1.1"} {
+		if err := js.put(cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: branch, State: jobStatePending}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	ghc := &fghc{
+		prs:      []github.PullRequest{{Number: 2, Base: github.PullRequestBranch{Ref: "master"}}},
+		prLabels: []github.Label{{Name: "cherrypick/release-1.0"}},
+	}
+	s := &Server{ghc: ghc, jobs: js, allowAll: true, labelPrefix: defaultLabelPrefix, log: logrus.WithField("test", t.Name())}
+
+	ic := github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"},
+		Issue:  github.Issue{Number: 2, State: "open", PullRequest: &struct{}{}},
+		Comment: github.IssueComment{
+			Body: "/cherrypick cancel release-1.0",
+			User: github.User{Login: "wiseguy"},
+		},
+	}
+	if _, err := s.handleIssueComment(s.log, ic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jobs := js.list()
+	if len(jobs) != 1 || jobs[0].TargetBranch != "release-1.1" {
+		t.Errorf("expected only the pending job onto release-1.1 to remain, got %+v", jobs)
+	}
+	if len(ghc.comments) != 1 {
+		t.Fatalf("expected one comment, got %v", ghc.comments)
+	}
+	for _, want := range []string{"I will not cherry-pick the present PR on top of `release-1.0`.", "Remove the label(s) `cherrypick/release-1.0`"} {
+		if !strings.Contains(ghc.comments[0], want) {
+			t.Errorf("expected comment to contain %q, got %q", want, ghc.comments[0])
+		}
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{
+		prs: []github.PullRequest{{Number: 2, Base: github.PullRequestBranch{Ref: "master"}, User: github.User{Login: "developer"}, Body: "/cherrypick release-1.0"}},
+		prComments: []github.IssueComment{
+			{User: github.User{Login: "wiseguy"}, Body: "/cherrypick release-1.0\n/cherrypick release-1.1"},
+			{User: github.User{Login: "developer"}, Body: "/cherrypick cancel release-1.1"},
+		},
+		prLabels: []github.Label{{Name: "cherrypick/release-1.2"}},
+	}
+	s := &Server{ghc: ghc, allowAll: true, labelPrefix: defaultLabelPrefix, log: logrus.WithField("test", t.Name())}
+
+	ic := github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"},
+		Issue:  github.Issue{Number: 2, State: "open", PullRequest: &struct{}{}},
+		Comment: github.IssueComment{
+			Body: "/cherrypick list",
+			User: github.User{Login: "wiseguy"},
+		},
+	}
+	if _, err := s.handleIssueComment(s.log, ic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 1 {
+		t.Fatalf("expected one comment, got %v", ghc.comments)
+	}
+	want := "| `release-1.0` | @developer, @wiseguy |\n| `release-1.2` | label `cherrypick/release-1.2` |\n"
+	if !strings.Contains(ghc.comments[0], want) {
+		t.Errorf("expected comment to contain %q, got %q", want, ghc.comments[0])
+	}
+	if strings.Contains(ghc.comments[0], "release-1.1") {
+		t.Errorf("expected cancelled cherry-pick not to be listed, got %q", ghc.comments[0])
+	}
+}
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// cherryPicksCommits returns true if the merged commits instead of the PR patch are cherry-picked
+// for the given repository.
+func (s *Server) cherryPicksCommits(org, repo string) bool {
+	return s.settings(org, repo).cherryPickCommits
+}
+
+// mergeMethod determines how the PR was merged. Merge commits are recognized by their parents.
+// Squash and rebase merges are told apart by the merge methods allowed for the repository and, if
+// both are allowed, by the "(#<number>)" suffix which GitHub adds to the title of squashed commits.
+func (s *Server) mergeMethod(dir string, job *cherryPickJob) (github.PullRequestMergeType, error) {
+	parents, err := runGit(dir, "rev-list", "--parents", "-n", "1", job.MergeSHA)
+	if err != nil {
+		return "", err
+	}
+	// The first field is the commit itself, merge commits have more than one parent.
+	if len(strings.Fields(parents)) > 2 {
+		return github.MergeMerge, nil
+	}
+	if job.Commits <= 1 {
+		return github.MergeSquash, nil
+	}
+
+	fullRepo, err := s.ghc.GetRepo(job.Org, job.Repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository %s/%s: %w", job.Org, job.Repo, err)
+	}
+	switch {
+	case fullRepo.AllowRebaseMerge && !fullRepo.AllowSquashMerge:
+		return github.MergeRebase, nil
+	case fullRepo.AllowSquashMerge && !fullRepo.AllowRebaseMerge:
+		return github.MergeSquash, nil
+	}
+	subject, err := runGit(dir, "log", "-1", "--format=%s", job.MergeSHA)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(strings.TrimSpace(subject), fmt.Sprintf("(#%d)", job.Number)) {
+		return github.MergeSquash, nil
+	}
+	return github.MergeRebase, nil
+}
+
+// cherryPickArgs returns the arguments of `git cherry-pick` for a PR merged with the given method.
+func cherryPickArgs(method github.PullRequestMergeType, mergeSHA string, commits int) []string {
+	switch method {
+	case github.MergeMerge:
+		return []string{"cherry-pick", "-m", "1", mergeSHA}
+	case github.MergeRebase:
+		return []string{"cherry-pick", fmt.Sprintf("%s~%d..%s", mergeSHA, commits, mergeSHA)}
+	default:
+		return []string{"cherry-pick", mergeSHA}
+	}
+}
+
+// fetchMergeCommit makes the merge commit of the PR available in the clone of the target repository. For
+// targets in other repositories, it is fetched from a clone of the repository of the PR.
+func (s *Server) fetchMergeCommit(r git.RepoClient, job *cherryPickJob) error {
+	if job.MergeSHA == "" {
+		return fmt.Errorf("merge commit of %s/%s#%d is unknown", job.Org, job.Repo, job.Number)
+	}
+	if exists, err := r.ObjectExists(job.MergeSHA); err == nil && exists {
+		return nil
+	}
+	if !job.cherryPickTarget().crossRepo() {
+		if err := r.FetchRef(job.MergeSHA); err != nil {
+			return fmt.Errorf("failed to fetch merge commit %s: %w", job.MergeSHA, err)
+		}
+		return nil
+	}
+
+	source, err := s.gc.ClientFor(job.Org, job.Repo)
+	if err != nil {
+		return fmt.Errorf("failed to clone %s/%s: %w", job.Org, job.Repo, err)
+	}
+	defer func() {
+		_ = source.Clean()
+	}()
+	if exists, err := source.ObjectExists(job.MergeSHA); err != nil || !exists {
+		if err := source.FetchRef(job.MergeSHA); err != nil {
+			return fmt.Errorf("failed to fetch merge commit %s from %s/%s: %w", job.MergeSHA, job.Org, job.Repo, err)
+		}
+	}
+	if _, err := runGit(r.Directory(), "fetch", source.Directory(), job.MergeSHA); err != nil {
+		return fmt.Errorf("failed to fetch merge commit %s from %s/%s: %w", job.MergeSHA, job.Org, job.Repo, err)
+	}
+	return nil
+}
+
+// commitCherryPickArgs fetches the merge commit of the PR and returns the arguments of `git cherry-pick`
+// to cherry-pick the commits of the PR according to its merge method.
+func (s *Server) commitCherryPickArgs(logger logrus.FieldLogger, r git.RepoClient, job *cherryPickJob) ([]string, error) {
+	if err := s.fetchMergeCommit(r, job); err != nil {
+		return nil, err
+	}
+
+	method, err := s.mergeMethod(r.Directory(), job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine merge method: %w", err)
+	}
+	job.MergeMethod = method
+	logger.WithField("merge_method", method).Info("Cherry-picking merged commits.")
+	return cherryPickArgs(method, job.MergeSHA, job.Commits), nil
+}
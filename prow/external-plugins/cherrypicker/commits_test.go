@@ -0,0 +1,223 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/prow/pkg/git/localgit"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// dirKeepingFactory keeps the first cloned repository, i.e. the target repository, after handle, so
+// that tests can inspect it.
+type dirKeepingFactory struct {
+	git.ClientFactory
+	dir string
+}
+
+type keptRepoClient struct {
+	git.RepoClient
+}
+
+func (keptRepoClient) Clean() error {
+	return nil
+}
+
+func (f *dirKeepingFactory) ClientFor(org, repo string) (git.RepoClient, error) {
+	r, err := f.ClientFactory.ClientFor(org, repo)
+	if err != nil {
+		return nil, err
+	}
+	if f.dir != "" {
+		return r, nil
+	}
+	f.dir = r.Directory()
+	return keptRepoClient{r}, nil
+}
+
+func TestMergeMethod(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	mustGit(t, dir, "init", "-b", "master")
+	mustGit(t, dir, "config", "user.email", "test@test.test")
+	mustGit(t, dir, "config", "user.name", "test test")
+	commitFile(t, dir, "file", "a\n")
+
+	mustGit(t, dir, "checkout", "-b", "fix")
+	commitFile(t, dir, "file", "b\n")
+	mustGit(t, dir, "checkout", "master")
+	mustGit(t, dir, "merge", "--no-ff", "-m", "merge fix", "fix")
+	mergeSHA := mustGit(t, dir, "rev-parse", "HEAD")
+
+	commitFile(t, dir, "other", "a\n")
+	mustGit(t, dir, "commit", "--amend", "-m", "Squashed fix (#2)")
+	squashSHA := mustGit(t, dir, "rev-parse", "HEAD")
+	commitFile(t, dir, "other", "b\n")
+	rebaseSHA := mustGit(t, dir, "rev-parse", "HEAD")
+
+	testCases := []struct {
+		name     string
+		sha      string
+		commits  int
+		fullRepo github.FullRepo
+		want     github.PullRequestMergeType
+	}{
+		{name: "merge commit", sha: mergeSHA, commits: 1, want: github.MergeMerge},
+		{name: "single commit", sha: rebaseSHA, commits: 1, want: github.MergeSquash},
+		{name: "only rebase allowed", sha: squashSHA, commits: 2, fullRepo: github.FullRepo{AllowRebaseMerge: true, AllowMergeCommit: true}, want: github.MergeRebase},
+		{name: "only squash allowed", sha: rebaseSHA, commits: 2, fullRepo: github.FullRepo{AllowSquashMerge: true}, want: github.MergeSquash},
+		{name: "squashed commit title", sha: squashSHA, commits: 2, fullRepo: github.FullRepo{AllowSquashMerge: true, AllowRebaseMerge: true}, want: github.MergeSquash},
+		{name: "rebased commit title", sha: rebaseSHA, commits: 2, fullRepo: github.FullRepo{AllowSquashMerge: true, AllowRebaseMerge: true}, want: github.MergeRebase},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := &Server{ghc: &fghc{fullRepo: tc.fullRepo}}
+			got, err := s.mergeMethod(dir, &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, MergeSHA: tc.sha, Commits: tc.commits})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected merge method %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCherryPickArgs(t *testing.T) {
+	t.Parallel()
+	for method, want := range map[github.PullRequestMergeType][]string{
+		github.MergeMerge:  {"cherry-pick", "-m", "1", "abc"},
+		github.MergeSquash: {"cherry-pick", "abc"},
+		github.MergeRebase: {"cherry-pick", "abc~3..abc"},
+	} {
+		if diff := cmp.Diff(want, cherryPickArgs(method, "abc", 3)); diff != "" {
+			t.Errorf("unexpected arguments for %s (-want +got):\n%s", method, diff)
+		}
+	}
+}
+
+func TestHandleCommitMode(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out stage branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"stage.go": []byte("package bar\n")}); err != nil {
+		t.Fatalf("Adding stage commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	// Two rebased commits of the PR on master.
+	for _, file := range []string{"one.go", "two.go"} {
+		if err := lg.AddCommit("foo", "bar", map[string][]byte{file: []byte("package bar\n")}); err != nil {
+			t.Fatalf("Adding commit: %v", err)
+		}
+	}
+	mergeSHA, err := lg.RevParse("foo", "bar", "HEAD")
+	if err != nil {
+		t.Fatalf("Parsing merge commit: %v", err)
+	}
+
+	ghc := &fghc{fullRepo: github.FullRepo{AllowRebaseMerge: true}}
+	s := &Server{
+		botUser:         &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:              &dirKeepingFactory{ClientFactory: c},
+		pusher:          &testPusher{},
+		ghc:             ghc,
+		commitModeRepos: sets.New("foo/bar"),
+	}
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "stage", BaseBranch: "master", Title: "This is a fix for X", MergeSHA: mergeSHA, Commits: 2}
+	if err := s.handle(logrus.WithField("test", t.Name()), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.MergeMethod != github.MergeRebase {
+		t.Errorf("expected rebase merge method, got %q", job.MergeMethod)
+	}
+	if len(ghc.prs) != 1 || job.ResultPR != ghc.prs[0].Number {
+		t.Fatalf("expected cherry-pick PR to be created, got %+v", ghc.prs)
+	}
+
+	dir := s.gc.(*dirKeepingFactory).dir
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for _, file := range []string{"stage.go", "one.go", "two.go"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			t.Errorf("expected %s to exist on the cherry-pick branch: %v", file, err)
+		}
+	}
+	if got := mustGit(t, dir, "rev-list", "--count", "stage..HEAD"); got != "2" {
+		t.Errorf("expected both commits to be cherry-picked, got %s commits", got)
+	}
+}
+
+func TestHandleCrossRepoFallback(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Checking out fix branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("// Package bar does an interesting thing.\npackage bar\n\n// Foo does a thing.\nfunc Foo(wow int) int {\n\treturn 49 + wow\n}\n")}); err != nil {
+		t.Fatalf("Adding fix commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.Merge("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Merging fix: %v", err)
+	}
+	mergeSHA, err := lg.RevParse("foo", "bar", "HEAD")
+	if err != nil {
+		t.Fatalf("Parsing merge commit: %v", err)
+	}
+
+	// The target repository does not contain the merge commit and the patch does not apply to it.
+	if err := lg.MakeFakeRepo("other", "baz"); err != nil {
+		t.Fatalf("Making fake repo: %v", err)
+	}
+	if err := lg.AddCommit("other", "baz", map[string][]byte{"bar.go": []byte("// Package bar does an interesting thing.\npackage bar\n\n// Foo does a thing in another repository.\nfunc Foo(wow int) int {\n\treturn 42 + wow\n}\n")}); err != nil {
+		t.Fatalf("Adding initial commit: %v", err)
+	}
+	if err := lg.CheckoutNewBranch("other", "baz", "stage"); err != nil {
+		t.Fatalf("Checking out stage branch: %v", err)
+	}
+
+	ghc := &fghc{patch: patch}
+	s := &Server{
+		botUser: &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:      &dirKeepingFactory{ClientFactory: c},
+		pusher:  &testPusher{},
+		ghc:     ghc,
+	}
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetOrg: "other", TargetRepo: "baz", TargetBranch: "stage", BaseBranch: "master", Title: "This is a fix for X", MergeSHA: mergeSHA}
+	if err := s.handle(logrus.WithField("test", t.Name()), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.prs) != 1 || job.ResultPR != ghc.prs[0].Number {
+		t.Fatalf("expected cherry-pick PR to be created, got %+v", ghc.prs)
+	}
+
+	dir := s.gc.(*dirKeepingFactory).dir
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	content, err := os.ReadFile(filepath.Join(dir, "bar.go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "// Foo does a thing in another repository.\nfunc Foo(wow int) int {\n\treturn 49 + wow\n}\n"; !strings.HasSuffix(string(content), want) {
+		t.Errorf("expected merge commit to be cherry-picked onto the target repository, got %q", string(content))
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+const (
+	// commitStatusContextPrefix is the prefix of the contexts of the commit statuses reporting cherry-picks,
+	// which are followed by the target branch.
+	commitStatusContextPrefix = "cherrypick/"
+	// maxStatusDescription is the maximum number of characters of the description of a commit status accepted by GitHub.
+	maxStatusDescription = 140
+)
+
+// commitStatus returns the commit status which reports the state of the job on the merge commit of its PR.
+func (j *cherryPickJob) commitStatus() github.Status {
+	status := github.Status{Context: commitStatusContextPrefix + j.target()}
+	switch j.State {
+	case jobStateSucceeded:
+		status.State = github.StatusSuccess
+		status.Description = "Cherry-pick completed"
+		if j.ResultPR != 0 {
+			status.Description = fmt.Sprintf("Cherry-pick PR %s opened", j.targetRef(j.ResultPR))
+			if j.ResultMerged {
+				status.Description = fmt.Sprintf("Cherry-pick PR %s merged", j.targetRef(j.ResultPR))
+			}
+		}
+	case jobStateConflicted:
+		status.State = github.StatusFailure
+		status.Description = "Cherry-pick has conflicts"
+		if j.ResultPR != 0 {
+			status.Description = fmt.Sprintf("Cherry-pick has conflicts, resolve them in draft PR %s", j.targetRef(j.ResultPR))
+		}
+	case jobStateFailed:
+		status.State = github.StatusFailure
+		status.Description = "Cherry-pick failed: " + j.Reason
+	case jobStateQueued:
+		status.State = github.StatusPending
+		status.Description = fmt.Sprintf("Cherry-pick attempt %d failed, retrying", j.Attempts)
+	default:
+		status.State = github.StatusPending
+		status.Description = "Cherry-pick in progress"
+	}
+	// Truncate by characters, so that multi-byte characters of the reason are not split.
+	if description := []rune(status.Description); len(description) > maxStatusDescription {
+		status.Description = string(description[:maxStatusDescription-3]) + "..."
+	}
+	if j.ResultPR != 0 {
+		targetOrg, targetRepo := j.targetOrgRepo()
+		status.TargetURL = fmt.Sprintf("https://github.com/%s/%s/pull/%d", targetOrg, targetRepo, j.ResultPR)
+	}
+	return status
+}
+
+// reportCommitStatus sets the commit status of the job on the merge commit of its PR, if commit
+// statuses are enabled for the repository. Jobs of open PRs are not reported, as they have no merge commit yet.
+func (s *Server) reportCommitStatus(log logrus.FieldLogger, job *cherryPickJob) {
+	if job.MergeSHA == "" || !s.settings(job.Org, job.Repo).commitStatus {
+		return
+	}
+	if err := s.ghc.CreateStatus(job.Org, job.Repo, job.MergeSHA, job.commitStatus()); err != nil {
+		log.WithError(err).WithField("sha", job.MergeSHA).Warn("Failed to set commit status of cherry-pick.")
+	}
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestCommitStatus(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		job      cherryPickJob
+		expected github.Status
+	}{
+		{
+			name:     "running",
+			job:      cherryPickJob{TargetBranch: "release-v1.2", State: jobStateRunning},
+			expected: github.Status{Context: "cherrypick/release-v1.2", State: github.StatusPending, Description: "Cherry-pick in progress"},
+		},
+		{
+			name:     "retrying",
+			job:      cherryPickJob{TargetBranch: "release-v1.2", State: jobStateQueued, Attempts: 1},
+			expected: github.Status{Context: "cherrypick/release-v1.2", State: github.StatusPending, Description: "Cherry-pick attempt 1 failed, retrying"},
+		},
+		{
+			name: "succeeded",
+			job:  cherryPickJob{Org: "foo", Repo: "bar", TargetBranch: "release-v1.2", State: jobStateSucceeded, ResultPR: 5},
+			expected: github.Status{Context: "cherrypick/release-v1.2", State: github.StatusSuccess, Description: "Cherry-pick PR #5 opened",
+				TargetURL: "https://github.com/foo/bar/pull/5"},
+		},
+		{
+			name: "merged in other repository",
+			job:  cherryPickJob{Org: "foo", Repo: "bar", TargetOrg: "baz", TargetRepo: "qux", TargetBranch: "release-v1.2", State: jobStateSucceeded, ResultPR: 5, ResultMerged: true},
+			expected: github.Status{Context: "cherrypick/baz/qux:release-v1.2", State: github.StatusSuccess, Description: "Cherry-pick PR baz/qux#5 merged",
+				TargetURL: "https://github.com/baz/qux/pull/5"},
+		},
+		{
+			name:     "conflicted",
+			job:      cherryPickJob{TargetBranch: "release-v1.2", State: jobStateConflicted},
+			expected: github.Status{Context: "cherrypick/release-v1.2", State: github.StatusFailure, Description: "Cherry-pick has conflicts"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tc.expected, tc.job.commitStatus()); diff != "" {
+				t.Errorf("unexpected status (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	failed := cherryPickJob{TargetBranch: "release-v1.2", State: jobStateFailed, Reason: strings.Repeat("x", 200)}
+	if got := failed.commitStatus(); got.State != github.StatusFailure || len(got.Description) != maxStatusDescription {
+		t.Errorf("expected failure with truncated description, got %+v", got)
+	}
+
+	failed.Reason = strings.Repeat("ä", 200)
+	got := failed.commitStatus()
+	if !utf8.ValidString(got.Description) || utf8.RuneCountInString(got.Description) != maxStatusDescription {
+		t.Errorf("expected description truncated to %d characters, got %q", maxStatusDescription, got.Description)
+	}
+}
+
+func TestRunJobCommitStatus(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{}
+	s := &Server{
+		ghc:          ghc,
+		botUser:      &github.UserData{Login: "ci-robot"},
+		jobs:         js,
+		maxAttempts:  3,
+		commitStatus: true,
+	}
+
+	// EnsureFork fails for the "error" repository, which is reported on the PR.
+	job := &cherryPickJob{Org: "foo", Repo: "error", Number: 1, TargetBranch: "stage", BaseBranch: "master", MergeSHA: "abcdef"}
+	if err := s.runJob(logrus.WithField("test", t.Name()), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var states []string
+	for _, status := range ghc.statuses {
+		if status.Context != "cherrypick/stage" {
+			t.Errorf("unexpected context %q", status.Context)
+		}
+		states = append(states, status.State)
+	}
+	if diff := cmp.Diff([]string{github.StatusPending, github.StatusFailure}, states); diff != "" {
+		t.Errorf("unexpected commit states (-want +got):\n%s", diff)
+	}
+
+	// Jobs of open PRs have no merge commit to report on.
+	ghc.statuses = nil
+	job = &cherryPickJob{Org: "foo", Repo: "error", Number: 2, TargetBranch: "stage", BaseBranch: "master"}
+	if err := s.runJob(logrus.WithField("test", t.Name()), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.statuses) != 0 {
+		t.Errorf("expected no commit status without merge commit, got %v", ghc.statuses)
+	}
+}
@@ -0,0 +1,356 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// pluginConfig is the configuration file of the cherrypicker. The settings of an org override the default
+// settings and the settings of a repository override the settings of its org. Unset settings fall
+// back to the flags of the plugin.
+type pluginConfig struct {
+	Default overrides            `json:"default,omitempty"`
+	Orgs    map[string]orgConfig `json:"orgs,omitempty"`
+}
+
+type orgConfig struct {
+	overrides
+	Repos map[string]overrides `json:"repos,omitempty"`
+}
+
+// overrides are the settings which can be configured per org and repository.
+type overrides struct {
+	// Labels are applied to the cherry-pick PRs.
+	Labels []string `json:"labels,omitempty"`
+	// AllowAll allows anybody to request cherry-picks.
+	AllowAll *bool `json:"allowAll,omitempty"`
+	// OnlyOrgMembers allows only org members to request cherry-picks. Otherwise, collaborators are allowed too.
+	OnlyOrgMembers *bool `json:"onlyOrgMembers,omitempty"`
+	// IssueOnConflict creates an issue on cherry-pick conflicts.
+	IssueOnConflict *bool `json:"issueOnConflict,omitempty"`
+	// DraftOnConflict opens a draft PR with the conflict markers on cherry-pick conflicts.
+	DraftOnConflict *bool `json:"draftOnConflict,omitempty"`
+	// LabelPrefix is the prefix of labels requesting cherry-picks.
+	LabelPrefix *string `json:"labelPrefix,omitempty"`
+	// ProwAssignments assigns the cherry-pick PRs to the requestor.
+	ProwAssignments *bool `json:"prowAssignments,omitempty"`
+	// CherryPickCommits cherry-picks the merged commits instead of applying the PR patch.
+	CherryPickCommits *bool `json:"cherryPickCommits,omitempty"`
+	// TargetBranchPattern is a regular expression which target branches must match.
+	TargetBranchPattern *string `json:"targetBranchPattern,omitempty"`
+	// TitleTemplate is the Go template of the cherry-pick PR title, see titleData.
+	TitleTemplate *string `json:"titleTemplate,omitempty"`
+	// BodyTemplate is the Go template of the cherry-pick PR body, see bodyData.
+	BodyTemplate *string `json:"bodyTemplate,omitempty"`
+	// SignOff adds a Signed-off-by trailer of the bot to the cherry-picked commits.
+	SignOff *bool `json:"signOff,omitempty"`
+	// ResetAuthor makes the bot the author of the cherry-picked commits. Otherwise, the original authors are kept.
+	ResetAuthor *bool `json:"resetAuthor,omitempty"`
+	// CoAuthors adds Co-authored-by trailers for all commit authors of the PR.
+	CoAuthors *bool `json:"coAuthors,omitempty"`
+	// CherryPickedFrom adds a `(cherry picked from commit <sha>)` line to the cherry-picked commits.
+	CherryPickedFrom *bool `json:"cherryPickedFrom,omitempty"`
+	// CommitStatus reports the state of each cherry-pick as commit status on the merge commit of the PR.
+	CommitStatus *bool `json:"commitStatus,omitempty"`
+	// IssueTemplate is the Go template of the body of issues for failed cherry-picks, see issueData.
+	IssueTemplate *string `json:"issueTemplate,omitempty"`
+	// IssueLabels are applied to issues for failed cherry-picks in addition to the kind and area labels of the PR.
+	IssueLabels []string `json:"issueLabels,omitempty"`
+	// AutoCherryPick cherry-picks merged PRs automatically onto the latest release branches.
+	AutoCherryPick *autoCherryPick `json:"autoCherryPick,omitempty"`
+	// ReleaseNotes are the rules which transform the release notes of cherry-pick PRs.
+	ReleaseNotes *releaseNoteRules `json:"releaseNotes,omitempty"`
+
+	// The compiled pattern and templates are set by compile when the configuration is loaded.
+	targetBranchPattern *regexp.Regexp
+	titleTemplate       *template.Template
+	bodyTemplate        *template.Template
+	issueTemplate       *template.Template
+}
+
+// titleData is passed to the title template.
+type titleData struct {
+	Org          string
+	Repo         string
+	Number       int
+	TargetBranch string
+	BaseBranch   string
+	// Title is the title of the original PR without the indicator of its base branch.
+	Title string
+}
+
+// bodyData is passed to the body template.
+type bodyData struct {
+	titleData
+	Author    string
+	Requestor string
+	// Body is the generated body of the cherry-pick PR, including the release note.
+	Body string
+}
+
+// repoSettings are the effective settings of a repository.
+type repoSettings struct {
+	labels              []string
+	allowAll            bool
+	onlyOrgMembers      bool
+	issueOnConflict     bool
+	draftOnConflict     bool
+	labelPrefix         string
+	prowAssignments     bool
+	cherryPickCommits   bool
+	targetBranchPattern *regexp.Regexp
+	signOff             bool
+	resetAuthor         bool
+	coAuthors           bool
+	cherryPickedFrom    bool
+	commitStatus        bool
+	titleTemplate       *template.Template
+	bodyTemplate        *template.Template
+	issueTemplate       *template.Template
+	issueLabels         []string
+	autoCherryPick      *autoCherryPick
+	releaseNoteRules    *releaseNoteRules
+}
+
+// apply overrides the settings with the set fields of o. The pattern and templates must have been
+// compiled before.
+func (rs *repoSettings) apply(o overrides) {
+	if o.Labels != nil {
+		rs.labels = o.Labels
+	}
+	overrideBool := func(target *bool, value *bool) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	overrideBool(&rs.allowAll, o.AllowAll)
+	overrideBool(&rs.onlyOrgMembers, o.OnlyOrgMembers)
+	overrideBool(&rs.issueOnConflict, o.IssueOnConflict)
+	overrideBool(&rs.draftOnConflict, o.DraftOnConflict)
+	overrideBool(&rs.prowAssignments, o.ProwAssignments)
+	overrideBool(&rs.cherryPickCommits, o.CherryPickCommits)
+	overrideBool(&rs.signOff, o.SignOff)
+	overrideBool(&rs.resetAuthor, o.ResetAuthor)
+	overrideBool(&rs.coAuthors, o.CoAuthors)
+	overrideBool(&rs.cherryPickedFrom, o.CherryPickedFrom)
+	overrideBool(&rs.commitStatus, o.CommitStatus)
+	if o.LabelPrefix != nil {
+		rs.labelPrefix = *o.LabelPrefix
+	}
+	if o.TargetBranchPattern != nil {
+		// An empty pattern resets the pattern of the flags.
+		rs.targetBranchPattern = o.targetBranchPattern
+	}
+	if o.TitleTemplate != nil {
+		rs.titleTemplate = o.titleTemplate
+	}
+	if o.BodyTemplate != nil {
+		rs.bodyTemplate = o.bodyTemplate
+	}
+	if o.IssueTemplate != nil {
+		rs.issueTemplate = o.issueTemplate
+	}
+	if o.IssueLabels != nil {
+		rs.issueLabels = o.IssueLabels
+	}
+	if o.AutoCherryPick != nil {
+		rs.autoCherryPick = o.AutoCherryPick
+	}
+	if o.ReleaseNotes != nil {
+		rs.releaseNoteRules = o.ReleaseNotes
+	}
+}
+
+// compile validates the settings and compiles the pattern and templates, so that they are not compiled
+// again whenever the settings are applied.
+func (o *overrides) compile() error {
+	if o.LabelPrefix != nil && *o.LabelPrefix == "" {
+		return fmt.Errorf("labelPrefix must not be empty")
+	}
+	if o.TargetBranchPattern != nil && *o.TargetBranchPattern != "" {
+		pattern, err := regexp.Compile(*o.TargetBranchPattern)
+		if err != nil {
+			return fmt.Errorf("invalid targetBranchPattern: %w", err)
+		}
+		o.targetBranchPattern = pattern
+	}
+	var err error
+	if o.TitleTemplate != nil {
+		if o.titleTemplate, err = template.New("title").Parse(*o.TitleTemplate); err != nil {
+			return fmt.Errorf("invalid titleTemplate: %w", err)
+		}
+	}
+	if o.BodyTemplate != nil {
+		if o.bodyTemplate, err = template.New("body").Parse(*o.BodyTemplate); err != nil {
+			return fmt.Errorf("invalid bodyTemplate: %w", err)
+		}
+	}
+	if o.IssueTemplate != nil {
+		if o.issueTemplate, err = template.New("issue").Parse(*o.IssueTemplate); err != nil {
+			return fmt.Errorf("invalid issueTemplate: %w", err)
+		}
+	}
+	if o.AutoCherryPick != nil {
+		if err := o.AutoCherryPick.compile(); err != nil {
+			return fmt.Errorf("invalid autoCherryPick: %w", err)
+		}
+	}
+	if o.ReleaseNotes != nil {
+		if err := o.ReleaseNotes.compile(); err != nil {
+			return fmt.Errorf("invalid releaseNotes: %w", err)
+		}
+	}
+	return nil
+}
+
+// compile validates and compiles the settings of the default, all orgs and all repositories.
+func (c *pluginConfig) compile() error {
+	if err := c.Default.compile(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for org, orgConfig := range c.Orgs {
+		if err := orgConfig.overrides.compile(); err != nil {
+			return fmt.Errorf("orgs.%s: %w", org, err)
+		}
+		for repo, repoConfig := range orgConfig.Repos {
+			if err := repoConfig.compile(); err != nil {
+				return fmt.Errorf("orgs.%s.repos.%s: %w", org, repo, err)
+			}
+			orgConfig.Repos[repo] = repoConfig
+		}
+		c.Orgs[org] = orgConfig
+	}
+	return nil
+}
+
+// configAgent loads the configuration file and reloads it if it changes.
+type configAgent struct {
+	path string
+
+	lock   sync.RWMutex
+	raw    []byte
+	config *pluginConfig
+}
+
+// newConfigAgent creates a configAgent and loads the configuration file at path.
+func newConfigAgent(path string) (*configAgent, error) {
+	ca := &configAgent{path: path}
+	if _, err := ca.reload(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// reload loads the configuration file again. It returns true if the configuration changed. An invalid
+// configuration is rejected and the previous configuration is kept.
+func (ca *configAgent) reload() (bool, error) {
+	raw, err := os.ReadFile(ca.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config %s: %w", ca.path, err)
+	}
+
+	ca.lock.RLock()
+	unchanged := ca.config != nil && bytes.Equal(raw, ca.raw)
+	ca.lock.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	c := &pluginConfig{}
+	if err := yaml.UnmarshalStrict(raw, c); err != nil {
+		return false, fmt.Errorf("failed to parse config %s: %w", ca.path, err)
+	}
+	if err := c.compile(); err != nil {
+		return false, fmt.Errorf("invalid config %s: %w", ca.path, err)
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.raw, ca.config = raw, c
+	return true, nil
+}
+
+// get returns the current configuration.
+func (ca *configAgent) get() *pluginConfig {
+	if ca == nil {
+		return &pluginConfig{}
+	}
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	return ca.config
+}
+
+// settings returns the effective settings of the given repository. The flags of the plugin are
+// overridden by the default, org and repo settings of the configuration file.
+func (s *Server) settings(org, repo string) repoSettings {
+	rs := repoSettings{
+		labels:              s.labels,
+		allowAll:            s.allowAll,
+		onlyOrgMembers:      s.onlyOrgMembers,
+		issueOnConflict:     s.issueOnConflict,
+		draftOnConflict:     s.draftOnConflict,
+		labelPrefix:         s.labelPrefix,
+		prowAssignments:     s.prowAssignments,
+		cherryPickCommits:   s.commitModeRepos.Has(org) || s.commitModeRepos.Has(org+"/"+repo),
+		targetBranchPattern: s.targetBranchPattern,
+		signOff:             s.signOff,
+		resetAuthor:         s.resetAuthor,
+		coAuthors:           s.coAuthors,
+		cherryPickedFrom:    s.cherryPickedFrom,
+		commitStatus:        s.commitStatus,
+	}
+	c := s.config.get()
+	rs.apply(c.Default)
+	if orgConfig, ok := c.Orgs[org]; ok {
+		rs.apply(orgConfig.overrides)
+		if repoConfig, ok := orgConfig.Repos[repo]; ok {
+			rs.apply(repoConfig)
+		}
+	}
+	return rs
+}
+
+// titleData returns the data for the title template of the job.
+func (j *cherryPickJob) titleData() titleData {
+	return titleData{
+		Org:          j.Org,
+		Repo:         j.Repo,
+		Number:       j.Number,
+		TargetBranch: j.TargetBranch,
+		BaseBranch:   j.BaseBranch,
+		Title:        omitBaseBranchFromTitle(j.Title, j.BaseBranch),
+	}
+}
+
+// title returns the title of the cherry-pick PR.
+func (rs repoSettings) title(data titleData) string {
+	if rs.titleTemplate != nil {
+		var b strings.Builder
+		if err := rs.titleTemplate.Execute(&b, data); err == nil {
+			return b.String()
+		}
+	}
+	return fmt.Sprintf(titleTargetBranchIndicatorTemplate, data.TargetBranch) + data.Title
+}
+
+// body returns the body of the cherry-pick PR.
+func (rs repoSettings) body(data bodyData) string {
+	if rs.bodyTemplate != nil {
+		var b strings.Builder
+		if err := rs.bodyTemplate.Execute(&b, data); err == nil {
+			return b.String()
+		}
+	}
+	return data.Body
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const testConfig = `default:
+  labels:
+  - cherry-pick
+  issueOnConflict: true
+orgs:
+  gardener:
+    onlyOrgMembers: true
+    targetBranchPattern: '^release-v\d+\.\d+$'
+    titleTemplate: '[{{.TargetBranch}}] {{.Title}} (cherry-pick of #{{.Number}})'
+    repos:
+      gardener:
+        labels: []
+        cherryPickCommits: true
+        bodyTemplate: "{{.Body}}\n\nRequested by @{{.Requestor}}."
+`
+
+// mustCompileConfig returns the compiled config with the given default settings.
+func mustCompileConfig(t *testing.T, defaults overrides) *pluginConfig {
+	t.Helper()
+	cfg := &pluginConfig{Default: defaults}
+	if err := cfg.compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg
+}
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := newConfigAgent(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{
+		config:          ca,
+		labels:          []string{"flag-label"},
+		labelPrefix:     defaultLabelPrefix,
+		prowAssignments: true,
+		commitModeRepos: sets.New("other/commits"),
+	}
+
+	other := s.settings("other", "repo")
+	if len(other.labels) != 1 || other.labels[0] != "cherry-pick" || !other.issueOnConflict || other.onlyOrgMembers || other.targetBranchPattern != nil || other.cherryPickCommits {
+		t.Errorf("unexpected default settings: %+v", other)
+	}
+	if !other.prowAssignments || other.labelPrefix != defaultLabelPrefix {
+		t.Errorf("expected flags to be used for unset settings, got %+v", other)
+	}
+	if !s.settings("other", "commits").cherryPickCommits {
+		t.Error("expected commit mode from flags")
+	}
+
+	data := titleData{Org: "gardener", Repo: "dashboard", Number: 42, TargetBranch: "release-v1.2", Title: "Fix X"}
+	org := s.settings("gardener", "dashboard")
+	if !org.onlyOrgMembers || !org.targetBranchPattern.MatchString("release-v1.2") || org.targetBranchPattern.MatchString("master") {
+		t.Errorf("unexpected org settings: %+v", org)
+	}
+	if got, want := org.title(data), "[release-v1.2] Fix X (cherry-pick of #42)"; got != want {
+		t.Errorf("expected title %q, got %q", want, got)
+	}
+	if got, want := org.body(bodyData{titleData: data, Body: "body"}), "body"; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+
+	repo := s.settings("gardener", "gardener")
+	if len(repo.labels) != 0 || !repo.cherryPickCommits || !repo.onlyOrgMembers {
+		t.Errorf("unexpected repo settings: %+v", repo)
+	}
+	if got, want := repo.body(bodyData{titleData: data, Requestor: "wiseguy", Body: "body"}), "body\n\nRequested by @wiseguy."; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+	if got, want := s.settings("other", "repo").title(data), "[release-v1.2] Fix X"; got != want {
+		t.Errorf("expected default title %q, got %q", want, got)
+	}
+}
+
+func TestConfigAgentReload(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("default:\n  allowAll: true\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := newConfigAgent(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{config: ca}
+
+	if changed, err := ca.reload(); err != nil || changed {
+		t.Errorf("expected unchanged config without error, got %t, %v", changed, err)
+	}
+
+	for _, invalid := range []string{
+		"default:\n  unknown: true\n",
+		"orgs:\n  gardener:\n    targetBranchPattern: '('\n",
+		"default:\n  autoCherryPick:\n    labels: [kind/bug]\n    branchPattern: '('\n    latestBranches: 1\n",
+		"default:\n  releaseNotes:\n    patchBranchPattern: '('\n",
+		"orgs:\n  gardener:\n    repos:\n      gardener:\n        titleTemplate: '{{.Title'\n",
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := ca.reload(); err == nil {
+			t.Errorf("expected error for invalid config %q, but did not get one", invalid)
+		}
+		if !s.settings("gardener", "gardener").allowAll {
+			t.Error("expected previous config to be kept")
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("default:\n  allowAll: false\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed, err := ca.reload(); err != nil || !changed {
+		t.Errorf("expected changed config without error, got %t, %v", changed, err)
+	}
+	if s.settings("gardener", "gardener").allowAll {
+		t.Error("expected reloaded config to be used")
+	}
+}
@@ -0,0 +1,220 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	cherrypicker "sigs.k8s.io/prow/cmd/external-plugins/cherrypicker/lib"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// runGit runs git with the given arguments in dir. The prow git client does not expose all commands
+// required for cherry-picking, hence they are run directly in the directory of the cloned repository.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// cherryPickMergeCommit cherry-picks the merged commits of a PR onto the checked out branch. It is the
+// fallback for patches which do not apply with `git am`. The merge method is determined like in commit
+// mode, so that all commits of rebase merges are cherry-picked. If the cherry-pick conflicts, the
+// conflicting files are returned together with the error and the conflict markers are left in the
+// working tree.
+func (s *Server) cherryPickMergeCommit(dir string, job *cherryPickJob) ([]string, error) {
+	if job.MergeSHA == "" {
+		return nil, errors.New("merge commit of the PR is unknown")
+	}
+	method, err := s.mergeMethod(dir, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine merge method: %w", err)
+	}
+	job.MergeMethod = method
+	return cherryPick(dir, cherryPickArgs(method, job.MergeSHA, job.Commits)...)
+}
+
+// cherryPick runs `git cherry-pick` with the given arguments. If it conflicts, the conflicting files
+// are returned together with the error and the conflict markers are left in the working tree. Other
+// failures are aborted.
+func cherryPick(dir string, args ...string) ([]string, error) {
+	if _, err := runGit(dir, args...); err != nil {
+		conflicts, diffErr := conflictingFiles(dir)
+		if diffErr != nil || len(conflicts) == 0 {
+			_, _ = runGit(dir, "cherry-pick", "--abort")
+			return nil, err
+		}
+		return conflicts, err
+	}
+	return nil, nil
+}
+
+// conflictingFiles returns the unmerged files in the working tree.
+func conflictingFiles(dir string) ([]string, error) {
+	out, err := runGit(dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// commitConflicts commits the working tree including conflict markers, so that the conflicts can be
+// resolved in a draft PR.
+func commitConflicts(dir, message string) error {
+	if _, err := runGit(dir, "add", "--all"); err != nil {
+		return err
+	}
+	_, err := runGit(dir, "commit", "--no-verify", "-m", message)
+	return err
+}
+
+// resolutionCommands returns the commands to redo a conflicting cherry-pick locally.
+func resolutionCommands(job *cherryPickJob, newBranch string, conflicts []string) string {
+	files := "<conflicting files>"
+	if len(conflicts) > 0 {
+		files = strings.Join(conflicts, " ")
+	}
+	var b strings.Builder
+	targetOrg, targetRepo := job.targetOrgRepo()
+	fmt.Fprintf(&b, "git fetch https://github.com/%s/%s.git %s\n", targetOrg, targetRepo, job.TargetBranch)
+	fmt.Fprintf(&b, "git checkout -b %s FETCH_HEAD\n", newBranch)
+	continueCmd := "git am --continue"
+	if job.MergeMethod != "" {
+		fmt.Fprintf(&b, "git fetch https://github.com/%s/%s.git %s\n", job.Org, job.Repo, job.MergeSHA)
+		fmt.Fprintf(&b, "git %s\n", strings.Join(cherryPickArgs(job.MergeMethod, job.MergeSHA, job.Commits), " "))
+		continueCmd = "git cherry-pick --continue"
+	} else {
+		fmt.Fprintf(&b, "curl -sSL https://github.com/%s/%s/pull/%d.patch | git am -3\n", job.Org, job.Repo, job.Number)
+	}
+	b.WriteString("# resolve the conflicts, then\n")
+	fmt.Fprintf(&b, "git add %s\n", files)
+	fmt.Fprintf(&b, "%s\n", continueCmd)
+	fmt.Fprintf(&b, "git push <your-fork> %s\n", newBranch)
+	return b.String()
+}
+
+// handleConflict reports a PR which could neither be applied as patch nor by cherry-picking its
+// commits. If enabled, the conflict markers are pushed and opened as draft PR against the target branch.
+// Only failures with unmerged paths are conflicts, the returned error wraps errConflict for them. Other
+// failures, e.g. a missing object, are retried.
+func (s *Server) handleConflict(logger logrus.FieldLogger, job *cherryPickJob, r git.RepoClient, p pusher, pushOrg, newBranch, title string, applyErr error, conflicts []string) error {
+	org, repo, num := job.Org, job.Repo, job.Number
+	settings := s.settings(org, repo)
+	if len(conflicts) == 0 {
+		logger.WithError(applyErr).Warn("failed to cherry-pick PR on top of target branch")
+		resp := fmt.Sprintf("failed to cherry-pick #%d on top of branch %q:\n```\n%v\n```", num, job.target(), applyErr)
+		return utilerrors.NewAggregate([]error{applyErr, s.createRetriedFailureComment(logger, job, resp)})
+	}
+	errs := []error{fmt.Errorf("%w: failed to apply PR: %w", errConflict, applyErr)}
+	logger.WithError(applyErr).WithField("conflicts", conflicts).Warn("failed to apply PR on top of target branch")
+
+	resp := fmt.Sprintf("#%d failed to apply on top of branch %q:\n```\n%v\n```", num, job.target(), applyErr)
+	resp += "\n\nCherry-picking the merged commits conflicts in the following files:\n"
+	for _, file := range conflicts {
+		resp += fmt.Sprintf("- `%s`\n", file)
+	}
+	if settings.draftOnConflict {
+		draftNum, err := s.createConflictPR(logger, job, r, p, pushOrg, newBranch, title, conflicts)
+		if err != nil {
+			logger.WithError(err).Warn("failed to create draft pull request with conflicts")
+			errs = append(errs, fmt.Errorf("failed to create draft pull request: %w", err))
+		} else {
+			job.ResultPR = draftNum
+			resp += fmt.Sprintf("\nI opened the draft PR %s which contains the conflict markers. Resolve them there and mark the PR as ready for review.\n", job.targetRef(draftNum))
+		}
+	}
+	commands := resolutionCommands(job, newBranch, conflicts)
+	resp += fmt.Sprintf("\nTo resolve the conflicts locally, run:\n```sh\n%s```", commands)
+
+	if err := s.createComment(logger, org, repo, num, job.Comment, resp); err != nil {
+		errs = append(errs, fmt.Errorf("failed to create comment: %w", err))
+	}
+
+	if settings.issueOnConflict {
+		if err := s.createFailureIssue(logger, job, title, applyErr, conflicts, commands); err != nil {
+			errs = append(errs, fmt.Errorf("failed to create issue: %w", err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// createConflictPR commits and pushes the conflict markers and opens a draft PR for them.
+func (s *Server) createConflictPR(logger logrus.FieldLogger, job *cherryPickJob, r git.RepoClient, p pusher, pushOrg, newBranch, title string, conflicts []string) (int, error) {
+	org, repo := job.Org, job.Repo
+	targetOrg, targetRepo := job.targetOrgRepo()
+	message := fmt.Sprintf("%s\n\nCherry-pick of %s with unresolved conflicts in:\n%s", title, job.originRef(), strings.Join(conflicts, "\n"))
+	if err := commitConflicts(r.Directory(), message); err != nil {
+		return 0, fmt.Errorf("failed to commit conflicts: %w", err)
+	}
+	if err := p.Push(r, newBranch, true); err != nil {
+		return 0, fmt.Errorf("failed to push conflicts: %w", err)
+	}
+
+	settings := s.settings(org, repo)
+	requestor := ""
+	if settings.prowAssignments {
+		requestor = job.Requestor
+	}
+	body := fmt.Sprintf("**This cherry-pick has unresolved conflicts in the following files. Resolve the conflict markers before marking the PR as ready for review.**\n\n- `%s`\n\n%s",
+		strings.Join(conflicts, "`\n- `"), cherrypicker.CreateCherrypickBody(job.Number, requestor, "", job.ChainBranches, nil))
+	body = settings.body(job.crossRepoBody(bodyData{
+		titleData: job.titleData(),
+		Author:    job.Author,
+		Requestor: job.Requestor,
+		Body:      body,
+	}))
+
+	createdNum, err := s.ghc.CreatePullRequest(targetOrg, targetRepo, title, body, prHead(targetOrg, pushOrg, newBranch), job.TargetBranch, true)
+	if err != nil {
+		return 0, err
+	}
+	logger = logger.WithField("new_pull_request_number", createdNum)
+	logger.Info("new draft pull request with conflicts created")
+
+	createdPR, err := s.ghc.GetPullRequest(targetOrg, targetRepo, createdNum)
+	if err != nil {
+		logger.WithError(err).Warn("failed to get pull request with conflicts, it is not converted to draft")
+		return createdNum, nil
+	}
+	if err := s.convertToDraft(targetOrg, createdPR); err != nil {
+		logger.WithError(err).Warn("failed to convert pull request with conflicts to draft")
+	}
+	return createdNum, nil
+}
+
+// convertToDraft converts the PR of the given org to a draft. The GitHub client cannot create draft
+// PRs, hence PRs are converted after their creation.
+func (s *Server) convertToDraft(org string, pr *github.PullRequest) error {
+	var m struct {
+		ConvertPullRequestToDraft struct {
+			PullRequest struct {
+				IsDraft githubql.Boolean
+			}
+		} `graphql:"convertPullRequestToDraft(input: $input)"`
+	}
+	input := githubql.ConvertPullRequestToDraftInput{PullRequestID: githubql.ID(pr.NodeID)}
+	return s.ghc.MutateWithGitHubAppsSupport(context.Background(), &m, input, nil, org)
+}
+
+// prHead returns the head reference for a PR from the given branch in pushOrg.
+func prHead(org, pushOrg, branch string) string {
+	if pushOrg == org {
+		return branch
+	}
+	return fmt.Sprintf("%s:%s", pushOrg, branch)
+}
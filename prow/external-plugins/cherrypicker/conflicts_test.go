@@ -0,0 +1,206 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/git/localgit"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return strings.TrimSpace(out)
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mustGit(t, dir, "add", name)
+	mustGit(t, dir, "commit", "-m", "change "+name)
+}
+
+func TestCherryPickMergeCommit(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		rebase        bool
+		targetContent string
+		wantConflicts []string
+		wantErr       bool
+		wantContent   string
+		wantMethod    github.PullRequestMergeType
+	}{
+		{
+			name:          "merge commit applies",
+			targetContent: "z\na\nb\nc\n",
+			wantContent:   "z\na\nb\nC\n",
+			wantMethod:    github.MergeMerge,
+		},
+		{
+			name:          "merge commit conflicts",
+			targetContent: "a\nb\nX\n",
+			wantConflicts: []string{"file"},
+			wantErr:       true,
+			wantMethod:    github.MergeMerge,
+		},
+		{
+			name:          "all rebased commits apply",
+			rebase:        true,
+			targetContent: "z\na\nb\nc\n",
+			wantContent:   "z\na\nb\nC\n",
+			wantMethod:    github.MergeRebase,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			mustGit(t, dir, "init", "-b", "master")
+			mustGit(t, dir, "config", "user.email", "test@test.test")
+			mustGit(t, dir, "config", "user.name", "test test")
+			commitFile(t, dir, "file", "a\nb\nc\n")
+
+			mustGit(t, dir, "checkout", "-b", "fix")
+			commits := 1
+			if tc.rebase {
+				commitFile(t, dir, "second", "second\n")
+				commits++
+			}
+			commitFile(t, dir, "file", "a\nb\nC\n")
+			if tc.rebase {
+				mustGit(t, dir, "checkout", "-b", "rebased", "fix")
+				mustGit(t, dir, "checkout", "master")
+				commitFile(t, dir, "other", "other\n")
+				mustGit(t, dir, "rebase", "master", "rebased")
+			} else {
+				mustGit(t, dir, "checkout", "master")
+				commitFile(t, dir, "other", "other\n")
+				mustGit(t, dir, "merge", "--no-ff", "-m", "merge fix", "fix")
+			}
+			mergeSHA := mustGit(t, dir, "rev-parse", "HEAD")
+
+			mustGit(t, dir, "checkout", "-b", "target", "master")
+			mustGit(t, dir, "reset", "--hard", "HEAD~1")
+			commitFile(t, dir, "file", tc.targetContent)
+
+			s := &Server{ghc: &fghc{fullRepo: github.FullRepo{AllowRebaseMerge: true}}}
+			job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, MergeSHA: mergeSHA, Commits: commits}
+			conflicts, err := s.cherryPickMergeCommit(dir, job)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %t, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.wantConflicts, conflicts); diff != "" {
+				t.Errorf("unexpected conflicts (-want +got):\n%s", diff)
+			}
+			if job.MergeMethod != tc.wantMethod {
+				t.Errorf("expected merge method %q, got %q", tc.wantMethod, job.MergeMethod)
+			}
+			if tc.wantContent == "" {
+				return
+			}
+			content, err := os.ReadFile(filepath.Join(dir, "file"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(content) != tc.wantContent {
+				t.Errorf("expected content %q, got %q", tc.wantContent, string(content))
+			}
+			if _, err := os.Stat(filepath.Join(dir, "second")); tc.rebase && err != nil {
+				t.Errorf("expected all rebased commits to be cherry-picked: %v", err)
+			}
+		})
+	}
+
+	s := &Server{ghc: &fghc{}}
+	if _, err := s.cherryPickMergeCommit(t.TempDir(), &cherryPickJob{}); err == nil {
+		t.Error("expected error for unknown merge commit, but did not get one")
+	}
+}
+
+func TestHandleConflictDraftPR(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out pull branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("package bar\n\n// Foo was changed on stage.\nfunc Foo(wow int) int {\n\treturn 7 * wow\n}\n")}); err != nil {
+		t.Fatalf("Adding conflicting commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.CheckoutNewBranch("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Checking out fix branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("// Package bar does an interesting thing.\npackage bar\n\n// Foo does a thing.\nfunc Foo(wow int) int {\n\t// Needs to be 49 because of a reason.\n\treturn 49 + wow\n}\n")}); err != nil {
+		t.Fatalf("Adding fix commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.Merge("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Merging fix: %v", err)
+	}
+	mergeSHA, err := lg.RevParse("foo", "bar", "HEAD")
+	if err != nil {
+		t.Fatalf("Parsing merge commit: %v", err)
+	}
+
+	ghc := &fghc{patch: patch}
+	s := &Server{
+		botUser:         &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:              c,
+		pusher:          &testPusher{},
+		ghc:             ghc,
+		draftOnConflict: true,
+	}
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "stage", BaseBranch: "master", Requestor: "wiseguy", Title: "This is a fix for X", MergeSHA: mergeSHA}
+	if err := s.handle(logrus.WithField("test", t.Name()), job); !errors.Is(err, errConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	if len(ghc.prs) != 1 {
+		t.Fatalf("expected one draft PR, got %d", len(ghc.prs))
+	}
+	draft := ghc.prs[0]
+	if draft.Base.Ref != "stage" || draft.Head.Ref != "ci-robot:cherry-pick-2-to-stage" {
+		t.Errorf("unexpected draft PR %s <- %s", draft.Base.Ref, draft.Head.Ref)
+	}
+	if !strings.Contains(draft.Body, "- `bar.go`") {
+		t.Errorf("expected draft PR body to list the conflicting file, got %q", draft.Body)
+	}
+	if job.ResultPR != draft.Number {
+		t.Errorf("expected job result to be the draft PR #%d, got #%d", draft.Number, job.ResultPR)
+	}
+	if len(ghc.mutations) != 1 {
+		t.Errorf("expected draft PR to be converted to draft, got %d mutations", len(ghc.mutations))
+	}
+
+	if len(ghc.comments) != 1 {
+		t.Fatalf("expected one comment, got %v", ghc.comments)
+	}
+	for _, want := range []string{"- `bar.go`", "draft PR #1", "git checkout -b cherry-pick-2-to-stage FETCH_HEAD", "git add bar.go"} {
+		if !strings.Contains(ghc.comments[0], want) {
+			t.Errorf("expected comment to contain %q, got %q", want, ghc.comments[0])
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// duplicateSearchWindow is how long before the cherry-pick request merged PRs are considered as
+	// existing cherry-picks.
+	duplicateSearchWindow = 30 * 24 * time.Hour
+	// maxDuplicateCandidates limits the number of PRs whose diff is compared with the original PR.
+	maxDuplicateCandidates = 10
+)
+
+// existingCherryPick is a PR against the target branch which already cherry-picks the original PR.
+type existingCherryPick struct {
+	Number  int
+	HTMLURL string
+}
+
+// isCherryPickOf returns true if the title or body of a PR carry one of the markers of the cherry-picks of
+// the plugin for the job: the title which the plugin would use, the `This is an automated cherry-pick of #123`
+// line of the body, or a `(cherry picked from commit <sha>)` line of the merge commit of the original PR.
+// Other mentions of the original PR, e.g. `follow-up to #123`, do not count.
+func isCherryPickOf(title, body string, job *cherryPickJob, cherryPickTitle string) bool {
+	if title == cherryPickTitle {
+		return true
+	}
+	re := regexp.MustCompile(fmt.Sprintf(`(?m)^This is an automated cherry-pick of %s\b`, regexp.QuoteMeta(job.originRef())))
+	if re.MatchString(body) {
+		return true
+	}
+	if len(job.MergeSHA) >= 7 {
+		for _, m := range cherryPickedFromRe.FindAllStringSubmatch(body, -1) {
+			if strings.HasPrefix(job.MergeSHA, m[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cherryPickedFromRe matches the `(cherry picked from commit <sha>)` line which `git cherry-pick -x` adds.
+var cherryPickedFromRe = regexp.MustCompile(`\(cherry picked from commit ([0-9a-f]{7,40})\)`)
+
+// patchID returns the stable patch-id of the diff, which is independent of line numbers and
+// whitespace. It is empty if the diff does not contain any changes.
+func patchID(diff []byte) (string, error) {
+	cmd := exec.Command("git", "patch-id", "--stable")
+	cmd.Stdin = bytes.NewReader(diff)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git patch-id failed: %w", err)
+	}
+	if fields := strings.Fields(string(out)); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", nil
+}
+
+// findExistingCherryPick searches the open PRs and the recently merged PRs against the target
+// branch of the job for a cherry-pick of the original PR. Besides the cherry-picks of the plugin,
+// this finds manual backports which were created with `git cherry-pick -x` or have the same patch-id.
+// Only PRs against the target branch are compared, and at most maxDuplicateCandidates patch-ids.
+func (s *Server) findExistingCherryPick(logger logrus.FieldLogger, job *cherryPickJob) (*existingCherryPick, error) {
+	org, repo, num := job.Org, job.Repo, job.Number
+	targetOrg, targetRepo := job.targetOrgRepo()
+	otherRepo := job.cherryPickTarget().crossRepo()
+	cherryPickTitle := s.settings(org, repo).title(job.titleData())
+
+	open, err := s.ghc.GetPullRequests(targetOrg, targetRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pullrequests for %s/%s: %w", targetOrg, targetRepo, err)
+	}
+	var candidates []existingCherryPick
+	check := func(number int, htmlURL, title, body string) bool {
+		if number == num && !otherRepo {
+			return false
+		}
+		if isCherryPickOf(title, body, job, cherryPickTitle) {
+			return true
+		}
+		candidates = append(candidates, existingCherryPick{Number: number, HTMLURL: htmlURL})
+		return false
+	}
+	for _, pr := range open {
+		if pr.Base.Ref != job.TargetBranch {
+			continue
+		}
+		if check(pr.Number, pr.HTMLURL, pr.Title, pr.Body) {
+			return &existingCherryPick{Number: pr.Number, HTMLURL: pr.HTMLURL}, nil
+		}
+	}
+
+	since := job.CreatedAt
+	if since.IsZero() {
+		since = time.Now()
+	}
+	// The search only returns PRs against the target branch.
+	query := fmt.Sprintf("repo:%s/%s is:pr is:merged base:%s merged:>=%s", targetOrg, targetRepo, job.TargetBranch, since.Add(-duplicateSearchWindow).Format("2006-01-02"))
+	merged, err := s.ghc.FindIssuesWithOrg(targetOrg, query, "updated", false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search merged pullrequests: %w", err)
+	}
+	for _, issue := range merged {
+		if check(issue.Number, issue.HTMLURL, issue.Title, issue.Body) {
+			return &existingCherryPick{Number: issue.Number, HTMLURL: issue.HTMLURL}, nil
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	diff, err := s.ghc.GetPullRequestDiff(org, repo, num)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to get diff of the PR, skipping comparison of patch-ids.")
+		return nil, nil
+	}
+	original, err := patchID(diff)
+	if err != nil || original == "" {
+		logger.WithError(err).Warn("Failed to compute patch-id of the PR, skipping comparison of patch-ids.")
+		return nil, nil
+	}
+	for i, candidate := range candidates {
+		if i == maxDuplicateCandidates {
+			break
+		}
+		diff, err := s.ghc.GetPullRequestDiff(targetOrg, targetRepo, candidate.Number)
+		if err != nil {
+			logger.WithError(err).WithField("candidate", candidate.Number).Debug("Failed to get diff of PR.")
+			continue
+		}
+		if id, err := patchID(diff); err == nil && id == original {
+			return &candidate, nil
+		}
+	}
+	return nil, nil
+}
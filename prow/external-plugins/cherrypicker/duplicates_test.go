@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestIsCherryPickOf(t *testing.T) {
+	t.Parallel()
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 12, TargetBranch: "stage", MergeSHA: "0123456789abcdef"}
+	crossRepoJob := &cherryPickJob{Org: "foo", Repo: "bar", Number: 12, TargetOrg: "other", TargetRepo: "baz", TargetBranch: "stage"}
+	testCases := []struct {
+		title    string
+		body     string
+		job      *cherryPickJob
+		expected bool
+	}{
+		{title: "[stage] Fix X", job: job, expected: true},
+		{body: "This is an automated cherry-pick of #12\n\n/assign alice", job: job, expected: true},
+		{body: "Fix X\n\n(cherry picked from commit 0123456)", job: job, expected: true},
+		{body: "This is an automated cherry-pick of foo/bar#12", job: crossRepoJob, expected: true},
+		{title: "[release] Fix X", job: job},
+		{body: "This is an automated cherry-pick of #123", job: job},
+		{body: "This is an automated cherry-pick of #12", job: crossRepoJob},
+		{body: "Follow-up to #12", job: job},
+		{body: "Backport of https://github.com/foo/bar/pull/12", job: job},
+		{body: "(cherry picked from commit 0123457)", job: job},
+	}
+	for _, tc := range testCases {
+		if got := isCherryPickOf(tc.title, tc.body, tc.job, "[stage] Fix X"); got != tc.expected {
+			t.Errorf("expected %t for title %q and body %q, got %t", tc.expected, tc.title, tc.body, got)
+		}
+	}
+}
+
+func TestFindExistingCherryPick(t *testing.T) {
+	t.Parallel()
+	log := logrus.WithField("test", t.Name())
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "stage", MergeSHA: "abcdef0123", CreatedAt: time.Now()}
+	openPR := func(number int, base, body string) github.PullRequest {
+		pr := github.PullRequest{Number: number, Body: body}
+		pr.Base.Ref = base
+		return pr
+	}
+
+	testCases := []struct {
+		name      string
+		prs       []github.PullRequest
+		mergedPRs []github.Issue
+		diff      []byte
+		expected  int
+	}{
+		{
+			name: "no cherry-picks",
+			prs:  []github.PullRequest{openPR(3, "stage", "Fix Y")},
+		},
+		{
+			name:     "open cherry-pick of the original PR",
+			prs:      []github.PullRequest{openPR(3, "master", "This is an automated cherry-pick of #2"), openPR(4, "stage", "This is an automated cherry-pick of #2")},
+			expected: 4,
+		},
+		{
+			name: "open PR mentioning the original PR",
+			prs:  []github.PullRequest{openPR(3, "stage", "Follow-up to #2")},
+		},
+		{
+			name:      "merged manual cherry-pick of the original PR",
+			mergedPRs: []github.Issue{{Number: 5, Body: "Fix the magic number\n\n(cherry picked from commit abcdef0)"}},
+			expected:  5,
+		},
+		{
+			name:      "merged PR with identical patch",
+			mergedPRs: []github.Issue{{Number: 6, Body: "Fix the magic number"}},
+			diff:      patch,
+			expected:  6,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := &Server{ghc: &fghc{prs: tc.prs, mergedPRs: tc.mergedPRs, diff: tc.diff}}
+			existing, err := s.findExistingCherryPick(log, job)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := 0
+			if existing != nil {
+				got = existing.Number
+			}
+			if got != tc.expected {
+				t.Errorf("expected existing cherry-pick #%d, got #%d", tc.expected, got)
+			}
+		})
+	}
+}
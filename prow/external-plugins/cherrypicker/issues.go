@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// issueLabelPrefixes are the prefixes of labels which are copied from the original PR to failure issues.
+var issueLabelPrefixes = []string{"kind/", "area/"}
+
+// issueData is passed to the issue template.
+type issueData struct {
+	titleData
+	Author    string
+	Requestor string
+	// Conflicts are the conflicting files, if any.
+	Conflicts []string
+	// Commands are the shell commands to redo the cherry-pick locally.
+	Commands string
+	// Error is the error message of the failed cherry-pick.
+	Error string
+}
+
+var defaultIssueTemplate = template.Must(template.New("issue").Parse(`Manual cherry-pick required: {{.Org}}/{{.Repo}}#{{.Number}} failed to apply on top of branch ` + "`{{.TargetBranch}}`" + `.
+
+| | |
+| --- | --- |
+| Original PR | {{.Org}}/{{.Repo}}#{{.Number}} |
+| Base branch | ` + "`{{.BaseBranch}}`" + ` |
+| Target branch | ` + "`{{.TargetBranch}}`" + ` |
+| Requested by | @{{.Requestor}} |
+{{- if .Conflicts}}
+
+Conflicting files:
+{{range .Conflicts}}
+- ` + "`{{.}}`" + `
+{{- end}}
+{{- end}}
+
+To cherry-pick the PR locally, run:
+` + "```sh\n{{.Commands}}```" + `
+
+<details><summary>Error message</summary>
+
+` + "```\n{{.Error}}\n```" + `
+</details>
+`))
+
+// issueBody returns the body of a failure issue.
+func (rs repoSettings) issueBody(data issueData) string {
+	if rs.issueTemplate != nil {
+		var b strings.Builder
+		if err := rs.issueTemplate.Execute(&b, data); err == nil {
+			return b.String()
+		}
+	}
+	var b strings.Builder
+	_ = defaultIssueTemplate.Execute(&b, data)
+	return b.String()
+}
+
+// failureIssueLabels returns the labels of a failure issue, which are the configured labels and the
+// kind and area labels of the original PR.
+func (rs repoSettings) failureIssueLabels(prLabels []github.Label) []string {
+	labels := slices.Clone(rs.issueLabels)
+	for _, label := range prLabels {
+		for _, prefix := range issueLabelPrefixes {
+			if strings.HasPrefix(label.Name, prefix) && !slices.Contains(labels, label.Name) {
+				labels = append(labels, label.Name)
+			}
+		}
+	}
+	return labels
+}
+
+// targetMilestone returns the number of the open milestone which matches the version of the target
+// branch, e.g. v1.10 for release-v1.10. It returns 0 if there is no such milestone.
+func (s *Server) targetMilestone(org, repo, targetBranch string) (int, error) {
+	version := versionNumbers(targetBranch)
+	if len(version) == 0 {
+		return 0, nil
+	}
+	milestones, err := s.ghc.ListMilestones(org, repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list milestones of %s/%s: %w", org, repo, err)
+	}
+	number := 0
+	for _, milestone := range milestones {
+		if milestone.State == "closed" {
+			continue
+		}
+		numbers := versionNumbers(milestone.Title)
+		if slices.Equal(numbers, version) {
+			return milestone.Number, nil
+		}
+		// e.g. v1.10.0 for release-v1.10, if there is no v1.10 milestone.
+		if number == 0 && len(numbers) > len(version) && slices.Equal(numbers[:len(version)], version) {
+			number = milestone.Number
+		}
+	}
+	return number, nil
+}
+
+// createFailureIssue creates an issue for a cherry-pick which needs to be done manually.
+func (s *Server) createFailureIssue(logger logrus.FieldLogger, job *cherryPickJob, title string, failure error, conflicts []string, commands string) error {
+	org, repo := job.Org, job.Repo
+	settings := s.settings(org, repo)
+
+	milestone, err := s.targetMilestone(org, repo, job.TargetBranch)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to determine milestone of failure issue.")
+	}
+	body := settings.issueBody(issueData{
+		titleData: job.titleData(),
+		Author:    job.Author,
+		Requestor: job.Requestor,
+		Conflicts: conflicts,
+		Commands:  commands,
+		Error:     failure.Error(),
+	})
+	return s.createIssue(logger, org, repo, title, body, job.Number, job.Comment, settings.failureIssueLabels(job.Labels), []string{job.Requestor}, milestone)
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestTargetMilestone(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{milestones: []github.Milestone{
+		{Title: "v1.9", Number: 1, State: "closed"},
+		{Title: "v1.10.0", Number: 2, State: "open"},
+		{Title: "v1.10", Number: 3, State: "open"},
+		{Title: "v1.9.1", Number: 4, State: "open"},
+	}}
+	s := &Server{ghc: ghc}
+
+	for branch, expected := range map[string]int{
+		"release-v1.10": 3,
+		"release-v1.9":  4,
+		"release-v1.8":  0,
+		"master":        0,
+	} {
+		if got, err := s.targetMilestone("foo", "bar", branch); err != nil || got != expected {
+			t.Errorf("expected milestone %d for %s, got %d, %v", expected, branch, got, err)
+		}
+	}
+}
+
+func TestCreateFailureIssue(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{milestones: []github.Milestone{{Title: "v1.10", Number: 7, State: "open"}}}
+	s := &Server{ghc: ghc, issueOnConflict: true}
+	job := &cherryPickJob{
+		Org:          "foo",
+		Repo:         "bar",
+		Number:       2,
+		TargetBranch: "release-v1.10",
+		BaseBranch:   "master",
+		Requestor:    "wiseguy",
+		Title:        "Fix X",
+		Labels:       []github.Label{{Name: "kind/bug"}, {Name: "lgtm"}, {Name: "area/quality"}},
+	}
+
+	if err := s.createFailureIssue(logrus.WithField("test", t.Name()), job, "[release-v1.10] Fix X", errors.New("patch does not apply"), []string{"bar.go"}, "git cherry-pick abc\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.issues) != 1 {
+		t.Fatalf("expected one issue, got %d", len(ghc.issues))
+	}
+	issue := ghc.issues[0]
+	if issue.Title != "[release-v1.10] Fix X" || issue.Milestone.Number != 7 {
+		t.Errorf("unexpected issue title %q or milestone %d", issue.Title, issue.Milestone.Number)
+	}
+	var labels []string
+	for _, label := range issue.Labels {
+		labels = append(labels, label.Name)
+	}
+	if diff := cmp.Diff([]string{"kind/bug", "area/quality"}, labels); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+	for _, expected := range []string{
+		"| Original PR | foo/bar#2 |",
+		"| Target branch | `release-v1.10` |",
+		"| Requested by | @wiseguy |",
+		"Conflicting files:\n\n- `bar.go`\n",
+		"```sh\ngit cherry-pick abc\n```",
+		"```\npatch does not apply\n```",
+	} {
+		if !strings.Contains(issue.Body, expected) {
+			t.Errorf("expected issue body to contain %q, got:\n%s", expected, issue.Body)
+		}
+	}
+}
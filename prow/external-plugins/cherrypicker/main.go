@@ -17,18 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/config"
 	"sigs.k8s.io/prow/pkg/config/secret"
 	prowflagutil "sigs.k8s.io/prow/pkg/flagutil"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
 	"sigs.k8s.io/prow/pkg/interrupts"
 	"sigs.k8s.io/prow/pkg/logrusutil"
+	"sigs.k8s.io/prow/pkg/metrics"
 	"sigs.k8s.io/prow/pkg/pjutil"
 	"sigs.k8s.io/prow/pkg/pluginhelp/externalplugins"
 )
@@ -47,7 +54,32 @@ type options struct {
 	allowAll          bool
 	onlyOrgMembers    bool
 	issueOnConflict   bool
+	draftOnConflict   bool
 	labelPrefix       string
+	trustedUsersTTL   time.Duration
+	signOff           bool
+	resetAuthor       bool
+	coAuthors         bool
+	cherryPickedFrom  bool
+	commitStatus      bool
+
+	configPath          string
+	targetBranchPattern string
+	commitModeRepos     prowflagutil.Strings
+
+	queuePath    string
+	maxAttempts  int
+	retryBackoff time.Duration
+
+	workers         int
+	queueSize       int
+	shutdownTimeout time.Duration
+
+	replayEvent        string
+	replayPayload      string
+	replayGitDir       string
+	replayTrustedUsers prowflagutil.Strings
+	replayGitHubTrust  bool
 }
 
 func (o *options) Validate() error {
@@ -56,6 +88,18 @@ func (o *options) Validate() error {
 			return fmt.Errorf("%d: %w", idx, err)
 		}
 	}
+	if o.maxAttempts < 1 {
+		return fmt.Errorf("--max-attempts must be at least 1, got %d", o.maxAttempts)
+	}
+	if o.workers < 1 {
+		return fmt.Errorf("--workers must be at least 1, got %d", o.workers)
+	}
+	if o.queueSize < 0 {
+		return fmt.Errorf("--queue-size must not be negative, got %d", o.queueSize)
+	}
+	if _, err := regexp.Compile(o.targetBranchPattern); err != nil {
+		return fmt.Errorf("invalid --target-branch-pattern: %w", err)
+	}
 
 	return nil
 }
@@ -72,7 +116,28 @@ func gatherOptions() options {
 	fs.BoolVar(&o.allowAll, "allow-all", false, "Allow anybody to use automated cherrypicks by skipping GitHub organization membership checks.")
 	fs.BoolVar(&o.onlyOrgMembers, "only-org-members", false, "Allow only users with a GitHub organization membership to use automated cherrypicks. Otherwise, collaborators are allowed to use it too.")
 	fs.BoolVar(&o.issueOnConflict, "create-issue-on-conflict", false, "Create a GitHub issue and assign it to the requestor on cherrypick conflict.")
+	fs.BoolVar(&o.draftOnConflict, "draft-pr-on-conflict", false, "Push the conflict markers and open a draft PR on cherrypick conflict.")
 	fs.StringVar(&o.labelPrefix, "label-prefix", defaultLabelPrefix, "Set a custom label prefix.")
+	fs.BoolVar(&o.signOff, "sign-off", false, "Add a Signed-off-by trailer of the bot to the cherry-picked commits.")
+	fs.BoolVar(&o.resetAuthor, "reset-author", false, "Make the bot the author of the cherry-picked commits. Otherwise, the original authors are kept.")
+	fs.BoolVar(&o.coAuthors, "co-authored-by", false, "Add Co-authored-by trailers for all commit authors of the PR to the cherry-picked commits.")
+	fs.BoolVar(&o.cherryPickedFrom, "cherry-picked-from", false, "Add a '(cherry picked from commit <sha>)' line to the cherry-picked commits.")
+	fs.BoolVar(&o.commitStatus, "commit-status", false, "Report the state of each cherry-pick as commit status 'cherrypick/<branch>' on the merge commit of the PR.")
+	fs.DurationVar(&o.trustedUsersTTL, "trusted-users-cache-ttl", 5*time.Minute, "Time for which org members and collaborators are cached. It is the only guaranteed invalidation, member, membership and organization events invalidate the cache earlier if hook delivers them. 0 disables the cache.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to the YAML configuration file with per-org and per-repo settings. The file is reloaded when it changes.")
+	fs.StringVar(&o.targetBranchPattern, "target-branch-pattern", "", "Regular expression which cherry-pick target branches must match, e.g. '^release-v[0-9]+\\.[0-9]+$'. If empty, all existing branches are allowed.")
+	fs.Var(&o.commitModeRepos, "cherry-pick-commits", "Org or org/repo for which the merged commits are cherry-picked instead of applying the PR patch. The commits are picked according to the merge method of the PR. Can be passed multiple times.")
+	fs.StringVar(&o.queuePath, "queue-path", "", "Path to the file in which cherry-pick jobs are persisted. If empty, jobs are only kept in memory and are lost on restart.")
+	fs.IntVar(&o.maxAttempts, "max-attempts", 3, "Maximum number of attempts for a cherry-pick before it is considered as failed.")
+	fs.DurationVar(&o.retryBackoff, "retry-backoff", time.Minute, "Delay before a failed cherry-pick is retried. It doubles with every further attempt.")
+	fs.IntVar(&o.workers, "workers", 8, "Maximum number of webhook events and retries which are handled concurrently.")
+	fs.IntVar(&o.queueSize, "queue-size", 100, "Maximum number of webhook events which wait for a free worker. Further events are rejected.")
+	fs.DurationVar(&o.shutdownTimeout, "shutdown-timeout", 2*time.Minute, "Time to wait for in-flight cherry-picks to finish on shutdown. Keep it below the termination grace period of the pod.")
+	fs.StringVar(&o.replayPayload, "replay-payload", "", "Path to a recorded webhook payload. If set, the payload is replayed against a fake GitHub and the local repositories in --replay-git-dir, the actions of the plugin are printed and the plugin exits.")
+	fs.StringVar(&o.replayEvent, "replay-event", "issue_comment", "Event type of the replayed payload, either issue_comment or pull_request.")
+	fs.StringVar(&o.replayGitDir, "replay-git-dir", ".", "Directory with the local repositories for a replay, as <org>/<repo>.")
+	fs.Var(&o.replayTrustedUsers, "replay-trusted-user", "User who is an org member and collaborator during a replay. Can be passed multiple times. All other users are untrusted.")
+	fs.BoolVar(&o.replayGitHubTrust, "replay-github-trust", false, "Answer the org membership and collaborator checks of a replay with the GitHub client of the --github-* flags instead of --replay-trusted-user.")
 	for _, group := range []prowflagutil.OptionGroup{&o.github, &o.instrumentationOptions} {
 		group.AddFlags(fs)
 	}
@@ -100,6 +165,13 @@ func main() {
 	logrus.SetLevel(logLevel)
 	log := logrus.StandardLogger().WithField("plugin", pluginName)
 
+	if o.replayPayload != "" {
+		if err := replay(log, o, os.Stdout); err != nil {
+			log.WithError(err).Fatal("Error replaying webhook payload.")
+		}
+		return
+	}
+
 	if err := secret.Add(o.webhookSecretFile); err != nil {
 		logrus.WithError(err).Fatal("Error starting secrets agent.")
 	}
@@ -112,12 +184,6 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting Git client.")
 	}
-	interrupts.OnInterrupt(func() {
-		if err := gitClient.Clean(); err != nil {
-			logrus.WithError(err).Error("Could not clean up git client cache.")
-		}
-	})
-
 	email, err := githubClient.Email()
 	if err != nil {
 		log.WithError(err).Fatal("Error getting bot e-mail.")
@@ -128,33 +194,113 @@ func main() {
 		logrus.WithError(err).Fatal("Error getting bot name.")
 	}
 
-	server := &Server{
-		tokenGenerator: secret.GetTokenGenerator(o.webhookSecretFile),
-		botUser:        botUser,
-		email:          email,
+	server, err := newServer(o, log, &instrumentedGitHub{ghc: githubClient}, gitClient, botUser, email)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating server.")
+	}
+	server.tokenGenerator = secret.GetTokenGenerator(o.webhookSecretFile)
+	server.bare = &http.Client{}
+	server.patchURL = "https://patch-diff.githubusercontent.com"
+	if cfg := server.config; cfg != nil {
+		interrupts.TickLiteral(func() {
+			changed, err := cfg.reload()
+			if err != nil {
+				log.WithError(err).Error("Error reloading config, keeping previous config.")
+			} else if changed {
+				log.Info("Reloaded config.")
+			}
+		}, time.Minute)
+	}
 
-		gc:  gitClient,
-		ghc: githubClient,
-		log: log,
+	// Drain the in-flight cherry-picks before cleaning up the git client cache, which they use.
+	interrupts.OnInterrupt(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+		defer cancel()
+		if err := server.workers.stop(ctx); err != nil {
+			log.WithError(err).Warn("Shutting down before all in-flight cherry-picks finished.")
+		}
+		if err := gitClient.Clean(); err != nil {
+			logrus.WithError(err).Error("Could not clean up git client cache.")
+		}
+	})
 
-		labels:          o.labels.Strings(),
-		prowAssignments: o.prowAssignments,
-		allowAll:        o.allowAll,
-		onlyOrgMembers:  o.onlyOrgMembers,
-		issueOnConflict: o.issueOnConflict,
-		labelPrefix:     o.labelPrefix,
+	// Pick up jobs which were interrupted by a restart and retry failed ones.
+	server.resumeJobs(log)
+	interrupts.TickLiteral(func() {
+		server.runDueJobs(log)
+	}, 30*time.Second)
 
-		bare:     &http.Client{},
-		patchURL: "https://patch-diff.githubusercontent.com",
-	}
+	metrics.ExposeMetrics("cherrypicker", config.PushGateway{}, o.instrumentationOptions.MetricsPort)
 
 	health := pjutil.NewHealthOnPort(o.instrumentationOptions.HealthPort)
 	health.ServeReady()
 
 	mux := http.NewServeMux()
 	mux.Handle("/", server)
+	mux.HandleFunc("/status", server.ServeStatus)
 	externalplugins.ServeExternalPluginHelp(mux, log, HelpProvider)
 	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
 	defer interrupts.WaitForGracefulShutdown()
 	interrupts.ListenAndServe(httpServer, 5*time.Second)
 }
+
+// newServer creates a server with the settings of the options for the given clients. The plugin and
+// replays share it, so that a replay behaves like the deployed plugin.
+func newServer(o options, log logrus.FieldLogger, ghc githubClient, gc git.ClientFactory, botUser *github.UserData, email string) (*Server, error) {
+	jobs, err := newJobStore(o.queuePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cherry-pick jobs: %w", err)
+	}
+
+	var cfg *configAgent
+	if o.configPath != "" {
+		cfg, err = newConfigAgent(o.configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config: %w", err)
+		}
+	}
+
+	var trustedUsers *trustCache
+	if o.trustedUsersTTL > 0 {
+		trustedUsers = newTrustCache(o.trustedUsersTTL)
+	}
+
+	var targetBranchPattern *regexp.Regexp
+	if o.targetBranchPattern != "" {
+		targetBranchPattern = regexp.MustCompile(o.targetBranchPattern)
+	}
+
+	return &Server{
+		botUser: botUser,
+		email:   email,
+
+		gc:  gc,
+		ghc: ghc,
+		log: log,
+
+		labels:          o.labels.Strings(),
+		prowAssignments: o.prowAssignments,
+		allowAll:        o.allowAll,
+		onlyOrgMembers:  o.onlyOrgMembers,
+		issueOnConflict: o.issueOnConflict,
+		draftOnConflict: o.draftOnConflict,
+		labelPrefix:     o.labelPrefix,
+		trustedUsers:    trustedUsers,
+		config:          cfg,
+
+		targetBranchPattern: targetBranchPattern,
+		commitModeRepos:     sets.New(o.commitModeRepos.Strings()...),
+
+		signOff:          o.signOff,
+		resetAuthor:      o.resetAuthor,
+		coAuthors:        o.coAuthors,
+		cherryPickedFrom: o.cherryPickedFrom,
+		commitStatus:     o.commitStatus,
+
+		jobs:         jobs,
+		maxAttempts:  o.maxAttempts,
+		retryBackoff: o.retryBackoff,
+
+		workers: newWorkerPool(o.workers, o.queueSize),
+	}, nil
+}
@@ -0,0 +1,234 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	githubql "github.com/shurcooL/githubv4"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// Triggers of cherry-pick requests.
+const (
+	triggerComment = "comment"
+	triggerLabel   = "label"
+)
+
+// Outcomes of cherry-pick requests.
+const (
+	outcomeSuccess       = "success"
+	outcomeConflict      = "conflict"
+	outcomePushFailure   = "push_failure"
+	outcomeUntrustedUser = "untrusted_user"
+	outcomeInvalidBranch = "invalid_branch"
+	outcomeError         = "error"
+)
+
+// Phases of a cherry-pick whose duration is measured.
+const (
+	phaseClone = "clone"
+	phaseApply = "apply"
+	phasePush  = "push"
+)
+
+// errPush is returned by handle if the cherry-pick branch could not be pushed.
+var errPush = errors.New("push failed")
+
+var cherryPickMetrics = struct {
+	requests      *prometheus.CounterVec
+	phaseDuration *prometheus.HistogramVec
+	githubErrors  *prometheus.CounterVec
+}{
+	requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cherrypicker_requests_total",
+		Help: "Number of cherry-pick requests by trigger and final outcome.",
+	}, []string{"trigger", "outcome"}),
+	phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cherrypicker_phase_duration_seconds",
+		Help:    "Duration of the successful clone, apply and push phases of cherry-picks.",
+		Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300},
+	}, []string{"phase"}),
+	githubErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cherrypicker_github_api_errors_total",
+		Help: "Number of failed GitHub API calls by method.",
+	}, []string{"method"}),
+}
+
+func init() {
+	prometheus.MustRegister(cherryPickMetrics.requests)
+	prometheus.MustRegister(cherryPickMetrics.phaseDuration)
+	prometheus.MustRegister(cherryPickMetrics.githubErrors)
+}
+
+// recordRequest counts a cherry-pick request with its final outcome.
+func recordRequest(comment *github.IssueComment, outcome string) {
+	trigger := triggerComment
+	if comment == nil {
+		trigger = triggerLabel
+	}
+	cherryPickMetrics.requests.WithLabelValues(trigger, outcome).Inc()
+}
+
+// observePhase records the duration of a cherry-pick phase which started at start.
+func observePhase(phase string, start time.Time) {
+	cherryPickMetrics.phaseDuration.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+// jobOutcome returns the outcome of a cherry-pick job after the given attempt.
+func jobOutcome(job *cherryPickJob, err error) string {
+	switch {
+	case job.Outcome != "":
+		return job.Outcome
+	case errors.Is(err, errConflict):
+		return outcomeConflict
+	case errors.Is(err, errPush):
+		return outcomePushFailure
+	case err != nil || job.Reason != "":
+		return outcomeError
+	default:
+		return outcomeSuccess
+	}
+}
+
+// instrumentedGitHub counts the failed calls of the wrapped GitHub client.
+type instrumentedGitHub struct {
+	ghc githubClient
+}
+
+// countError counts err as a failed call of the given method, if it is set.
+func countError(method string, err error) error {
+	if err != nil {
+		cherryPickMetrics.githubErrors.WithLabelValues(method).Inc()
+	}
+	return err
+}
+
+func (i *instrumentedGitHub) AddLabel(org, repo string, number int, label string) error {
+	return countError("AddLabel", i.ghc.AddLabel(org, repo, number, label))
+}
+
+func (i *instrumentedGitHub) AssignIssue(org, repo string, number int, logins []string) error {
+	return countError("AssignIssue", i.ghc.AssignIssue(org, repo, number, logins))
+}
+
+func (i *instrumentedGitHub) CreateComment(org, repo string, number int, comment string) error {
+	return countError("CreateComment", i.ghc.CreateComment(org, repo, number, comment))
+}
+
+func (i *instrumentedGitHub) CreateFork(org, repo string) (string, error) {
+	name, err := i.ghc.CreateFork(org, repo)
+	return name, countError("CreateFork", err)
+}
+
+func (i *instrumentedGitHub) CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error) {
+	number, err := i.ghc.CreatePullRequest(org, repo, title, body, head, base, canModify)
+	return number, countError("CreatePullRequest", err)
+}
+
+func (i *instrumentedGitHub) CreateStatus(org, repo, SHA string, s github.Status) error {
+	return countError("CreateStatus", i.ghc.CreateStatus(org, repo, SHA, s))
+}
+
+func (i *instrumentedGitHub) CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error) {
+	number, err := i.ghc.CreateIssue(org, repo, title, body, milestone, labels, assignees)
+	return number, countError("CreateIssue", err)
+}
+
+func (i *instrumentedGitHub) EnsureFork(forkingUser, org, repo string) (string, error) {
+	name, err := i.ghc.EnsureFork(forkingUser, org, repo)
+	return name, countError("EnsureFork", err)
+}
+
+func (i *instrumentedGitHub) EditPullRequest(org, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error) {
+	edited, err := i.ghc.EditPullRequest(org, repo, number, pr)
+	return edited, countError("EditPullRequest", err)
+}
+
+func (i *instrumentedGitHub) GetPullRequest(org, repo string, number int) (*github.PullRequest, error) {
+	pr, err := i.ghc.GetPullRequest(org, repo, number)
+	return pr, countError("GetPullRequest", err)
+}
+
+func (i *instrumentedGitHub) GetPullRequestPatch(org, repo string, number int) ([]byte, error) {
+	patch, err := i.ghc.GetPullRequestPatch(org, repo, number)
+	return patch, countError("GetPullRequestPatch", err)
+}
+
+func (i *instrumentedGitHub) GetPullRequestDiff(org, repo string, number int) ([]byte, error) {
+	diff, err := i.ghc.GetPullRequestDiff(org, repo, number)
+	return diff, countError("GetPullRequestDiff", err)
+}
+
+func (i *instrumentedGitHub) ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error) {
+	commits, err := i.ghc.ListPRCommits(org, repo, number)
+	return commits, countError("ListPRCommits", err)
+}
+
+func (i *instrumentedGitHub) GetPullRequests(org, repo string) ([]github.PullRequest, error) {
+	prs, err := i.ghc.GetPullRequests(org, repo)
+	return prs, countError("GetPullRequests", err)
+}
+
+func (i *instrumentedGitHub) FindIssuesWithOrg(org, query, sort string, asc bool) ([]github.Issue, error) {
+	issues, err := i.ghc.FindIssuesWithOrg(org, query, sort, asc)
+	return issues, countError("FindIssuesWithOrg", err)
+}
+
+func (i *instrumentedGitHub) GetRepo(owner, name string) (github.FullRepo, error) {
+	repo, err := i.ghc.GetRepo(owner, name)
+	return repo, countError("GetRepo", err)
+}
+
+func (i *instrumentedGitHub) IsMember(org, user string) (bool, error) {
+	member, err := i.ghc.IsMember(org, user)
+	return member, countError("IsMember", err)
+}
+
+func (i *instrumentedGitHub) IsCollaborator(org, repo, user string) (bool, error) {
+	collaborator, err := i.ghc.IsCollaborator(org, repo, user)
+	return collaborator, countError("IsCollaborator", err)
+}
+
+func (i *instrumentedGitHub) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	comments, err := i.ghc.ListIssueComments(org, repo, number)
+	return comments, countError("ListIssueComments", err)
+}
+
+func (i *instrumentedGitHub) EditComment(org, repo string, id int, comment string) error {
+	return countError("EditComment", i.ghc.EditComment(org, repo, id, comment))
+}
+
+func (i *instrumentedGitHub) GetIssueLabels(org, repo string, number int) ([]github.Label, error) {
+	labels, err := i.ghc.GetIssueLabels(org, repo, number)
+	return labels, countError("GetIssueLabels", err)
+}
+
+func (i *instrumentedGitHub) ListOrgMembers(org, role string) ([]github.TeamMember, error) {
+	members, err := i.ghc.ListOrgMembers(org, role)
+	return members, countError("ListOrgMembers", err)
+}
+
+func (i *instrumentedGitHub) ListCollaborators(org, repo string) ([]github.User, error) {
+	users, err := i.ghc.ListCollaborators(org, repo)
+	return users, countError("ListCollaborators", err)
+}
+
+func (i *instrumentedGitHub) GetBranches(org, repo string, onlyProtected bool) ([]github.Branch, error) {
+	branches, err := i.ghc.GetBranches(org, repo, onlyProtected)
+	return branches, countError("GetBranches", err)
+}
+
+func (i *instrumentedGitHub) ListMilestones(org, repo string) ([]github.Milestone, error) {
+	milestones, err := i.ghc.ListMilestones(org, repo)
+	return milestones, countError("ListMilestones", err)
+}
+
+func (i *instrumentedGitHub) MutateWithGitHubAppsSupport(ctx context.Context, m any, input githubql.Input, vars map[string]any, org string) error {
+	return countError("MutateWithGitHubAppsSupport", i.ghc.MutateWithGitHubAppsSupport(ctx, m, input, vars, org))
+}
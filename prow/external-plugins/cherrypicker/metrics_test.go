@@ -0,0 +1,56 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestJobOutcome(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		job      cherryPickJob
+		err      error
+		expected string
+	}{
+		{name: "success", expected: outcomeSuccess},
+		{name: "conflict", err: utilerrors.NewAggregate([]error{fmt.Errorf("%w: failed to apply PR", errConflict)}), expected: outcomeConflict},
+		{name: "push failure", err: utilerrors.NewAggregate([]error{fmt.Errorf("%w: denied", errPush), nil}), expected: outcomePushFailure},
+		{name: "reported failure", job: cherryPickJob{Reason: "failed to get PR patch"}, expected: outcomeError},
+		{name: "other error", err: errors.New("failed to configure git user"), expected: outcomeError},
+		{name: "invalid branch", job: cherryPickJob{Reason: "cannot checkout", Outcome: outcomeInvalidBranch}, expected: outcomeInvalidBranch},
+	}
+	for _, tc := range testCases {
+		if got := jobOutcome(&tc.job, tc.err); got != tc.expected {
+			t.Errorf("%s: expected outcome %q, got %q", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestInstrumentedGitHub(t *testing.T) {
+	t.Parallel()
+	ghc := &instrumentedGitHub{ghc: &fghc{}}
+	errorCount := func() float64 {
+		var m dto.Metric
+		if err := cherryPickMetrics.githubErrors.WithLabelValues("GetPullRequest").Write(&m); err != nil {
+			t.Fatalf("failed to read counter: %v", err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	before := errorCount()
+
+	if _, err := ghc.GetPullRequest("foo", "bar", 2); err == nil {
+		t.Fatal("expected error for unknown PR")
+	}
+	if got := errorCount() - before; got != 1 {
+		t.Errorf("expected one counted error, got %v", got)
+	}
+}
@@ -0,0 +1,445 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+type jobState string
+
+const (
+	// jobStatePending marks a cherry-pick which was requested on an open PR and runs once the PR merges.
+	jobStatePending    jobState = "pending"
+	jobStateQueued     jobState = "queued"
+	jobStateRunning    jobState = "running"
+	jobStateSucceeded  jobState = "succeeded"
+	jobStateConflicted jobState = "conflicted"
+	jobStateFailed     jobState = "failed"
+)
+
+const (
+	// maxRetryBackoff caps the delay between two attempts of the same job.
+	maxRetryBackoff = 30 * time.Minute
+	// jobRetention is how long finished jobs are kept in the store.
+	jobRetention = 7 * 24 * time.Hour
+)
+
+// errConflict is returned by handle if the PR does not apply on top of the target branch.
+// Conflicts are reported on the PR and never retried.
+var errConflict = errors.New("cherry-pick conflict")
+
+// cherryPickJob is a single cherry-pick of a PR onto a target branch. It carries everything handle
+// needs, so that jobs can be retried and replayed after a restart of the plugin.
+type cherryPickJob struct {
+	Org          string `json:"org"`
+	Repo         string `json:"repo"`
+	Number       int    `json:"number"`
+	TargetBranch string `json:"targetBranch"`
+	// TargetOrg and TargetRepo are set if the PR is cherry-picked onto a branch of another repository.
+	TargetOrg     string               `json:"targetOrg,omitempty"`
+	TargetRepo    string               `json:"targetRepo,omitempty"`
+	BaseBranch    string               `json:"baseBranch"`
+	Author        string               `json:"author"`
+	Requestor     string               `json:"requestor"`
+	Comment       *github.IssueComment `json:"comment,omitempty"`
+	ChainBranches []string             `json:"chainBranches,omitempty"`
+	// ChainOrigin is the number of the original PR, if the job continues a chained cherry-pick.
+	ChainOrigin int            `json:"chainOrigin,omitempty"`
+	Title       string         `json:"title"`
+	Body        string         `json:"body"`
+	Labels      []github.Label `json:"labels,omitempty"`
+	MergeSHA    string         `json:"mergeSHA,omitempty"`
+	Commits     int            `json:"commits,omitempty"`
+	// MergeMethod is set if the merged commits are cherry-picked instead of the PR patch.
+	MergeMethod github.PullRequestMergeType `json:"mergeMethod,omitempty"`
+
+	State    jobState `json:"state"`
+	Attempts int      `json:"attempts"`
+	// Reason describes why the last attempt did not succeed.
+	Reason string `json:"reason,omitempty"`
+	// ResultPR is the number of the cherry-pick PR, if one was created or found.
+	ResultPR int `json:"resultPR,omitempty"`
+	// ResultMerged is set once the cherry-pick PR merged.
+	ResultMerged bool      `json:"resultMerged,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	NextAttempt  time.Time `json:"nextAttempt,omitempty"`
+
+	// Outcome overrides the outcome of the last attempt for metrics, see jobOutcome. It is not persisted.
+	Outcome string `json:"-"`
+}
+
+func (j *cherryPickJob) key() string {
+	return fmt.Sprintf("%s/%s#%d:%s", j.Org, j.Repo, j.Number, j.target())
+}
+
+func (j *cherryPickJob) finished() bool {
+	return j.State == jobStateSucceeded || j.State == jobStateConflicted || j.State == jobStateFailed
+}
+
+// fail records a failure which has already been reported on the PR and must not be retried.
+func (j *cherryPickJob) fail(reason string) {
+	j.Reason = reason
+}
+
+// jobStore keeps track of all cherry-pick jobs. If path is set, the jobs are persisted as JSON file,
+// so that pending work survives restarts of the plugin.
+type jobStore struct {
+	path string
+
+	lock sync.Mutex
+	jobs map[string]cherryPickJob
+}
+
+// newJobStore creates a jobStore and loads previously persisted jobs from path. An empty path
+// creates an in-memory store.
+func newJobStore(path string) (*jobStore, error) {
+	js := &jobStore{path: path, jobs: map[string]cherryPickJob{}}
+	if path == "" {
+		return js, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return js, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job store %s: %w", path, err)
+	}
+	var jobs []cherryPickJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse job store %s: %w", path, err)
+	}
+	for _, job := range jobs {
+		js.jobs[job.key()] = job
+	}
+	return js, nil
+}
+
+// put stores a copy of the given job and persists the store.
+func (js *jobStore) put(job cherryPickJob) error {
+	if js == nil {
+		return nil
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	js.jobs[job.key()] = job
+	return js.persist()
+}
+
+// get returns a copy of the job with the given key.
+func (js *jobStore) get(key string) (cherryPickJob, bool) {
+	if js == nil {
+		return cherryPickJob{}, false
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	job, ok := js.jobs[key]
+	return job, ok
+}
+
+// list returns all jobs ordered by creation time.
+func (js *jobStore) list() []cherryPickJob {
+	if js == nil {
+		return nil
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	jobs := make([]cherryPickJob, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].key() < jobs[j].key()
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs
+}
+
+// prune removes finished jobs which were last updated before the given time.
+func (js *jobStore) prune(before time.Time) error {
+	if js == nil {
+		return nil
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	for key, job := range js.jobs {
+		if job.finished() && job.UpdatedAt.Before(before) {
+			delete(js.jobs, key)
+		}
+	}
+	return js.persist()
+}
+
+// removePending removes the pending jobs of the given PR onto branch, e.g. if they were cancelled. An
+// empty branch removes the pending jobs onto all branches, e.g. if the PR was closed without merging.
+func (js *jobStore) removePending(org, repo string, num int, branch string) error {
+	if js == nil {
+		return nil
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	for key, job := range js.jobs {
+		if job.State == jobStatePending && job.Org == org && job.Repo == repo && job.Number == num && (branch == "" || job.target() == branch) {
+			delete(js.jobs, key)
+		}
+	}
+	return js.persist()
+}
+
+// failPending fails the pending jobs of the given PR with the given reason, so that they are pruned like
+// other finished jobs.
+func (js *jobStore) failPending(org, repo string, num int, reason string) error {
+	if js == nil {
+		return nil
+	}
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	now := time.Now()
+	for key, job := range js.jobs {
+		if job.State == jobStatePending && job.Org == org && job.Repo == repo && job.Number == num {
+			job.State = jobStateFailed
+			job.Reason = reason
+			job.UpdatedAt = now
+			js.jobs[key] = job
+		}
+	}
+	return js.persist()
+}
+
+// persist writes all jobs to the store file. The file is replaced atomically, so that a crash while
+// writing does not corrupt the store. It must be called with the lock held.
+func (js *jobStore) persist() error {
+	if js.path == "" {
+		return nil
+	}
+	jobs := make([]cherryPickJob, 0, len(js.jobs))
+	for _, job := range js.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].key() < jobs[j].key() })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(js.path), filepath.Base(js.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary job store file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write job store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write job store: %w", err)
+	}
+	return os.Rename(tmp.Name(), js.path)
+}
+
+// loggedJob is a job together with its logger.
+type loggedJob struct {
+	log logrus.FieldLogger
+	job *cherryPickJob
+}
+
+// runJobs persists the jobs as queued before it runs them one after the other, so that jobs which did not
+// run yet are resumed after a restart. A job whose retry is submitted already is left to the retry, which
+// reads the queued job from the store. It returns the errors of the jobs in their order.
+func (s *Server) runJobs(jobs []loggedJob) []error {
+	now := time.Now()
+	var run []bool
+	for _, j := range jobs {
+		j.job.State = jobStateQueued
+		j.job.CreatedAt = now
+		j.job.UpdatedAt = now
+		run = append(run, s.startRetry(j.job.key()))
+		if err := s.jobs.put(*j.job); err != nil {
+			j.log.WithError(err).Warn("Failed to persist cherry-pick job.")
+		}
+	}
+
+	errs := make([]error, len(jobs))
+	for i, j := range jobs {
+		if !run[i] {
+			j.log.Info("Leaving cherry-pick to the submitted retry.")
+			continue
+		}
+		errs[i] = s.runJob(j.log, j.job)
+		s.finishRetry(j.job.key())
+	}
+	return errs
+}
+
+// runJob runs a single attempt of the given job and records its outcome in the job store. Failed
+// attempts are scheduled for a retry until maxAttempts is reached.
+func (s *Server) runJob(log logrus.FieldLogger, job *cherryPickJob) error {
+	now := time.Now()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.State = jobStateRunning
+	job.Attempts++
+	job.Reason = ""
+	job.UpdatedAt = now
+	if err := s.jobs.put(*job); err != nil {
+		log.WithError(err).Warn("Failed to persist cherry-pick job.")
+	}
+	s.reportCommitStatus(log, job)
+
+	err := s.handle(log, job)
+
+	switch {
+	case errors.Is(err, errConflict):
+		job.State = jobStateConflicted
+		job.Reason = err.Error()
+	case err != nil && job.Attempts < s.maxAttempts:
+		job.State = jobStateQueued
+		job.Reason = err.Error()
+		job.NextAttempt = time.Now().Add(s.retryDelay(job.Attempts))
+		log.WithError(err).WithField("next_attempt", job.NextAttempt).Info("Cherry-pick attempt failed, scheduled retry.")
+	case err != nil:
+		job.State = jobStateFailed
+		job.Reason = err.Error()
+	case job.Reason != "":
+		job.State = jobStateFailed
+	default:
+		job.State = jobStateSucceeded
+	}
+	job.UpdatedAt = time.Now()
+	if err := s.jobs.put(*job); err != nil {
+		log.WithError(err).Warn("Failed to persist cherry-pick job.")
+	}
+	s.reportCommitStatus(log, job)
+	if job.finished() {
+		recordRequest(job.Comment, jobOutcome(job, err))
+	}
+	if job.chained() {
+		if err := s.updateChainStatus(job.Org, job.Repo, job.origin()); err != nil {
+			log.WithError(err).Warn("Failed to update status of chained cherry-picks.")
+		}
+	}
+	return err
+}
+
+// startRetry marks the retry of the job as submitted. It returns false if it was submitted already.
+func (s *Server) startRetry(key string) bool {
+	s.retryLock.Lock()
+	defer s.retryLock.Unlock()
+	if s.retrying.Has(key) {
+		return false
+	}
+	if s.retrying == nil {
+		s.retrying = sets.New[string]()
+	}
+	s.retrying.Insert(key)
+	return true
+}
+
+// finishRetry marks the retry of the job as finished.
+func (s *Server) finishRetry(key string) {
+	s.retryLock.Lock()
+	defer s.retryLock.Unlock()
+	s.retrying.Delete(key)
+}
+
+// retryDelay returns the exponential backoff before the next attempt of a job.
+func (s *Server) retryDelay(attempts int) time.Duration {
+	delay := s.retryBackoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryBackoff)
+}
+
+// resumeJobs requeues all jobs which were running when the plugin was stopped, so that
+// they are picked up by runDueJobs again. Queued jobs, including those which were persisted by
+// runJobs but did not start yet, are picked up once their next attempt is due.
+func (s *Server) resumeJobs(log logrus.FieldLogger) {
+	var resumed int
+	for _, job := range s.jobs.list() {
+		switch job.State {
+		case jobStateQueued:
+			resumed++
+		case jobStateRunning:
+			job.State = jobStateQueued
+			job.NextAttempt = time.Now()
+			if err := s.jobs.put(job); err != nil {
+				log.WithError(err).WithField("job", job.key()).Warn("Failed to requeue interrupted cherry-pick job.")
+				continue
+			}
+			resumed++
+		}
+	}
+	log.WithField("jobs", resumed).Info("Resumed queued cherry-pick jobs.")
+}
+
+// due returns true if the job is queued and its next attempt is due.
+func (j *cherryPickJob) due(now time.Time) bool {
+	return j.State == jobStateQueued && !j.NextAttempt.After(now)
+}
+
+// runDueJobs submits the retries of all queued jobs whose next attempt is due to the worker pool and
+// prunes old finished jobs. Jobs whose retry was submitted already are skipped, and every job is read
+// again before it runs, as it may have been run or cancelled in the meantime.
+func (s *Server) runDueJobs(log logrus.FieldLogger) {
+	now := time.Now()
+	for _, job := range s.jobs.list() {
+		if !job.due(now) || !s.startRetry(job.key()) {
+			continue
+		}
+		key := job.key()
+		err := s.submit(func() {
+			defer s.finishRetry(key)
+			job, ok := s.jobs.get(key)
+			if !ok || !job.due(time.Now()) {
+				return
+			}
+			jobLog := log.WithFields(logrus.Fields{
+				github.OrgLogField:  job.Org,
+				github.RepoLogField: job.Repo,
+				github.PrLogField:   job.Number,
+				"requestor":         job.Requestor,
+				"target_branch":     job.target(),
+				"attempt":           job.Attempts + 1,
+			})
+			jobLog.Info("Retrying cherry-pick.")
+			if err := s.runJob(jobLog, &job); err != nil {
+				jobLog.WithError(err).Info("Cherry-pick failed.")
+			}
+		})
+		if err != nil {
+			// The remaining retries are submitted with the next tick.
+			s.finishRetry(key)
+			log.WithError(err).WithField("job", key).Info("Could not submit retry of cherry-pick.")
+			break
+		}
+	}
+
+	if err := s.jobs.prune(now.Add(-jobRetention)); err != nil {
+		log.WithError(err).Warn("Failed to prune cherry-pick jobs.")
+	}
+}
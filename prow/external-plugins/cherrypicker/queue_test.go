@@ -0,0 +1,432 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/git/localgit"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+type failingClientFactory struct {
+	git.ClientFactory
+}
+
+func (f *failingClientFactory) ClientFor(_, _ string) (git.RepoClient, error) {
+	return nil, errors.New("git is down")
+}
+
+// flakyLabelGHC fails to add labels for the given number of calls.
+type flakyLabelGHC struct {
+	*fghc
+	failures int
+}
+
+func (f *flakyLabelGHC) AddLabel(org, repo string, number int, label string) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("labels are down")
+	}
+	return f.fghc.AddLabel(org, repo, number, label)
+}
+
+func TestJobStorePersistence(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	js, err := newJobStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	jobs := []cherryPickJob{
+		{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "release-1.0", State: jobStateQueued, Attempts: 1, CreatedAt: now, UpdatedAt: now},
+		{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "release-1.0", State: jobStateSucceeded, ResultPR: 3, CreatedAt: now.Add(time.Minute), UpdatedAt: now.Add(-2 * jobRetention)},
+		{Org: "foo", Repo: "bar", Number: 4, TargetBranch: "release-1.0", State: jobStateFailed, Reason: "boom", CreatedAt: now.Add(2 * time.Minute), UpdatedAt: now},
+	}
+	for _, job := range jobs {
+		if err := js.put(job); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	reloaded, err := newJobStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(jobs, reloaded.list()); diff != "" {
+		t.Errorf("reloaded jobs differ (-want +got):\n%s", diff)
+	}
+
+	if err := reloaded.prune(now.Add(-jobRetention)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reloaded, err = newJobStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]cherryPickJob{jobs[0], jobs[2]}, reloaded.list()); diff != "" {
+		t.Errorf("pruned jobs differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunJobRetries(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{
+		ghc:          &fghc{},
+		gc:           &failingClientFactory{},
+		botUser:      &github.UserData{Login: "ci-robot"},
+		jobs:         js,
+		maxAttempts:  2,
+		retryBackoff: time.Minute,
+		workers:      newWorkerPool(1, 10),
+	}
+	l := logrus.WithField("test", t.Name())
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master"}
+	if err := s.runJob(l, job); err == nil {
+		t.Fatal("expected error, but did not get one")
+	}
+	if job.State != jobStateQueued || job.Attempts != 1 {
+		t.Errorf("expected job to be queued for a retry after the first attempt, got state %q after %d attempts", job.State, job.Attempts)
+	}
+	if !job.NextAttempt.After(time.Now()) {
+		t.Errorf("expected next attempt to be in the future, got %v", job.NextAttempt)
+	}
+
+	// The retry is not due yet.
+	s.runDueJobs(l)
+	if got := js.list()[0]; got.Attempts != 1 {
+		t.Errorf("expected job not to be retried before its next attempt, got %d attempts", got.Attempts)
+	}
+
+	job.NextAttempt = time.Now()
+	if err := js.put(*job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.runDueJobs(l)
+	waitForRetries(t, s)
+	got := js.list()[0]
+	if got.State != jobStateFailed || got.Attempts != 2 {
+		t.Errorf("expected job to fail after the second attempt, got state %q after %d attempts", got.State, got.Attempts)
+	}
+	if got.Reason == "" {
+		t.Error("expected failure reason to be recorded")
+	}
+}
+
+func TestRunDueJobsSkipsSubmittedAndChangedJobs(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{
+		ghc:          &fghc{},
+		gc:           &failingClientFactory{},
+		botUser:      &github.UserData{Login: "ci-robot"},
+		jobs:         js,
+		maxAttempts:  2,
+		retryBackoff: time.Minute,
+		workers:      newWorkerPool(1, 10),
+	}
+	l := logrus.WithField("test", t.Name())
+
+	job := cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master", State: jobStateQueued, Attempts: 1}
+	if err := js.put(job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Block the only worker, so that the retry waits in the queue.
+	started, release := make(chan struct{}), make(chan struct{})
+	if err := s.workers.submit(func() {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-started
+	s.runDueJobs(l)
+	// The retry was submitted already, so it is not submitted again.
+	s.runDueJobs(l)
+	if n := len(s.workers.queue); n != 1 {
+		t.Errorf("expected a single queued retry, got %d", n)
+	}
+	// The job succeeded in the meantime, so the queued retry does not run it again.
+	job.State = jobStateSucceeded
+	if err := js.put(job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	waitForRetries(t, s)
+
+	if got := js.list()[0]; got.State != jobStateSucceeded || got.Attempts != 1 {
+		t.Errorf("expected job not to be retried, got state %q after %d attempts", got.State, got.Attempts)
+	}
+}
+
+// waitForRetries waits until the retries submitted by runDueJobs finished.
+func waitForRetries(t *testing.T, s *Server) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.retryLock.Lock()
+		n := s.retrying.Len()
+		s.retryLock.Unlock()
+		if n == 0 {
+			return
+		}
+	}
+	t.Fatal("retries did not finish in time")
+}
+
+func TestRunJobReportedFailure(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{}
+	s := &Server{
+		ghc:         ghc,
+		botUser:     &github.UserData{Login: "ci-robot"},
+		jobs:        js,
+		maxAttempts: 3,
+	}
+
+	// EnsureFork fails for the "error" repository, which is reported on the PR.
+	job := &cherryPickJob{Org: "foo", Repo: "error", Number: 1, TargetBranch: "stage", BaseBranch: "master"}
+	if err := s.runJob(logrus.WithField("test", t.Name()), job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.State != jobStateFailed || job.Attempts != 1 {
+		t.Errorf("expected job to fail without retry, got state %q after %d attempts", job.State, job.Attempts)
+	}
+	if len(ghc.comments) != 1 {
+		t.Errorf("expected failure to be reported in one comment, got %v", ghc.comments)
+	}
+}
+
+func TestRunJobConflict(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out pull branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("package bar\n\n// Foo was changed on stage.\nfunc Foo(wow int) int {\n\treturn 7 * wow\n}\n")}); err != nil {
+		t.Fatalf("Adding conflicting commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.CheckoutNewBranch("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Checking out fix branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("package bar\n\n// Foo does a thing.\nfunc Foo(wow int) int {\n\treturn 49 + wow\n}\n")}); err != nil {
+		t.Fatalf("Adding fix commit: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.Merge("foo", "bar", "fix"); err != nil {
+		t.Fatalf("Merging fix: %v", err)
+	}
+	mergeSHA, err := lg.RevParse("foo", "bar", "HEAD")
+	if err != nil {
+		t.Fatalf("Parsing merge commit: %v", err)
+	}
+
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{patch: patch}
+	s := &Server{
+		botUser:     &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:          c,
+		pusher:      &testPusher{},
+		ghc:         ghc,
+		jobs:        js,
+		maxAttempts: 3,
+	}
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master", Title: "This is a fix for X", MergeSHA: mergeSHA}
+	if err := s.runJob(logrus.WithField("test", t.Name()), job); !errors.Is(err, errConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if job.State != jobStateConflicted || job.Attempts != 1 {
+		t.Errorf("expected job to be conflicted without retry, got state %q after %d attempts", job.State, job.Attempts)
+	}
+}
+
+func TestRunJobRetriesMissingMergeCommit(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out pull branch: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"bar.go": []byte("package bar\n\n// Foo was changed on stage.\nfunc Foo(wow int) int {\n\treturn 7 * wow\n}\n")}); err != nil {
+		t.Fatalf("Adding conflicting commit: %v", err)
+	}
+
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{
+		botUser:     &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:          c,
+		pusher:      &testPusher{},
+		ghc:         &fghc{patch: patch},
+		jobs:        js,
+		maxAttempts: 3,
+	}
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master", Title: "This is a fix for X", MergeSHA: "0123456789abcdef0123456789abcdef01234567"}
+	if err := s.runJob(logrus.WithField("test", t.Name()), job); err == nil || errors.Is(err, errConflict) {
+		t.Fatalf("expected non-conflict error, got %v", err)
+	}
+	if job.State != jobStateQueued || job.Attempts != 1 {
+		t.Errorf("expected job to be retried, got state %q after %d attempts", job.State, job.Attempts)
+	}
+}
+
+func TestRunJobRetriesFollowUpSteps(t *testing.T) {
+	t.Parallel()
+	lg, c := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out pull branch: %v", err)
+	}
+
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{patch: patch}
+	s := &Server{
+		botUser:      &github.UserData{Login: "ci-robot", Email: "ci-robot@users.noreply.github.com"},
+		gc:           c,
+		pusher:       &testPusher{},
+		ghc:          &flakyLabelGHC{fghc: ghc, failures: 1},
+		jobs:         js,
+		labels:       []string{"cherry-pick"},
+		maxAttempts:  3,
+		retryBackoff: time.Minute,
+	}
+	l := logrus.WithField("test", t.Name())
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master", Title: "This is a fix for X"}
+	if err := s.runJob(l, job); err == nil {
+		t.Fatal("expected error, but did not get one")
+	}
+	if job.State != jobStateQueued || job.ResultPR != 1 {
+		t.Fatalf("expected job to be retried for cherry-pick PR #1, got state %q for PR #%d", job.State, job.ResultPR)
+	}
+
+	if err := s.runJob(l, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.State != jobStateSucceeded {
+		t.Errorf("expected job to succeed, got state %q: %s", job.State, job.Reason)
+	}
+	if len(ghc.prs) != 1 {
+		t.Fatalf("expected one cherry-pick PR, got %d", len(ghc.prs))
+	}
+	if diff := cmp.Diff([]github.Label{{Name: "cherry-pick"}}, ghc.prs[0].Labels); diff != "" {
+		t.Errorf("unexpected labels (-want +got):\n%s", diff)
+	}
+	if len(ghc.comments) != 1 || !strings.Contains(ghc.comments[0], "new pull request created") {
+		t.Errorf("expected only the comment about the new PR, got %v", ghc.comments)
+	}
+}
+
+func TestRunJobCommentsFailureOnLastAttempt(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{}
+	s := &Server{ghc: ghc, maxAttempts: 2}
+	l := logrus.WithField("test", t.Name())
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, Attempts: 1}
+	if err := s.createRetriedFailureComment(l, job, "boom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 0 {
+		t.Errorf("expected no comment before the last attempt, got %v", ghc.comments)
+	}
+	job.Attempts = 2
+	if err := s.createRetriedFailureComment(l, job, "boom"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ghc.comments) != 1 {
+		t.Errorf("expected a comment on the last attempt, got %v", ghc.comments)
+	}
+}
+
+func TestResumeJobs(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, state := range []jobState{jobStateRunning, jobStateQueued, jobStateSucceeded} {
+		if err := js.put(cherryPickJob{Org: "foo", Repo: "bar", Number: i, TargetBranch: "stage", State: state}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	s := &Server{jobs: js}
+	s.resumeJobs(logrus.WithField("test", t.Name()))
+
+	var states []jobState
+	for _, job := range js.list() {
+		states = append(states, job.State)
+	}
+	if diff := cmp.Diff([]jobState{jobStateQueued, jobStateQueued, jobStateSucceeded}, states); diff != "" {
+		t.Errorf("unexpected job states (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunJobsPersistsQueuedJobs(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := &Server{
+		ghc:          &fghc{},
+		gc:           &failingClientFactory{},
+		botUser:      &github.UserData{Login: "ci-robot"},
+		jobs:         js,
+		maxAttempts:  2,
+		retryBackoff: time.Minute,
+	}
+	l := logrus.WithField("test", t.Name())
+
+	first := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "stage", BaseBranch: "master"}
+	second := &cherryPickJob{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "release", BaseBranch: "master"}
+	// The retry of the second job was submitted already, so it is left to the retry.
+	s.startRetry(second.key())
+
+	errs := s.runJobs([]loggedJob{{log: l, job: first}, {log: l, job: second}})
+	if errs[0] == nil || errs[1] != nil {
+		t.Errorf("expected only the first job to run and fail, got %v", errs)
+	}
+	got, ok := js.get(second.key())
+	if !ok || got.State != jobStateQueued || got.Attempts != 0 || !got.due(time.Now()) {
+		t.Errorf("expected the second job to be persisted as due without attempts, got %+v", got)
+	}
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// releaseNoteCategories are the categories of release notes, see releaseNoteRe.
+var releaseNoteCategories = []string{"breaking", "noteworthy", "feature", "bugfix", "doc", "other"}
+
+// releaseNote is a release note block of a PR body, e.g.
+//
+//	```bugfix operator github.com/gardener/gardener #123 @author
+//	Fix X
+//	```
+type releaseNote struct {
+	Category  string
+	Audience  string
+	Source    string
+	Reference string
+	Author    string
+	Text      string
+}
+
+// parseReleaseNotes returns the release notes of the PR body.
+func parseReleaseNotes(body string) []releaseNote {
+	var notes []releaseNote
+	for _, match := range releaseNoteRe.FindAllStringSubmatch(body, -1) {
+		notes = append(notes, releaseNote{
+			Category:  strings.TrimSpace(match[2]),
+			Audience:  strings.TrimSpace(match[3]),
+			Source:    strings.TrimSpace(match[4]),
+			Reference: strings.TrimSpace(match[5]),
+			Author:    strings.TrimSpace(match[6]),
+			Text:      strings.TrimSpace(match[7]),
+		})
+	}
+	return notes
+}
+
+// isNone returns true if the note states that the PR has no user-facing changes.
+func (n releaseNote) isNone() bool {
+	return strings.EqualFold(n.Text, "NONE")
+}
+
+func (n releaseNote) String() string {
+	header := strings.Join(slices.DeleteFunc([]string{n.Category, n.Audience, n.Source, n.Reference, n.Author}, func(s string) bool { return s == "" }), " ")
+	return fmt.Sprintf("```%s\n%s\n```", header, n.Text)
+}
+
+// formatReleaseNotes returns the release note blocks for the body of a PR.
+func formatReleaseNotes(notes []releaseNote) string {
+	blocks := make([]string, 0, len(notes))
+	for _, note := range notes {
+		blocks = append(blocks, note.String())
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// releaseNoteRules transform the release notes of a PR when it is cherry-picked.
+type releaseNoteRules struct {
+	// PatchBranchPattern is a regular expression matching the branches which only receive patch
+	// releases, e.g. `^release-v\d+\.\d+$`.
+	PatchBranchPattern string `json:"patchBranchPattern,omitempty"`
+	// PatchCategories maps the categories of notes cherry-picked onto patch branches to a replacement
+	// category, e.g. noteworthy to other. Notes are dropped if the replacement is empty.
+	PatchCategories map[string]string `json:"patchCategories,omitempty"`
+	// PrependTargetBranch prepends `[<target branch>]` to the text of the notes.
+	PrependTargetBranch bool `json:"prependTargetBranch,omitempty"`
+	// Merge merges the notes with the same category and audience into a single note.
+	Merge bool `json:"merge,omitempty"`
+	// DropNone omits `NONE` notes from the cherry-pick PR. Otherwise, a single `NONE` note is kept if
+	// the PR has no other notes, or if all of its notes were dropped.
+	DropNone bool `json:"dropNone,omitempty"`
+
+	// The compiled PatchBranchPattern is set by compile when the configuration is loaded.
+	patchBranchPattern *regexp.Regexp
+}
+
+// compile validates the rules and compiles the pattern of the patch branches.
+func (r *releaseNoteRules) compile() error {
+	if r.PatchBranchPattern != "" {
+		pattern, err := regexp.Compile(r.PatchBranchPattern)
+		if err != nil {
+			return fmt.Errorf("invalid patchBranchPattern %q: %w", r.PatchBranchPattern, err)
+		}
+		r.patchBranchPattern = pattern
+	}
+	for category, replacement := range r.PatchCategories {
+		if !slices.Contains(releaseNoteCategories, category) {
+			return fmt.Errorf("unknown release note category %q in patchCategories", category)
+		}
+		if replacement != "" && !slices.Contains(releaseNoteCategories, replacement) {
+			return fmt.Errorf("unknown release note category %q in patchCategories", replacement)
+		}
+	}
+	return nil
+}
+
+// isPatchBranch returns true if the target branch only receives patch releases.
+func (r *releaseNoteRules) isPatchBranch(targetBranch string) bool {
+	return r.patchBranchPattern != nil && r.patchBranchPattern.MatchString(targetBranch)
+}
+
+// apply returns the release notes of the cherry-pick PR onto the target branch. It returns the notes
+// unchanged if there are no rules.
+func (r *releaseNoteRules) apply(notes []releaseNote, targetBranch string) []releaseNote {
+	if r == nil || len(notes) == 0 {
+		return notes
+	}
+
+	var none *releaseNote
+	var result []releaseNote
+	for _, note := range notes {
+		if note.isNone() {
+			if none == nil {
+				none = &note
+			}
+			continue
+		}
+		if r.isPatchBranch(targetBranch) {
+			if replacement, ok := r.PatchCategories[note.Category]; ok {
+				if replacement == "" {
+					continue
+				}
+				note.Category = replacement
+			}
+		}
+		if r.PrependTargetBranch {
+			note.Text = fmt.Sprintf("[%s] %s", targetBranch, note.Text)
+		}
+		result = append(result, note)
+	}
+
+	if r.Merge {
+		result = mergeReleaseNotes(result)
+	}
+	if len(result) > 0 || r.DropNone {
+		return result
+	}
+	if none == nil {
+		none = &releaseNote{Category: "other", Audience: notes[0].Audience, Source: notes[0].Source, Reference: notes[0].Reference, Author: notes[0].Author, Text: "NONE"}
+	}
+	return []releaseNote{*none}
+}
+
+// mergeReleaseNotes merges the notes with the same category and audience. The merged note keeps the
+// position, source, reference and author of the first note.
+func mergeReleaseNotes(notes []releaseNote) []releaseNote {
+	var merged []releaseNote
+	for _, note := range notes {
+		i := slices.IndexFunc(merged, func(m releaseNote) bool {
+			return m.Category == note.Category && m.Audience == note.Audience
+		})
+		if i < 0 {
+			merged = append(merged, note)
+			continue
+		}
+		merged[i].Text += "\n" + note.Text
+	}
+	return merged
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseReleaseNotes(t *testing.T) {
+	t.Parallel()
+	body := "Fixes X\n\n```bugfix operator\nFix X\n```\n\n```feature developer github.com/foo/baz #7 @bar-author\nAdd Y\nwith two lines\n```"
+	expected := []releaseNote{
+		{Category: "bugfix", Audience: "operator", Text: "Fix X"},
+		{Category: "feature", Audience: "developer", Source: "github.com/foo/baz", Reference: "#7", Author: "@bar-author", Text: "Add Y\nwith two lines"},
+	}
+	notes := parseReleaseNotes(body)
+	if diff := cmp.Diff(expected, notes); diff != "" {
+		t.Errorf("unexpected notes (-want +got):\n%s", diff)
+	}
+	if got, want := notes[0].String(), "```bugfix operator\nFix X\n```"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestReleaseNoteRules(t *testing.T) {
+	t.Parallel()
+	note := func(category, audience, text string) releaseNote {
+		return releaseNote{Category: category, Audience: audience, Source: "github.com/foo/bar", Reference: "#2", Author: "@foo-author", Text: text}
+	}
+	patchRules := releaseNoteRules{
+		PatchBranchPattern: `^release-v\d+\.\d+$`,
+		PatchCategories:    map[string]string{"noteworthy": "other", "feature": ""},
+	}
+	if err := patchRules.compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dropNoneRules := releaseNoteRules{PatchBranchPattern: patchRules.PatchBranchPattern, PatchCategories: patchRules.PatchCategories, DropNone: true}
+	if err := dropNoneRules.compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		rules        *releaseNoteRules
+		targetBranch string
+		notes        []releaseNote
+		expected     []releaseNote
+	}{
+		{
+			name:         "no rules",
+			targetBranch: "release-v1.2",
+			notes:        []releaseNote{note("feature", "user", "Add X")},
+			expected:     []releaseNote{note("feature", "user", "Add X")},
+		},
+		{
+			name:         "patch branch",
+			rules:        &patchRules,
+			targetBranch: "release-v1.2",
+			notes:        []releaseNote{note("feature", "user", "Add X"), note("noteworthy", "operator", "Change Y"), note("bugfix", "user", "Fix Z")},
+			expected:     []releaseNote{note("other", "operator", "Change Y"), note("bugfix", "user", "Fix Z")},
+		},
+		{
+			name:         "other branch",
+			rules:        &patchRules,
+			targetBranch: "stage",
+			notes:        []releaseNote{note("feature", "user", "Add X")},
+			expected:     []releaseNote{note("feature", "user", "Add X")},
+		},
+		{
+			name:         "all notes dropped",
+			rules:        &patchRules,
+			targetBranch: "release-v1.2",
+			notes:        []releaseNote{note("feature", "user", "Add X")},
+			expected:     []releaseNote{note("other", "user", "NONE")},
+		},
+		{
+			name:         "all notes dropped without NONE",
+			rules:        &dropNoneRules,
+			targetBranch: "release-v1.2",
+			notes:        []releaseNote{note("feature", "user", "Add X")},
+		},
+		{
+			name:     "NONE and other notes",
+			rules:    &releaseNoteRules{},
+			notes:    []releaseNote{note("other", "operator", "NONE"), note("bugfix", "user", "Fix Z")},
+			expected: []releaseNote{note("bugfix", "user", "Fix Z")},
+		},
+		{
+			name:     "only NONE notes",
+			rules:    &releaseNoteRules{},
+			notes:    []releaseNote{note("other", "operator", "none"), note("other", "user", "NONE")},
+			expected: []releaseNote{note("other", "operator", "none")},
+		},
+		{
+			name:         "prepend target branch and merge",
+			rules:        &releaseNoteRules{PrependTargetBranch: true, Merge: true},
+			targetBranch: "release-v1.2",
+			notes:        []releaseNote{note("bugfix", "user", "Fix X"), note("bugfix", "operator", "Fix Y"), note("bugfix", "user", "Fix Z")},
+			expected:     []releaseNote{note("bugfix", "user", "[release-v1.2] Fix X\n[release-v1.2] Fix Z"), note("bugfix", "operator", "[release-v1.2] Fix Y")},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tc.expected, tc.rules.apply(tc.notes, tc.targetBranch)); diff != "" {
+				t.Errorf("unexpected notes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReleaseNoteRulesCompile(t *testing.T) {
+	t.Parallel()
+	for _, rules := range []releaseNoteRules{
+		{PatchBranchPattern: "("},
+		{PatchCategories: map[string]string{"improvement": "other"}},
+		{PatchCategories: map[string]string{"feature": "minor"}},
+	} {
+		if err := rules.compile(); err == nil {
+			t.Errorf("expected error for %+v", rules)
+		}
+	}
+	if err := (&releaseNoteRules{PatchCategories: map[string]string{"feature": ""}}).compile(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
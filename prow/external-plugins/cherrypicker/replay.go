@@ -0,0 +1,326 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// replay runs the recorded webhook payload of --replay-event against a fake GitHub, which is backed
+// by the payload and the local repositories in --replay-git-dir (as <dir>/org/repo). The server is
+// created from the same options as the plugin. All actions the plugin would take on GitHub are
+// printed to out instead.
+func replay(log logrus.FieldLogger, o options, out io.Writer) error {
+	payload, err := os.ReadFile(o.replayPayload)
+	if err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+	var trust trustClient = newRecordedTrust(o.replayTrustedUsers.Strings())
+	if o.replayGitHubTrust {
+		githubClient, err := o.github.GitHubClient(true)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client: %w", err)
+		}
+		trust = githubClient
+	}
+	ghc, err := newReplayGitHub(o.replayEvent, payload, o.replayGitDir, trust, out)
+	if err != nil {
+		return err
+	}
+	gc, err := git.NewLocalClientFactory(o.replayGitDir,
+		func() (string, string, error) { return replayBot, replayBot + "@example.com", nil },
+		func(content []byte) []byte { return content })
+	if err != nil {
+		return fmt.Errorf("failed to create git client factory: %w", err)
+	}
+
+	// Jobs of a replay are neither persisted nor retried.
+	o.queuePath = ""
+	o.maxAttempts, o.workers, o.queueSize = 1, 1, 1
+	s, err := newServer(o, log, ghc, gc, &github.UserData{Login: replayBot, Email: replayBot + "@example.com"}, "")
+	if err != nil {
+		return err
+	}
+	s.pusher = &replayPusher{ghc: ghc}
+	if err := s.handleEvent(o.replayEvent, "replay", payload); err != nil {
+		return err
+	}
+	return s.workers.stop(context.Background())
+}
+
+// replayBot is the login of the bot during a replay.
+const replayBot = "cherrypicker-replay"
+
+// trustClient answers the org membership and collaborator checks of a replay.
+type trustClient interface {
+	IsMember(org, user string) (bool, error)
+	IsCollaborator(org, repo, user string) (bool, error)
+	ListOrgMembers(org, role string) ([]github.TeamMember, error)
+	ListCollaborators(org, repo string) ([]github.User, error)
+}
+
+// recordedTrust answers the trust checks of a replay with the recorded trusted users. They are members
+// of every org and collaborators of every repository.
+type recordedTrust struct {
+	users sets.Set[string]
+}
+
+func newRecordedTrust(users []string) *recordedTrust {
+	return &recordedTrust{users: sets.New(users...)}
+}
+
+func (t *recordedTrust) IsMember(_, user string) (bool, error) {
+	return t.users.Has(user), nil
+}
+
+func (t *recordedTrust) IsCollaborator(_, _, user string) (bool, error) {
+	return t.users.Has(user), nil
+}
+
+func (t *recordedTrust) ListOrgMembers(_, _ string) ([]github.TeamMember, error) {
+	var members []github.TeamMember
+	for _, login := range sets.List(t.users) {
+		members = append(members, github.TeamMember{Login: login})
+	}
+	return members, nil
+}
+
+func (t *recordedTrust) ListCollaborators(_, _ string) ([]github.User, error) {
+	var users []github.User
+	for _, login := range sets.List(t.users) {
+		users = append(users, github.User{Login: login})
+	}
+	return users, nil
+}
+
+// replayGitHub is a fake GitHub client for replays. It answers queries from the payload and the local
+// repository, and prints all mutations. Trust checks are answered by the trustClient.
+type replayGitHub struct {
+	trustClient
+
+	lock sync.Mutex
+	out  io.Writer
+	dir  string
+
+	pr       github.PullRequest
+	comments []github.IssueComment
+	labels   []github.Label
+	created  []github.PullRequest
+	nextID   int
+}
+
+func newReplayGitHub(eventType string, payload []byte, gitDir string, trust trustClient, out io.Writer) (*replayGitHub, error) {
+	r := &replayGitHub{trustClient: trust, out: out, nextID: 1000}
+	switch eventType {
+	case "issue_comment":
+		var ic github.IssueCommentEvent
+		if err := json.Unmarshal(payload, &ic); err != nil {
+			return nil, err
+		}
+		r.dir = filepath.Join(gitDir, ic.Repo.Owner.Login, ic.Repo.Name)
+		// The payload only contains the issue, the PR is assumed to be merged into the default branch if it is closed.
+		r.pr = github.PullRequest{
+			Number: ic.Issue.Number,
+			Title:  ic.Issue.Title,
+			Body:   ic.Issue.Body,
+			User:   ic.Issue.User,
+			State:  ic.Issue.State,
+			Labels: ic.Issue.Labels,
+			Merged: ic.Issue.State == "closed",
+		}
+		r.pr.Base.Repo = ic.Repo
+		r.pr.Base.Ref = ic.Repo.DefaultBranch
+		if sha, err := runGit(r.dir, "rev-parse", "refs/heads/"+r.pr.Base.Ref); err == nil {
+			sha = strings.TrimSpace(sha)
+			r.pr.MergeSHA = &sha
+		}
+		r.comments = []github.IssueComment{ic.Comment}
+		r.labels = ic.Issue.Labels
+	case "pull_request":
+		var pre github.PullRequestEvent
+		if err := json.Unmarshal(payload, &pre); err != nil {
+			return nil, err
+		}
+		r.dir = filepath.Join(gitDir, pre.Repo.Owner.Login, pre.Repo.Name)
+		r.pr = pre.PullRequest
+		r.labels = pre.PullRequest.Labels
+	default:
+		return nil, fmt.Errorf("unsupported event type %q, expected issue_comment or pull_request", eventType)
+	}
+	return r, nil
+}
+
+func (r *replayGitHub) printf(format string, args ...any) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	fmt.Fprintf(r.out, format+"\n", args...)
+}
+
+func (r *replayGitHub) AddLabel(org, repo string, number int, label string) error {
+	r.printf("AddLabel %s/%s#%d: %s", org, repo, number, label)
+	return nil
+}
+
+func (r *replayGitHub) AssignIssue(org, repo string, number int, logins []string) error {
+	r.printf("AssignIssue %s/%s#%d: %s", org, repo, number, strings.Join(logins, ", "))
+	return nil
+}
+
+func (r *replayGitHub) CreateComment(org, repo string, number int, comment string) error {
+	r.printf("CreateComment %s/%s#%d:\n%s\n", org, repo, number, comment)
+	return nil
+}
+
+func (r *replayGitHub) EditComment(org, repo string, id int, comment string) error {
+	r.printf("EditComment %s/%s %d:\n%s\n", org, repo, id, comment)
+	return nil
+}
+
+func (r *replayGitHub) CreateFork(_, repo string) (string, error) {
+	return repo, nil
+}
+
+func (r *replayGitHub) EnsureFork(_, _, repo string) (string, error) {
+	return repo, nil
+}
+
+func (r *replayGitHub) CreatePullRequest(org, repo, title, body, head, base string, _ bool) (int, error) {
+	r.lock.Lock()
+	r.nextID++
+	number := r.nextID
+	pr := github.PullRequest{Number: number, Title: title, Body: body}
+	pr.Head.Ref, pr.Base.Ref = head, base
+	r.created = append(r.created, pr)
+	r.lock.Unlock()
+	r.printf("CreatePullRequest %s/%s#%d (%s <- %s): %s\n%s\n", org, repo, number, base, head, title, body)
+	return number, nil
+}
+
+func (r *replayGitHub) CreateStatus(org, repo, sha string, s github.Status) error {
+	r.printf("CreateStatus %s/%s@%s %s: %s (%s)", org, repo, sha, s.Context, s.State, s.Description)
+	return nil
+}
+
+func (r *replayGitHub) CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error) {
+	r.lock.Lock()
+	r.nextID++
+	number := r.nextID
+	r.lock.Unlock()
+	r.printf("CreateIssue %s/%s#%d (milestone %d, labels %v, assignees %v): %s\n%s\n", org, repo, number, milestone, labels, assignees, title, body)
+	return number, nil
+}
+
+func (r *replayGitHub) EditPullRequest(org, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error) {
+	r.printf("EditPullRequest %s/%s#%d: %s", org, repo, number, pr.Title)
+	return pr, nil
+}
+
+func (r *replayGitHub) MutateWithGitHubAppsSupport(_ context.Context, _ any, input githubql.Input, _ map[string]any, org string) error {
+	r.printf("Mutate %s: %+v", org, input)
+	return nil
+}
+
+func (r *replayGitHub) GetPullRequest(_, _ string, number int) (*github.PullRequest, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if number == r.pr.Number {
+		pr := r.pr
+		return &pr, nil
+	}
+	for _, pr := range r.created {
+		if pr.Number == number {
+			return &pr, nil
+		}
+	}
+	return nil, fmt.Errorf("PR #%d is not part of the replay", number)
+}
+
+// GetPullRequestPatch returns the patch of the merge commit of the PR in the local repository.
+func (r *replayGitHub) GetPullRequestPatch(_, _ string, _ int) ([]byte, error) {
+	if r.pr.MergeSHA == nil {
+		return nil, fmt.Errorf("merge commit of PR #%d is unknown", r.pr.Number)
+	}
+	sha := *r.pr.MergeSHA
+	args := []string{"format-patch", "--stdout", "-1", sha}
+	if parents, err := runGit(r.dir, "rev-list", "--parents", "-n", "1", sha); err == nil && len(strings.Fields(parents)) > 2 {
+		args = []string{"format-patch", "--stdout", sha + "^1.." + sha + "^2"}
+	}
+	out, err := runGit(r.dir, args...)
+	return []byte(out), err
+}
+
+func (r *replayGitHub) GetPullRequestDiff(org, repo string, number int) ([]byte, error) {
+	return r.GetPullRequestPatch(org, repo, number)
+}
+
+func (r *replayGitHub) ListPRCommits(_, _ string, _ int) ([]github.RepositoryCommit, error) {
+	return nil, nil
+}
+
+func (r *replayGitHub) GetPullRequests(_, _ string) ([]github.PullRequest, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]github.PullRequest(nil), r.created...), nil
+}
+
+func (r *replayGitHub) FindIssuesWithOrg(_, _, _ string, _ bool) ([]github.Issue, error) {
+	return nil, nil
+}
+
+func (r *replayGitHub) GetRepo(_, _ string) (github.FullRepo, error) {
+	return github.FullRepo{Repo: r.pr.Base.Repo}, nil
+}
+
+func (r *replayGitHub) ListIssueComments(_, _ string, _ int) ([]github.IssueComment, error) {
+	return r.comments, nil
+}
+
+func (r *replayGitHub) GetIssueLabels(_, _ string, _ int) ([]github.Label, error) {
+	return r.labels, nil
+}
+
+// GetBranches returns the branches of the local repository.
+func (r *replayGitHub) GetBranches(_, _ string, _ bool) ([]github.Branch, error) {
+	out, err := runGit(r.dir, "for-each-ref", "--format=%(refname:short)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	var branches []github.Branch
+	for _, name := range strings.Fields(out) {
+		branches = append(branches, github.Branch{Name: name})
+	}
+	return branches, nil
+}
+
+func (r *replayGitHub) ListMilestones(_, _ string) ([]github.Milestone, error) {
+	return nil, nil
+}
+
+// replayPusher prints the pushed branches instead of pushing them.
+type replayPusher struct {
+	ghc *replayGitHub
+}
+
+func (p *replayPusher) Push(r git.RepoClient, newBranch string, _ bool) error {
+	log, err := runGit(r.Directory(), "log", "--format=%h %s", "HEAD", "--not", "--remotes", "--max-count=20")
+	if err != nil {
+		log = err.Error()
+	}
+	p.ghc.printf("Push %s:\n%s", newBranch, log)
+	return nil
+}
@@ -0,0 +1,115 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	prowflagutil "sigs.k8s.io/prow/pkg/flagutil"
+	"sigs.k8s.io/prow/pkg/git/localgit"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	lg, _ := makeFakeRepoWithCommit(localgit.NewV2, t)
+	if err := lg.CheckoutNewBranch("foo", "bar", "stage"); err != nil {
+		t.Fatalf("Checking out stage branch: %v", err)
+	}
+	if err := lg.Checkout("foo", "bar", "master"); err != nil {
+		t.Fatalf("Checking out master: %v", err)
+	}
+	if err := lg.AddCommit("foo", "bar", map[string][]byte{"baz.go": []byte("package bar\n")}); err != nil {
+		t.Fatalf("Adding fix commit: %v", err)
+	}
+
+	payload, err := json.Marshal(github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: "foo"}, Name: "bar", FullName: "foo/bar", DefaultBranch: "master"},
+		Issue: github.Issue{
+			Number:      2,
+			Title:       "This is a fix for X",
+			Body:        body,
+			User:        github.User{Login: "foo-author"},
+			State:       "closed",
+			PullRequest: &struct{}{},
+		},
+		Comment: github.IssueComment{User: github.User{Login: "wiseguy"}, Body: "/cherrypick stage"},
+	})
+	if err != nil {
+		t.Fatalf("Marshaling payload: %v", err)
+	}
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "payload.json")
+	if err := os.WriteFile(payloadPath, payload, 0o600); err != nil {
+		t.Fatalf("Writing payload: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("default:\n  titleTemplate: \"Cherry-pick #{{.Number}} onto {{.TargetBranch}}\"\n"), 0o600); err != nil {
+		t.Fatalf("Writing config: %v", err)
+	}
+
+	testCases := []struct {
+		name         string
+		configPath   string
+		trustedUsers []string
+		expected     []string
+		unexpected   []string
+	}{
+		{
+			name:         "trusted requestor",
+			trustedUsers: []string{"wiseguy"},
+			expected: []string{
+				"Push cherry-pick-2-to-stage",
+				"CreatePullRequest foo/bar#1001 (stage <- cherrypicker-replay:cherry-pick-2-to-stage): [stage] This is a fix for X",
+			},
+		},
+		{
+			name:         "config is loaded",
+			configPath:   configPath,
+			trustedUsers: []string{"wiseguy"},
+			expected:     []string{"CreatePullRequest foo/bar#1001 (stage <- cherrypicker-replay:cherry-pick-2-to-stage): Cherry-pick #2 onto stage"},
+		},
+		{
+			name:         "untrusted requestor",
+			trustedUsers: []string{"foo-author"},
+			expected:     []string{"CreateComment foo/bar#2:", "org members may request cherry picks"},
+			unexpected:   []string{"Push", "CreatePullRequest"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := options{
+				labelPrefix:        defaultLabelPrefix,
+				configPath:         tc.configPath,
+				replayEvent:        "issue_comment",
+				replayPayload:      payloadPath,
+				replayGitDir:       lg.Dir,
+				replayTrustedUsers: prowflagutil.NewStrings(tc.trustedUsers...),
+			}
+			var out bytes.Buffer
+			if err := replay(logrus.WithField("test", t.Name()), o, &out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, expected := range tc.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain %q, got:\n%s", expected, out.String())
+				}
+			}
+			for _, unexpected := range tc.unexpected {
+				if strings.Contains(out.String(), unexpected) {
+					t.Errorf("expected output not to contain %q, got:\n%s", unexpected, out.String())
+				}
+			}
+		})
+	}
+}
@@ -17,16 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
-	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
@@ -54,19 +56,27 @@ type githubClient interface {
 	CreateComment(org, repo string, number int, comment string) error
 	CreateFork(org, repo string) (string, error)
 	CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error)
+	CreateStatus(org, repo, SHA string, s github.Status) error
 	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
 	EnsureFork(forkingUser, org, repo string) (string, error)
 	EditPullRequest(org, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetPullRequestPatch(org, repo string, number int) ([]byte, error)
+	GetPullRequestDiff(org, repo string, number int) ([]byte, error)
+	ListPRCommits(org, repo string, number int) ([]github.RepositoryCommit, error)
 	GetPullRequests(org, repo string) ([]github.PullRequest, error)
+	FindIssuesWithOrg(org, query, sort string, asc bool) ([]github.Issue, error)
 	GetRepo(owner, name string) (github.FullRepo, error)
 	IsMember(org, user string) (bool, error)
 	IsCollaborator(org, repo, user string) (bool, error)
 	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	EditComment(org, repo string, id int, comment string) error
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	ListOrgMembers(org, role string) ([]github.TeamMember, error)
 	ListCollaborators(org, repo string) ([]github.User, error)
+	GetBranches(org, repo string, onlyProtected bool) ([]github.Branch, error)
+	ListMilestones(org, repo string) ([]github.Milestone, error)
+	MutateWithGitHubAppsSupport(ctx context.Context, m any, input githubql.Input, vars map[string]any, org string) error
 }
 
 // HelpProvider construct the pluginhelp.PluginHelp for this plugin.
@@ -82,6 +92,18 @@ func HelpProvider(_ []config.OrgRepo) (*pluginhelp.PluginHelp, error) {
 		WhoCanUse: "Members of the trusted organization for the repo.",
 		Examples:  []string{"/cherrypick release-3.9", "/cherry-pick release-1.15", "/cherrypick release-1.6 release-1.5 release-1.4"},
 	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/cherrypick cancel <branch|all>",
+		Description: "Cancel the cherrypicks onto the given branches (or all branches) which were requested on an open PR.",
+		WhoCanUse:   "Members of the trusted organization for the repo.",
+		Examples:    []string{"/cherrypick cancel release-1.15", "/cherrypick cancel all"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/cherrypick list",
+		Description: "List the pending cherrypicks of an open PR together with their requestors, or the cherrypicks of a merged PR.",
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/cherrypick list"},
+	})
 	return pluginHelp, nil
 }
 
@@ -106,16 +128,50 @@ type Server struct {
 	allowAll bool
 	// Only members of the Github organization are allowed to use cherrypicks. Otherwise, collaborators are allowed too.
 	onlyOrgMembers bool
+	// Cache of org members and collaborators, if set.
+	trustedUsers *trustCache
+	config       *configAgent
+
 	// Create an issue on cherrypick conflict.
 	issueOnConflict bool
+	// Push conflict markers in a draft PR if a cherrypick conflicts.
+	draftOnConflict bool
+	// Only allow target branches matching this pattern, if set.
+	targetBranchPattern *regexp.Regexp
+	// Orgs and org/repos for which the merged commits are cherry-picked instead of the PR patch.
+	commitModeRepos sets.Set[string]
 	// Set a custom label prefix.
 	labelPrefix string
+	// Add a Signed-off-by trailer of the bot to the cherry-picked commits.
+	signOff bool
+	// Make the bot the author of the cherry-picked commits instead of keeping the original authors.
+	resetAuthor bool
+	// Add Co-authored-by trailers for all commit authors of the PR.
+	coAuthors bool
+	// Reference the original commits in the cherry-picked commits.
+	cherryPickedFrom bool
+	// Report the state of cherry-picks as commit statuses on the merge commits of the PRs.
+	commitStatus bool
 
 	bare     *http.Client
 	patchURL string
 
+	// Store of all cherry-pick jobs.
+	jobs *jobStore
+	// Maximum number of attempts for a cherry-pick job before it is marked as failed.
+	maxAttempts int
+	// Delay before the first retry of a failed cherry-pick job. It doubles with every further attempt.
+	retryBackoff time.Duration
+
+	// Pool of workers which handle the webhook events and the retries. If nil, every event is handled in a
+	// new goroutine.
+	workers *workerPool
+	// Keys of the jobs whose retry was submitted and did not finish yet.
+	retryLock sync.Mutex
+	retrying  sets.Set[string]
+
 	mapLock sync.Mutex
-	lockMap map[cherryPickRequest]*sync.Mutex
+	lockMap map[cherryPickRequest]*requestLock
 }
 
 type pusher interface {
@@ -143,17 +199,23 @@ type cherryPickRequest struct {
 	targetBranch string
 }
 
-// ServeHTTP validates an incoming webhook and puts it into the event channel.
+// ServeHTTP validates an incoming webhook and puts it into the event channel. Events which cannot be
+// queued are rejected with 503, so that the failed delivery is visible on GitHub and can be redelivered.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	eventType, eventGUID, payload, ok, _ := github.ValidateWebhook(w, r, s.tokenGenerator)
 	if !ok {
 		return
 	}
-	fmt.Fprint(w, "Event received. Have a nice day.")
 
 	if err := s.handleEvent(eventType, eventGUID, payload); err != nil {
-		s.log.WithError(err).Error("Error parsing event.")
+		if errors.Is(err, errQueueFull) || errors.Is(err, errStopped) {
+			s.log.WithError(err).WithField(github.EventGUID, eventGUID).Warn("Rejecting event.")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		s.log.WithError(err).Error("Error handling event.")
 	}
+	fmt.Fprint(w, "Event received. Have a nice day.")
 }
 
 func (s *Server) handleEvent(eventType, eventGUID string, payload []byte) error {
@@ -167,21 +229,23 @@ func (s *Server) handleEvent(eventType, eventGUID string, payload []byte) error
 		if err := json.Unmarshal(payload, &ic); err != nil {
 			return err
 		}
-		go func() {
+		return s.submit(func() {
 			if log, err := s.handleIssueComment(l, ic); err != nil {
 				log.WithError(err).Info("Cherry-pick failed.")
 			}
-		}()
+		})
+	case "member", "membership", "organization":
+		return s.handleTrustEvent(l, payload)
 	case "pull_request":
 		var pr github.PullRequestEvent
 		if err := json.Unmarshal(payload, &pr); err != nil {
 			return err
 		}
-		go func() {
+		return s.submit(func() {
 			if log, err := s.handlePullRequest(l, pr); err != nil {
 				log.WithError(err).Info("Cherry-pick failed.")
 			}
-		}()
+		})
 	default:
 		l.Debugf("skipping event of type %q", eventType)
 	}
@@ -214,11 +278,22 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 	title := pr.Title
 	body := pr.Body
 
+	if cancelled := parseCancel(ic.Comment); len(cancelled) > 0 {
+		if err := s.handleCancel(log, ic, cancelled); err != nil {
+			return log, err
+		}
+	}
+	if isListCommand(ic.Comment) {
+		if err := s.handleList(log, ic, pr); err != nil {
+			return log, err
+		}
+	}
+
 	// Collect all branches for which a PR should be created as an immediate response
 	// to it. This excludes all subsequent branches in chained cherrypicks.
 	commands := parseComment(ic.Comment)
 
-	// Due to the regular expression, this should never happen.
+	// The comment contains only cancel or list commands.
 	if len(commands) == 0 {
 		return log, nil
 	}
@@ -234,24 +309,73 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 		return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
 	}
 
+	settings := s.settings(org, repo)
+
 	// PR is not yet done, inform the user we will remember their instructions and create PRs later.
 	if ic.Issue.State != "closed" {
-		if !s.allowAll {
+		if !settings.allowAll {
 			// Only members or collaborators should be able to do cherry-picks.
 			ok, err := s.isTrustedUser(org, repo, commentAuthor)
 			if err != nil {
 				return log, err
 			}
 			if !ok {
+				for range commands {
+					recordRequest(&ic.Comment, outcomeUntrustedUser)
+				}
 				resp := fmt.Sprintf(notOrgMemberMessageTemplate, org, org, org, commentAuthor)
 				log.Info(resp)
 				return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
 			}
 		}
 
+		invalid, err := s.invalidTargetBranches(org, repo, commands)
+		if err != nil {
+			return log, err
+		}
+		for target := range invalid {
+			delete(commands, target)
+			recordRequest(&ic.Comment, outcomeInvalidBranch)
+		}
+		untrusted, err := s.untrustedTargets(org, repo, commentAuthor, commands)
+		if err != nil {
+			return log, err
+		}
+		for target, msg := range untrusted {
+			delete(commands, target)
+			recordRequest(&ic.Comment, outcomeUntrustedUser)
+			invalid[target] = msg
+		}
+		if len(commands) == 0 {
+			resp := invalidTargetBranchesMessage(invalid)
+			log.Info(resp)
+			return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
+		}
+
 		var branchNames []string
+		now := time.Now()
 		for _, branch := range sets.List(sets.KeySet(commands)) {
 			branchNames = append(branchNames, fmt.Sprintf("`%s`", branch))
+			target := parseTarget(org, repo, branch)
+			job := cherryPickJob{
+				Org:           org,
+				Repo:          repo,
+				Number:        num,
+				TargetBranch:  target.branch,
+				TargetOrg:     target.org,
+				TargetRepo:    target.repo,
+				BaseBranch:    baseBranch,
+				Author:        pr.User.Login,
+				Requestor:     commentAuthor,
+				ChainBranches: commands[branch],
+				Title:         title,
+				State:         jobStatePending,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}
+			if err := s.jobs.put(job); err != nil {
+				log.WithError(err).WithField("target_branch", branch).Warn("Failed to persist pending cherry-pick job.")
+			}
 		}
 
 		var resp string
@@ -263,6 +387,9 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 		if hasInvalidBranch {
 			resp += fmt.Sprintf(" I cannot cherry-pick it on top of `%s` because that is already its base branch.", baseBranch)
 		}
+		if len(invalid) > 0 {
+			resp += "\n\n" + invalidTargetBranchesMessage(invalid)
+		}
 
 		log.Info(resp)
 		return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
@@ -275,19 +402,47 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 		return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
 	}
 
-	if !s.allowAll {
+	if !settings.allowAll {
 		// Only org members or collaborators should be able to do cherry-picks.
 		ok, err := s.isTrustedUser(org, repo, commentAuthor)
 		if err != nil {
 			return log, err
 		}
 		if !ok {
+			for range commands {
+				recordRequest(&ic.Comment, outcomeUntrustedUser)
+			}
 			resp := fmt.Sprintf(notOrgMemberMessageTemplate, org, org, org, commentAuthor)
 			log.Info(resp)
 			return log, s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp))
 		}
 	}
 
+	invalid, err := s.invalidTargetBranches(org, repo, commands)
+	if err != nil {
+		return log, err
+	}
+	for target := range invalid {
+		delete(commands, target)
+		recordRequest(&ic.Comment, outcomeInvalidBranch)
+	}
+	untrusted, err := s.untrustedTargets(org, repo, commentAuthor, commands)
+	if err != nil {
+		return log, err
+	}
+	for target, msg := range untrusted {
+		delete(commands, target)
+		recordRequest(&ic.Comment, outcomeUntrustedUser)
+		invalid[target] = msg
+	}
+	if len(invalid) > 0 {
+		resp := invalidTargetBranchesMessage(invalid)
+		log.Info(resp)
+		if err := s.ghc.CreateComment(org, repo, num, plugins.FormatICResponse(ic.Comment, resp)); err != nil {
+			log.WithError(err).WithField("response", resp).Error("Failed to create comment.")
+		}
+	}
+
 	// If the user requested multiple cherry-picks and one of them was invalid, warn them about it.
 	if hasInvalidBranch {
 		resp := fmt.Sprintf("I cannot cherry-pick the present PR on top of its base branch (`%s`).", baseBranch)
@@ -302,6 +457,7 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 	// targets, so we collect errors and continue the loop.
 	var errs []error
 
+	var jobs []loggedJob
 	for _, targetBranch := range sets.List(sets.KeySet(commands)) {
 		branchLog := log.WithFields(logrus.Fields{
 			"requestor":     ic.Comment.User.Login,
@@ -309,9 +465,32 @@ func (s *Server) handleIssueComment(log logrus.FieldLogger, ic github.IssueComme
 		})
 		branchLog.Debug("Cherrypick request.")
 
-		if err := s.handle(branchLog, pr.User.Login, ic.Comment.User.Login, &ic.Comment, org, repo, targetBranch, baseBranch, commands[targetBranch], title, body, num, pr.Labels); err != nil {
-			errs = append(errs, fmt.Errorf("failed to handle cherrypick for %s: %w", targetBranch, err))
-			continue
+		target := parseTarget(org, repo, targetBranch)
+		job := &cherryPickJob{
+			Org:           org,
+			Repo:          repo,
+			Number:        num,
+			TargetBranch:  target.branch,
+			TargetOrg:     target.org,
+			TargetRepo:    target.repo,
+			BaseBranch:    baseBranch,
+			Author:        pr.User.Login,
+			Requestor:     ic.Comment.User.Login,
+			Comment:       &ic.Comment,
+			ChainBranches: commands[targetBranch],
+			Title:         title,
+			Body:          body,
+			Labels:        pr.Labels,
+		}
+		if pr.MergeSHA != nil {
+			job.MergeSHA = *pr.MergeSHA
+		}
+		job.Commits = pr.Commits
+		jobs = append(jobs, loggedJob{log: branchLog, job: job})
+	}
+	for i, err := range s.runJobs(jobs) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to handle cherrypick for %s: %w", jobs[i].job.target(), err))
 		}
 	}
 
@@ -326,6 +505,9 @@ func parseComment(comment github.IssueComment) cherrypickCommands {
 	for _, match := range cherryPickRe.FindAllStringSubmatch(comment.Body, -1) {
 		targetBranches := strings.Fields(match[1])
 		targetBranch := targetBranches[0]
+		if isReservedCommand(targetBranch) {
+			continue
+		}
 
 		cmds[targetBranch] = targetBranches[1:]
 	}
@@ -340,10 +522,18 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 	}
 
 	pr := pre.PullRequest
+	if pre.Action == github.PullRequestActionClosed && !pr.Merged {
+		if err := s.jobs.removePending(pr.Base.Repo.Owner.Login, pr.Base.Repo.Name, pr.Number, ""); err != nil {
+			log.WithError(err).Warn("Failed to remove pending cherry-pick jobs of closed PR.")
+		}
+	}
 	if !pr.Merged || pr.MergeSHA == nil {
 		return log, nil
 	}
 
+	// The merged PR may be a cherry-pick itself, which continues a chain.
+	parent := s.recordMergedCherryPick(log, pr)
+
 	org := pr.Base.Repo.Owner.Login
 	repo := pr.Base.Repo.Name
 	baseBranch := pr.Base.Ref
@@ -357,36 +547,31 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 		github.PrLogField:   num,
 	})
 
+	// The jobs which run replace the pending jobs of the PR. Pending jobs which are left once the merge was
+	// handled were skipped, e.g. because the requestor is not trusted, and are failed so that they are pruned.
+	if pre.Action == github.PullRequestActionClosed {
+		defer func() {
+			if err := s.jobs.failPending(org, repo, num, "the cherry-pick was not requested anymore when the PR merged"); err != nil {
+				log.WithError(err).Warn("Failed to fail skipped pending cherry-pick jobs.")
+			}
+		}()
+	}
+
 	comments, err := s.ghc.ListIssueComments(org, repo, num)
 	if err != nil {
 		return log, fmt.Errorf("failed to list comments: %w", err)
 	}
 
-	// requestor -> target branch -> issue comment
-	requestorToComments := make(map[string]map[string]*github.IssueComment)
-	// target branch -> chain branches (eg. "release-1.6" -> []string{"release-1.5", "release-1.4"})
-	targetBranchToChainBranches := make(map[string][]string)
-
 	// treat PR body/description as a comment
 	comments = append([]github.IssueComment{{
 		Body: body,
 		User: pr.User,
 	}}, comments...)
 
-	// first look for our special comments
-	for _, comment := range comments {
-		commands := parseComment(comment)
-
-		for targetBranch, chainedBranches := range commands {
-			if requestorToComments[comment.User.Login] == nil {
-				requestorToComments[comment.User.Login] = make(map[string]*github.IssueComment)
-			}
-			requestorToComments[comment.User.Login][targetBranch] = &comment
-
-			if len(chainedBranches) > 0 {
-				targetBranchToChainBranches[targetBranch] = chainedBranches
-			}
-		}
+	// first look for our special comments, honouring cancelled requests
+	requestorToComments, targetBranchToChainBranches, err := cherryPickRequests(comments, s.trustedFunc(org, repo))
+	if err != nil {
+		return log, err
 	}
 
 	foundCherryPickComments := len(requestorToComments) != 0
@@ -401,15 +586,26 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 		requestorToComments[pr.User.Login] = make(map[string]*github.IssueComment)
 	}
 
+	settings := s.settings(org, repo)
 	foundCherryPickLabels := false
 	for _, label := range labels {
-		if strings.HasPrefix(label.Name, s.labelPrefix) {
-			requestorToComments[pr.User.Login][label.Name[len(s.labelPrefix):]] = nil // leave this nil which indicates a label-initiated cherry-pick
+		if strings.HasPrefix(label.Name, settings.labelPrefix) {
+			requestorToComments[pr.User.Login][label.Name[len(settings.labelPrefix):]] = nil // leave this nil which indicates a label-initiated cherry-pick
 			foundCherryPickLabels = true
 		}
 	}
 
-	if !foundCherryPickComments && !foundCherryPickLabels {
+	// automatic cherry-picks are only considered once, when the PR merges
+	var autoBranches []string
+	var autoReason string
+	if pre.Action == github.PullRequestActionClosed {
+		autoBranches, autoReason, err = s.autoCherryPickBranches(org, repo, pr, labels)
+		if err != nil {
+			log.WithError(err).Warn("Failed to determine automatic cherry-picks.")
+		}
+	}
+
+	if !foundCherryPickComments && !foundCherryPickLabels && len(autoBranches) == 0 {
 		return log, nil
 	}
 
@@ -418,24 +614,32 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 	}
 
 	// Figure out membership.
-	if !s.allowAll {
-		// TODO: Possibly cache this.
+	if !settings.allowAll {
 		logins, err := s.listTrustedUsers(org, repo)
 		if err != nil {
 			return log, err
 		}
-		for requestor := range requestorToComments {
-			isTrusted := slices.Contains(logins, requestor)
+		for requestor, branches := range requestorToComments {
+			isTrusted := containsLogin(logins, requestor)
 			if !isTrusted {
+				for _, ic := range branches {
+					recordRequest(ic, outcomeUntrustedUser)
+				}
 				delete(requestorToComments, requestor)
 			}
 		}
 	}
 
+	// Automatic cherry-picks are requested by the policy of the repository, so they are not subject to membership.
+	if len(autoBranches) > 0 {
+		s.requestAutoCherryPicks(log, pr, autoBranches, autoReason, requestorToComments)
+	}
+
 	// Handle multiple comments serially. Make sure to filter out
 	// comments targeting the same branch.
 	handledBranches := make(map[string]bool)
 	var errs []error
+	var jobs []loggedJob
 	for requestor, branches := range requestorToComments {
 		for targetBranch, ic := range branches {
 			if handledBranches[targetBranch] {
@@ -443,6 +647,7 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 				continue
 			}
 			if targetBranch == baseBranch {
+				recordRequest(ic, outcomeInvalidBranch)
 				resp := fmt.Sprintf("base branch (%s) needs to differ from target branch (%s)", baseBranch, targetBranch)
 				log.Info(resp)
 				if err := s.createComment(log, org, repo, num, ic, resp); err != nil {
@@ -456,14 +661,51 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 				"target_branch": targetBranch,
 			})
 			branchLog.Debug("Cherrypick request.")
+			target := parseTarget(org, repo, targetBranch)
+			if target.crossRepo() {
+				untrusted, err := s.untrustedTargets(org, repo, requestor, cherrypickCommands{targetBranch: nil})
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if resp, ok := untrusted[targetBranch]; ok {
+					recordRequest(ic, outcomeUntrustedUser)
+					branchLog.Info(resp)
+					if err := s.createComment(branchLog, org, repo, num, ic, resp); err != nil {
+						branchLog.WithError(err).WithField("response", resp).Error("Failed to create comment.")
+					}
+					continue
+				}
+			}
 			var chainedBranches []string
 			if branches, ok := targetBranchToChainBranches[targetBranch]; ok {
 				chainedBranches = branches
 			}
-			err := s.handle(branchLog, pr.User.Login, requestor, ic, org, repo, targetBranch, baseBranch, chainedBranches, title, body, num, pr.Labels)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("failed to create cherrypick: %w", err))
+			job := &cherryPickJob{
+				Org:           org,
+				Repo:          repo,
+				Number:        num,
+				TargetBranch:  target.branch,
+				TargetOrg:     target.org,
+				TargetRepo:    target.repo,
+				BaseBranch:    baseBranch,
+				Author:        pr.User.Login,
+				Requestor:     requestor,
+				Comment:       ic,
+				ChainBranches: chainedBranches,
+				ChainOrigin:   chainOrigin(parent, targetBranch),
+				Title:         title,
+				Body:          body,
+				Labels:        pr.Labels,
+				MergeSHA:      *pr.MergeSHA,
+				Commits:       pr.Commits,
 			}
+			jobs = append(jobs, loggedJob{log: branchLog, job: job})
+		}
+	}
+	for _, err := range s.runJobs(jobs) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create cherrypick: %w", err))
 		}
 	}
 	return log, utilerrors.NewAggregate(errs)
@@ -471,26 +713,30 @@ func (s *Server) handlePullRequest(log logrus.FieldLogger, pre github.PullReques
 
 var cherryPickBranchFmt = "cherry-pick-%d-to-%s"
 
-func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, comment *github.IssueComment, org, repo, targetBranch, baseBranch string, chainBranches []string, title, body string, num int, labels []github.Label) error {
-	var lock *sync.Mutex
-	func() {
-		s.mapLock.Lock()
-		defer s.mapLock.Unlock()
-		if _, ok := s.lockMap[cherryPickRequest{org, repo, num, targetBranch}]; !ok {
-			if s.lockMap == nil {
-				s.lockMap = map[cherryPickRequest]*sync.Mutex{}
-			}
-			s.lockMap[cherryPickRequest{org, repo, num, targetBranch}] = &sync.Mutex{}
-		}
-		lock = s.lockMap[cherryPickRequest{org, repo, num, targetBranch}]
-	}()
-	lock.Lock()
-	defer lock.Unlock()
+// handle runs the given cherry-pick job. Failures which are reported on the PR without returning
+// an error are recorded in the job.
+func (s *Server) handle(logger logrus.FieldLogger, job *cherryPickJob) error {
+	org, repo, num := job.Org, job.Repo, job.Number
+	targetBranch, chainBranches := job.TargetBranch, job.ChainBranches
+	author, requestor, comment := job.Author, job.Requestor, job.Comment
+	title, body := job.Title, job.Body
+	settings := s.settings(org, repo)
+	// The PR is cherry-picked onto targetOrg/targetRepo, which differs from org/repo for targets in other repositories.
+	targetOrg, targetRepo := job.targetOrgRepo()
+
+	unlock := s.lockRequest(cherryPickRequest{org, repo, num, job.target()})
+	defer unlock()
+
+	// A previous attempt created the cherry-pick PR already, only its follow-up steps are retried.
+	if job.ResultPR != 0 {
+		return s.completeCherryPickPR(logger, job, job.ResultPR)
+	}
 
-	p, pushOrg, err := s.getPusherAndOrg(logger, org, repo)
+	p, pushOrg, err := s.getPusherAndOrg(logger, targetOrg, targetRepo)
 	if err != nil {
 		logger.WithError(err).Warn("failed get pusher")
-		resp := fmt.Sprintf("cannot decide how to push into %s/%s: %v", org, repo, err)
+		resp := fmt.Sprintf("cannot decide how to push into %s/%s: %v", targetOrg, targetRepo, err)
+		job.fail(resp)
 		return s.createComment(logger, org, repo, num, comment, resp)
 	}
 
@@ -500,9 +746,9 @@ func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, com
 
 	// Clone the repo, checkout the target branch.
 	startClone := time.Now()
-	r, err := s.gc.ClientFor(org, repo)
+	r, err := s.gc.ClientFor(targetOrg, targetRepo)
 	if err != nil {
-		return fmt.Errorf("failed to get git client for %s/%s: %w", org, repo, err)
+		return fmt.Errorf("failed to get git client for %s/%s: %w", targetOrg, targetRepo, err)
 	}
 	defer func() {
 		if err := r.Clean(); err != nil {
@@ -511,16 +757,30 @@ func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, com
 	}()
 	if err := r.Checkout(targetBranch); err != nil {
 		logger.WithError(err).Warn("failed to checkout target branch")
-		resp := fmt.Sprintf("cannot checkout `%s`: %v", targetBranch, err)
+		resp := fmt.Sprintf("cannot checkout `%s`: %v", job.target(), err)
+		job.fail(resp)
+		job.Outcome = outcomeInvalidBranch
 		return s.createComment(logger, org, repo, num, comment, resp)
 	}
 	logger.WithField("duration", time.Since(startClone)).Info("Cloned and checked out target branch.")
-
-	// Fetch the patch from GitHub
-	localPath, err := s.getPatch(org, repo, targetBranch, num)
-	if err != nil {
-		logger.WithError(err).Errorf("Failed to get patch for %s/%s#%d", org, repo, num)
-		return s.createComment(logger, org, repo, num, comment, fmt.Sprintf("Failed to get PR patch from GitHub. This PR will need to be manually cherrypicked.\n<details><summary>Error message</summary>%v</details>", err))
+	observePhase(phaseClone, startClone)
+
+	// Fetch the patch from GitHub, unless the merged commits are cherry-picked. The merged commits are
+	// not part of other repositories, so their cherry-picks always apply the patch.
+	commitMode := s.cherryPicksCommits(org, repo) && !job.cherryPickTarget().crossRepo()
+	var localPath string
+	if !commitMode {
+		localPath, err = s.getPatch(org, repo, targetBranch, num)
+		if err != nil {
+			logger.WithError(err).Errorf("Failed to get patch for %s/%s#%d", org, repo, num)
+			job.fail(fmt.Sprintf("failed to get PR patch from GitHub: %v", err))
+			return s.createComment(logger, org, repo, num, comment, fmt.Sprintf("Failed to get PR patch from GitHub. This PR will need to be manually cherrypicked.\n<details><summary>Error message</summary>%v</details>", err))
+		}
+		defer func() {
+			if err := os.Remove(localPath); err != nil {
+				logger.WithError(err).Warn("Failed to remove patch file.")
+			}
+		}()
 	}
 
 	if err := r.Config("user.name", s.botUser.Login); err != nil {
@@ -535,58 +795,93 @@ func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, com
 	}
 
 	// New branch for the cherry-pick.
-	newBranch := fmt.Sprintf(cherryPickBranchFmt, num, targetBranch)
+	newBranch := job.newBranch()
 
 	// Check if that branch already exists, which means there is already a PR for that cherry-pick.
 	if r.BranchExists(newBranch) {
 		// Find the PR and link to it.
-		prs, err := s.ghc.GetPullRequests(org, repo)
+		prs, err := s.ghc.GetPullRequests(targetOrg, targetRepo)
 		if err != nil {
-			return fmt.Errorf("failed to get pullrequests for %s/%s: %w", org, repo, err)
+			return fmt.Errorf("failed to get pullrequests for %s/%s: %w", targetOrg, targetRepo, err)
 		}
 		for _, pr := range prs {
 			if pr.Head.Ref == fmt.Sprintf("%s:%s", s.botUser.Login, newBranch) {
 				logger.WithField("preexisting_cherrypick", pr.HTMLURL).Info("PR already has cherrypick")
 				resp := fmt.Sprintf("Looks like #%d has already been cherry picked in %s", num, pr.HTMLURL)
-				return s.createComment(logger, org, repo, num, comment, resp)
+				if err := s.createComment(logger, org, repo, num, comment, resp); err != nil {
+					return err
+				}
+				job.ResultPR = pr.Number
+				return nil
 			}
 		}
 	}
 
+	// Look for cherry-picks which were created manually or pushed to another branch.
+	existing, err := s.findExistingCherryPick(logger, job)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to search for existing cherry-picks.")
+	} else if existing != nil {
+		logger.WithField("preexisting_cherrypick", existing.HTMLURL).Info("PR already has cherrypick")
+		resp := fmt.Sprintf("Looks like #%d has already been cherry picked in %s", num, existing.HTMLURL)
+		if err := s.createComment(logger, org, repo, num, comment, resp); err != nil {
+			return err
+		}
+		job.ResultPR = existing.Number
+		return nil
+	}
+
 	// Create the branch for the cherry-pick.
 	if err := r.CheckoutNewBranch(newBranch); err != nil {
 		return fmt.Errorf("failed to checkout %s: %w", newBranch, err)
 	}
+	base, err := r.RevParse("HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to parse HEAD of %s: %w", newBranch, err)
+	}
 
 	// Title for GitHub issue/PR.
-	titleTargetBranchIndicator := fmt.Sprintf(titleTargetBranchIndicatorTemplate, targetBranch)
-	title = fmt.Sprintf("%s%s", titleTargetBranchIndicator, omitBaseBranchFromTitle(title, baseBranch))
-
-	// Apply the patch.
-	if err := r.Am(localPath); err != nil {
-		errs := []error{fmt.Errorf("failed to `git am`: %w", err)}
-		logger.WithError(err).Warn("failed to apply PR on top of target branch")
-		resp := fmt.Sprintf("#%d failed to apply on top of branch %q:\n```\n%v\n```", num, targetBranch, err)
-		if err := s.createComment(logger, org, repo, num, comment, resp); err != nil {
-			errs = append(errs, fmt.Errorf("failed to create comment: %w", err))
-		}
+	title = settings.title(job.titleData())
 
-		if s.issueOnConflict {
-			resp = fmt.Sprintf("Manual cherrypick required.\n\n%v", resp)
-			if err := s.createIssue(logger, org, repo, title, resp, num, comment, nil, []string{requestor}); err != nil {
-				errs = append(errs, fmt.Errorf("failed to create issue: %w", err))
-			}
+	startApply := time.Now()
+	if commitMode {
+		// Cherry-pick the merged commits.
+		args, err := s.commitCherryPickArgs(logger, r, job)
+		if err != nil {
+			return err
+		}
+		if conflicts, err := cherryPick(r.Directory(), args...); err != nil {
+			return s.handleConflict(logger, job, r, p, pushOrg, newBranch, title, err, conflicts)
+		}
+	} else if err := r.Am(localPath); err != nil {
+		// The patch does not apply, fall back to cherry-picking the merged commits.
+		logger.WithError(err).Info("failed to apply PR patch, cherry-picking merged commits")
+		if err := s.fetchMergeCommit(r, job); err != nil {
+			return err
+		}
+		if conflicts, err := s.cherryPickMergeCommit(r.Directory(), job); err != nil {
+			return s.handleConflict(logger, job, r, p, pushOrg, newBranch, title, err, conflicts)
 		}
+	}
 
-		return utilerrors.NewAggregate(errs)
+	// Add sign-off and trailers to the cherry-picked commits.
+	rewrite, err := s.commitRewrite(logger, r, job)
+	if err != nil {
+		return err
+	}
+	if err := rewriteCommits(r.Directory(), strings.TrimSpace(base), rewrite); err != nil {
+		return fmt.Errorf("failed to rewrite cherry-picked commits: %w", err)
 	}
+	observePhase(phaseApply, startApply)
 
 	// Push the new branch
+	startPush := time.Now()
 	if err := p.Push(r, newBranch, true); err != nil {
 		logger.WithError(err).Warn("failed to push chery-picked changes to GitHub")
 		resp := fmt.Sprintf("failed to push cherry-picked changes in GitHub: %v", err)
-		return utilerrors.NewAggregate([]error{err, s.createComment(logger, org, repo, num, comment, resp)})
+		return utilerrors.NewAggregate([]error{fmt.Errorf("%w: %w", errPush, err), s.createRetriedFailureComment(logger, job, resp)})
 	}
+	observePhase(phasePush, startPush)
 
 	// Open a PR in GitHub.
 	var kindLabels []string
@@ -595,26 +890,31 @@ func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, com
 	} else {
 		kindLabels = kindLabelsFromIssueLabels(labels)
 	}
-	var cherryPickBody string
-	if s.prowAssignments {
-		cherryPickBody = cherrypicker.CreateCherrypickBody(num, requestor, releaseNoteFromParentPR(author, org, repo, num, body), chainBranches, kindLabels)
-	} else {
-		cherryPickBody = cherrypicker.CreateCherrypickBody(num, "", releaseNoteFromParentPR(author, org, repo, num, body), chainBranches, kindLabels)
+	// releaseNote returns the release notes of the parent PR for the target branch, referring to the given PR of refOrg/refRepo.
+	releaseNote := func(refOrg, refRepo string, ref int) string {
+		return formatReleaseNotes(settings.releaseNoteRules.apply(parentReleaseNotes(author, refOrg, refRepo, ref, body), targetBranch))
 	}
-
-	head := fmt.Sprintf("%s:%s", pushOrg, newBranch)
-	if pushOrg == org {
-		head = newBranch
+	bodyData := bodyData{titleData: job.titleData(), Author: author, Requestor: requestor}
+	if settings.prowAssignments {
+		bodyData.Body = cherrypicker.CreateCherrypickBody(num, requestor, releaseNote(org, repo, num), chainBranches, kindLabels)
+	} else {
+		bodyData.Body = cherrypicker.CreateCherrypickBody(num, "", releaseNote(org, repo, num), chainBranches, kindLabels)
 	}
+	cherryPickBody := settings.body(job.crossRepoBody(bodyData))
 
-	createdNum, err := s.ghc.CreatePullRequest(org, repo, title, cherryPickBody, head, targetBranch, true)
+	createdNum, err := s.ghc.CreatePullRequest(targetOrg, targetRepo, title, cherryPickBody, prHead(targetOrg, pushOrg, newBranch), targetBranch, true)
 	if err != nil {
 		logger.WithError(err).Warn("failed to create new pull request")
 		resp := fmt.Sprintf("new pull request could not be created: %v", err)
-		return utilerrors.NewAggregate([]error{err, s.createComment(logger, org, repo, num, comment, resp)})
+		return utilerrors.NewAggregate([]error{err, s.createRetriedFailureComment(logger, job, resp)})
+	}
+	// Record the cherry-pick PR right away, so that retries of the follow-up steps do not create it again.
+	job.ResultPR = createdNum
+	if err := s.jobs.put(*job); err != nil {
+		logger.WithError(err).Warn("Failed to persist cherry-pick job.")
 	}
 	logger = logger.WithField("new_pull_request_number", createdNum)
-	resp := fmt.Sprintf("new pull request created: #%d", createdNum)
+	resp := fmt.Sprintf("new pull request created: %s", job.targetRef(createdNum))
 	logger.Info("new pull request created")
 	if err := s.createComment(logger, org, repo, num, comment, resp); err != nil {
 		logger.WithError(err).Warn("failed to create comment")
@@ -623,34 +923,53 @@ func (s *Server) handle(logger logrus.FieldLogger, author, requestor string, com
 	// Hence, we update the cherry-pick PR with the correct release notes after its creation.
 	logger.Info("updating PR references in release notes of cherry-pick pull request")
 	prUpdateErrorResponse := fmt.Sprintf("Failed updating the PR references in release notes. Please change the references manually from #%d to #%d", num, createdNum)
-	createdPR, err := s.ghc.GetPullRequest(org, repo, createdNum)
+	createdPR, err := s.ghc.GetPullRequest(targetOrg, targetRepo, createdNum)
 	if err == nil {
-		if s.prowAssignments {
-			cherryPickBody = cherrypicker.CreateCherrypickBody(num, requestor, releaseNoteFromParentPR(author, org, repo, createdNum, body), chainBranches, kindLabels)
+		if settings.prowAssignments {
+			bodyData.Body = cherrypicker.CreateCherrypickBody(num, requestor, releaseNote(targetOrg, targetRepo, createdNum), chainBranches, kindLabels)
 		} else {
-			cherryPickBody = cherrypicker.CreateCherrypickBody(num, "", releaseNoteFromParentPR(author, org, repo, createdNum, body), chainBranches, kindLabels)
+			bodyData.Body = cherrypicker.CreateCherrypickBody(num, "", releaseNote(targetOrg, targetRepo, createdNum), chainBranches, kindLabels)
 		}
-		createdPR.Body = cherryPickBody
-		if _, err := s.ghc.EditPullRequest(org, repo, createdNum, createdPR); err != nil {
-			logger.WithError(utilerrors.NewAggregate([]error{err, s.ghc.CreateComment(org, repo, createdNum, prUpdateErrorResponse)})).Warn("failed to update cherry-pick pull request")
+		createdPR.Body = settings.body(job.crossRepoBody(bodyData))
+		if _, err := s.ghc.EditPullRequest(targetOrg, targetRepo, createdNum, createdPR); err != nil {
+			logger.WithError(utilerrors.NewAggregate([]error{err, s.ghc.CreateComment(targetOrg, targetRepo, createdNum, prUpdateErrorResponse)})).Warn("failed to update cherry-pick pull request")
 		}
 	} else {
-		logger.WithError(utilerrors.NewAggregate([]error{err, s.ghc.CreateComment(org, repo, createdNum, prUpdateErrorResponse)})).Warn("failed to get cherry-pick pull request")
+		logger.WithError(utilerrors.NewAggregate([]error{err, s.ghc.CreateComment(targetOrg, targetRepo, createdNum, prUpdateErrorResponse)})).Warn("failed to get cherry-pick pull request")
 	}
-	for _, label := range s.labels {
-		if err := s.ghc.AddLabel(org, repo, createdNum, label); err != nil {
-			return fmt.Errorf("failed to add label %s: %w", label, err)
-		}
+	return s.completeCherryPickPR(logger, job, createdNum)
+}
+
+// completeCherryPickPR adds the labels and assignees to the created cherry-pick PR. Labels which the PR
+// has already are skipped, so that the steps can be retried after a failure.
+func (s *Server) completeCherryPickPR(logger logrus.FieldLogger, job *cherryPickJob, createdNum int) error {
+	settings := s.settings(job.Org, job.Repo)
+	targetOrg, targetRepo := job.targetOrgRepo()
+
+	labels, err := s.ghc.GetIssueLabels(targetOrg, targetRepo, createdNum)
+	if err != nil {
+		return fmt.Errorf("failed to get labels of %s: %w", job.targetRef(createdNum), err)
 	}
+	existing := sets.New[string]()
 	for _, label := range labels {
+		existing.Insert(label.Name)
+	}
+	toAdd := append([]string{}, settings.labels...)
+	for _, label := range job.Labels {
 		if strings.HasPrefix(label.Name, "area/") || strings.HasPrefix(label.Name, "kind/") {
-			if err := s.ghc.AddLabel(org, repo, createdNum, label.Name); err != nil {
-				return fmt.Errorf("failed to add label %s: %w", label, err)
-			}
+			toAdd = append(toAdd, label.Name)
+		}
+	}
+	for _, label := range toAdd {
+		if existing.Has(label) {
+			continue
+		}
+		if err := s.ghc.AddLabel(targetOrg, targetRepo, createdNum, label); err != nil {
+			return fmt.Errorf("failed to add label %s: %w", label, err)
 		}
 	}
-	if s.prowAssignments {
-		if err := s.ghc.AssignIssue(org, repo, createdNum, []string{requestor}); err != nil {
+	if settings.prowAssignments {
+		if err := s.ghc.AssignIssue(targetOrg, targetRepo, createdNum, []string{job.Requestor}); err != nil {
 			logger.WithError(err).Warn("failed to assign to new PR")
 			// Ignore returning errors on failure to assign as this is most likely
 			// due to users not being members of the org so that they can't be assigned
@@ -683,6 +1002,15 @@ func omitBaseBranchFromTitle(title, baseBranch string) string {
 	return strings.Replace(title, fmt.Sprintf(titleTargetBranchIndicatorTemplate, baseBranch), "", 1)
 }
 
+// createRetriedFailureComment reports a failure of an attempt which is retried. It is only posted on the
+// last attempt, so that the PR does not get the same failure comment for every retry.
+func (s *Server) createRetriedFailureComment(l logrus.FieldLogger, job *cherryPickJob, resp string) error {
+	if job.Attempts < s.maxAttempts {
+		return nil
+	}
+	return s.createComment(l, job.Org, job.Repo, job.Number, job.Comment, resp)
+}
+
 func (s *Server) createComment(l logrus.FieldLogger, org, repo string, num int, comment *github.IssueComment, resp string) error {
 	if err := func() error {
 		if comment != nil {
@@ -698,8 +1026,8 @@ func (s *Server) createComment(l logrus.FieldLogger, org, repo string, num int,
 }
 
 // createIssue creates an issue on GitHub.
-func (s *Server) createIssue(l logrus.FieldLogger, org, repo, title, body string, num int, comment *github.IssueComment, labels, assignees []string) error {
-	issueNum, err := s.ghc.CreateIssue(org, repo, title, body, 0, labels, assignees)
+func (s *Server) createIssue(l logrus.FieldLogger, org, repo, title, body string, num int, comment *github.IssueComment, labels, assignees []string, milestone int) error {
+	issueNum, err := s.ghc.CreateIssue(org, repo, title, body, milestone, labels, assignees)
 	if err != nil {
 		return s.createComment(l, org, repo, num, comment, fmt.Sprintf("new issue could not be created for failed cherrypick: %v", err))
 	}
@@ -744,7 +1072,14 @@ func (s *Server) getPatch(org, repo, targetBranch string, num int) (string, erro
 
 // Check if the user is trusted and should be allowed to perform cherry-picks
 func (s *Server) isTrustedUser(org, repo, login string) (bool, error) {
-	if s.onlyOrgMembers {
+	if s.trustedUsers != nil {
+		logins, err := s.listTrustedUsers(org, repo)
+		if err != nil {
+			return false, err
+		}
+		return containsLogin(logins, login), nil
+	}
+	if s.settings(org, repo).onlyOrgMembers {
 		// Only members should be able to do cherry-picks.
 		return s.ghc.IsMember(org, login)
 	}
@@ -773,25 +1108,30 @@ func kindLabelsFromIssueLabels(labels []github.Label) []string {
 
 // List all trusted users of the repository
 func (s *Server) listTrustedUsers(org, repo string) ([]string, error) {
-	logins := []string{}
-	if s.onlyOrgMembers {
-		members, err := s.ghc.ListOrgMembers(org, "all")
+	if s.settings(org, repo).onlyOrgMembers {
+		return s.trustedUsers.get(org, func() ([]string, error) {
+			logins := []string{}
+			members, err := s.ghc.ListOrgMembers(org, "all")
+			if err != nil {
+				return nil, err
+			}
+			for _, member := range members {
+				logins = append(logins, member.Login)
+			}
+			return logins, nil
+		})
+	}
+	return s.trustedUsers.get(org+"/"+repo, func() ([]string, error) {
+		logins := []string{}
+		collaborators, err := s.ghc.ListCollaborators(org, repo)
 		if err != nil {
 			return nil, err
 		}
-		for _, member := range members {
-			logins = append(logins, member.Login)
+		for _, collaborator := range collaborators {
+			logins = append(logins, collaborator.Login)
 		}
 		return logins, nil
-	}
-	collaborators, err := s.ghc.ListCollaborators(org, repo)
-	if err != nil {
-		return nil, err
-	}
-	for _, collaborator := range collaborators {
-		logins = append(logins, collaborator.Login)
-	}
-	return logins, nil
+	})
 }
 
 func normalize(input string) string {
@@ -802,30 +1142,21 @@ func normalize(input string) string {
 // parent PR and formats it as per the PR template so that
 // it can be copied to the cherry-pick PR.
 func releaseNoteFromParentPR(prAuthor, org, repo string, num int, body string) string {
-	var output strings.Builder
-	potentialMatches := releaseNoteRe.FindAllStringSubmatch(body, -1)
-	for i, potentialMatch := range potentialMatches {
-		source := strings.TrimSpace(potentialMatch[4])
-		ref := strings.TrimSpace(potentialMatch[5])
-		if source == "" || ref == "" {
-			source = fmt.Sprintf("github.com/%s/%s", org, repo)
-			ref = fmt.Sprintf("#%d", num)
-		}
-		author := strings.TrimSpace(potentialMatch[6])
-		if author == "" {
-			author = fmt.Sprintf("@%s", prAuthor)
-		}
-		fmt.Fprintf(&output, "```%s %s %s %s %s\n%s\n```",
-			strings.TrimSpace(potentialMatch[2]),
-			strings.TrimSpace(potentialMatch[3]),
-			source,
-			ref,
-			author,
-			strings.TrimSpace(potentialMatch[7]),
-		)
-		if i+1 < len(potentialMatches) {
-			output.WriteString("\n")
-		}
-	}
-	return output.String()
+	return formatReleaseNotes(parentReleaseNotes(prAuthor, org, repo, num, body))
+}
+
+// parentReleaseNotes returns the release notes of the parent PR. Notes without a source and
+// reference refer to the PR num of org/repo, notes without an author to the PR author.
+func parentReleaseNotes(prAuthor, org, repo string, num int, body string) []releaseNote {
+	notes := parseReleaseNotes(body)
+	for i := range notes {
+		if notes[i].Source == "" || notes[i].Reference == "" {
+			notes[i].Source = fmt.Sprintf("github.com/%s/%s", org, repo)
+			notes[i].Reference = fmt.Sprintf("#%d", num)
+		}
+		if notes[i].Author == "" {
+			notes[i].Author = fmt.Sprintf("@%s", prAuthor)
+		}
+	}
+	return notes
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -24,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 
 	"sigs.k8s.io/prow/pkg/git/localgit"
@@ -53,6 +55,13 @@ type fghc struct {
 	prLabels   []github.Label
 	orgMembers []github.TeamMember
 	issues     []github.Issue
+	mutations  []githubql.Input
+	branches   []github.Branch
+	fullRepo   github.FullRepo
+	prCommits  []github.RepositoryCommit
+	mergedPRs  []github.Issue
+	milestones []github.Milestone
+	statuses   []github.Status
 }
 
 func (f *fghc) AddLabel(_, _ string, number int, label string) error {
@@ -111,6 +120,18 @@ func (f *fghc) GetPullRequests(_, _ string) ([]github.PullRequest, error) {
 	return f.prs, nil
 }
 
+func (f *fghc) ListMilestones(_, _ string) ([]github.Milestone, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.milestones, nil
+}
+
+func (f *fghc) FindIssuesWithOrg(_, _, _ string, _ bool) ([]github.Issue, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.mergedPRs, nil
+}
+
 func (f *fghc) EditPullRequest(_, _ string, num int, pullRequest *github.PullRequest) (*github.PullRequest, error) {
 	f.Lock()
 	defer f.Unlock()
@@ -145,7 +166,7 @@ func (f *fghc) IsCollaborator(_, _, _ string) (bool, error) {
 func (f *fghc) GetRepo(_, _ string) (github.FullRepo, error) {
 	f.Lock()
 	defer f.Unlock()
-	return github.FullRepo{}, nil
+	return f.fullRepo, nil
 }
 
 func (f *fghc) EnsureFork(_, _, repo string) (string, error) {
@@ -171,7 +192,7 @@ func prToString(pr github.PullRequest) string {
 	return fmt.Sprintf(expectedFmt, pr.Title, pr.Body, pr.Head.Ref, pr.Base.Ref, labels)
 }
 
-func (f *fghc) CreateIssue(_, _, title, body string, _ int, labels, assignees []string) (int, error) {
+func (f *fghc) CreateIssue(_, _, title, body string, milestone int, labels, assignees []string) (int, error) {
 	f.Lock()
 	defer f.Unlock()
 
@@ -200,11 +221,19 @@ func (f *fghc) CreateIssue(_, _, title, body string, _ int, labels, assignees []
 		Number:    num,
 		Labels:    ghLabels,
 		Assignees: ghAssignees,
+		Milestone: github.Milestone{Number: milestone},
 	})
 
 	return num, nil
 }
 
+func (f *fghc) CreateStatus(_, _, _ string, s github.Status) error {
+	f.Lock()
+	defer f.Unlock()
+	f.statuses = append(f.statuses, s)
+	return nil
+}
+
 func (f *fghc) CreatePullRequest(_, _, title, body, head, base string, _ bool) (int, error) {
 	f.Lock()
 	defer f.Unlock()
@@ -233,6 +262,24 @@ func (f *fghc) ListIssueComments(_, _ string, _ int) ([]github.IssueComment, err
 	return f.prComments, nil
 }
 
+func (f *fghc) ListPRCommits(_, _ string, _ int) ([]github.RepositoryCommit, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.prCommits, nil
+}
+
+func (f *fghc) EditComment(_, _ string, id int, comment string) error {
+	f.Lock()
+	defer f.Unlock()
+	for i := range f.prComments {
+		if f.prComments[i].ID == id {
+			f.prComments[i].Body = comment
+			return nil
+		}
+	}
+	return fmt.Errorf("comment %d not found", id)
+}
+
 func (f *fghc) GetIssueLabels(_, _ string, _ int) ([]github.Label, error) {
 	f.Lock()
 	defer f.Unlock()
@@ -263,6 +310,19 @@ func (f *fghc) CreateFork(_, repo string) (string, error) {
 	return repo, nil
 }
 
+func (f *fghc) GetBranches(_, _ string, _ bool) ([]github.Branch, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.branches, nil
+}
+
+func (f *fghc) MutateWithGitHubAppsSupport(_ context.Context, _ any, input githubql.Input, _ map[string]any, _ string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.mutations = append(f.mutations, input)
+	return nil
+}
+
 var initialFiles = map[string][]byte{
 	"bar.go": []byte(`// Package bar does an interesting thing.
 package bar
@@ -348,6 +408,7 @@ func testCherryPickIC(clients localgit.Clients, t *testing.T) {
 		},
 		isMember: true,
 		patch:    patch,
+		branches: []github.Branch{{Name: "master"}, {Name: "stage"}},
 		prLabels: []github.Label{
 			{Name: "kind/bug"},
 			{Name: "kind/cleanup"},
@@ -926,7 +987,7 @@ func TestCherryPickCreateIssue(t *testing.T) {
 			ghc: ghc,
 		}
 
-		if err := s.createIssue(logrus.WithField("test", t.Name()), tc.org, tc.repo, tc.title, tc.body, tc.prNum, nil, tc.labels, tc.assignees); err != nil {
+		if err := s.createIssue(logrus.WithField("test", t.Name()), tc.org, tc.repo, tc.title, tc.body, tc.prNum, nil, tc.labels, tc.assignees, 0); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1007,6 +1068,7 @@ func testCherryPickPRAssignments(clients localgit.Clients, t *testing.T) {
 			},
 			isMember: true,
 			patch:    patch,
+			branches: []github.Branch{{Name: "master"}, {Name: "stage"}},
 		}
 		ic := github.IssueCommentEvent{
 			Action: github.IssueCommentActionCreated,
@@ -1074,13 +1136,13 @@ func TestHandleLocks(t *testing.T) {
 
 	go func() {
 		defer close(routine1Done)
-		if err := s.handle(l, "", "", &github.IssueComment{}, "org", "repo", "targetBranch", "baseBranch", []string{}, "title", "body", 0, []github.Label{}); err != nil {
+		if err := s.handle(l, &cherryPickJob{Org: "org", Repo: "repo", TargetBranch: "targetBranch", BaseBranch: "baseBranch", Comment: &github.IssueComment{}, Title: "title", Body: "body"}); err != nil {
 			t.Errorf("routine failed: %v", err)
 		}
 	}()
 	go func() {
 		defer close(routine2Done)
-		if err := s.handle(l, "", "", &github.IssueComment{}, "org", "repo", "targetBranch", "baseBranch", []string{}, "title", "body", 0, []github.Label{}); err != nil {
+		if err := s.handle(l, &cherryPickJob{Org: "org", Repo: "repo", TargetBranch: "targetBranch", BaseBranch: "baseBranch", Comment: &github.IssueComment{}, Title: "title", Body: "body"}); err != nil {
 			t.Errorf("routine failed: %v", err)
 		}
 	}()
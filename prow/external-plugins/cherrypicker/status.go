@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// jobStatus is the public view of a cherry-pick job served by the status endpoint.
+type jobStatus struct {
+	Org          string   `json:"org"`
+	Repo         string   `json:"repo"`
+	Number       int      `json:"number"`
+	TargetBranch string   `json:"targetBranch"`
+	Requestor    string   `json:"requestor"`
+	State        jobState `json:"state"`
+	Attempts     int      `json:"attempts"`
+	ResultPR     int      `json:"resultPR,omitempty"`
+	// ResultRepo is the org/repo of the cherry-pick PR, which is the target repository of the job.
+	ResultRepo  string    `json:"resultRepo,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Cherrypicker status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+td.reason { max-width: 40em; white-space: pre-wrap; font-family: monospace; font-size: small; }
+</style>
+</head>
+<body>
+<h1>Cherry-picks</h1>
+{{- if not . }}
+<p>No cherry-picks.</p>
+{{- else }}
+<table>
+<tr><th>Repository</th><th>PR</th><th>Target branch</th><th>Requestor</th><th>State</th><th>Attempts</th><th>Result</th><th>Created</th><th>Updated</th><th>Reason</th></tr>
+{{- range . }}
+<tr>
+<td>{{ .Org }}/{{ .Repo }}</td>
+<td><a href="https://github.com/{{ .Org }}/{{ .Repo }}/pull/{{ .Number }}">#{{ .Number }}</a></td>
+<td>{{ .TargetBranch }}</td>
+<td>{{ .Requestor }}</td>
+<td>{{ .State }}</td>
+<td>{{ .Attempts }}</td>
+<td>{{ if .ResultPR }}<a href="https://github.com/{{ .ResultRepo }}/pull/{{ .ResultPR }}">{{ .ResultRepo }}#{{ .ResultPR }}</a>{{ end }}</td>
+<td>{{ .CreatedAt.Format "2006-01-02 15:04:05 MST" }}</td>
+<td>{{ .UpdatedAt.Format "2006-01-02 15:04:05 MST" }}</td>
+<td class="reason">{{ .Reason }}</td>
+</tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+// ServeStatus serves the cherry-pick jobs as HTML page, or as JSON if the format query parameter is
+// "json". The jobs can be filtered with the org, repo and state query parameters.
+func (s *Server) ServeStatus(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	org, repo, state := query.Get("org"), query.Get("repo"), jobState(query.Get("state"))
+
+	statuses := []jobStatus{}
+	for _, job := range s.jobs.list() {
+		if (org != "" && job.Org != org) || (repo != "" && job.Repo != repo) || (state != "" && job.State != state) {
+			continue
+		}
+		status := jobStatus{
+			Org:          job.Org,
+			Repo:         job.Repo,
+			Number:       job.Number,
+			TargetBranch: job.target(),
+			Requestor:    job.Requestor,
+			State:        job.State,
+			Attempts:     job.Attempts,
+			ResultPR:     job.ResultPR,
+			Reason:       job.Reason,
+			CreatedAt:    job.CreatedAt,
+			UpdatedAt:    job.UpdatedAt,
+			NextAttempt:  job.NextAttempt,
+		}
+		if job.ResultPR != 0 {
+			targetOrg, targetRepo := job.targetOrgRepo()
+			status.ResultRepo = targetOrg + "/" + targetRepo
+		}
+		statuses = append(statuses, status)
+	}
+
+	if query.Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			s.log.WithError(err).Warn("Failed to write status.")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, statuses); err != nil {
+		s.log.WithError(err).Warn("Failed to render status.")
+	}
+}
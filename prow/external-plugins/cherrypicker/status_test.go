@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestServeStatus(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, job := range []cherryPickJob{
+		{Org: "foo", Repo: "bar", Number: 1, TargetBranch: "release-1.0", Requestor: "wiseguy", State: jobStateSucceeded, Attempts: 1, ResultPR: 2, CreatedAt: now, UpdatedAt: now},
+		{Org: "foo", Repo: "baz", Number: 3, TargetBranch: "release-1.0", Requestor: "wiseguy", State: jobStateFailed, Attempts: 3, Reason: "<push failed>", CreatedAt: now.Add(time.Minute), UpdatedAt: now},
+		{Org: "foo", Repo: "bar", Number: 4, TargetBranch: "release-1.0", TargetOrg: "other", TargetRepo: "fork", Requestor: "wiseguy", State: jobStateSucceeded, Attempts: 1, ResultPR: 5, CreatedAt: now.Add(2 * time.Minute), UpdatedAt: now},
+	} {
+		if err := js.put(job); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	s := &Server{jobs: js, log: logrus.WithField("test", t.Name())}
+
+	rec := httptest.NewRecorder()
+	s.ServeStatus(rec, httptest.NewRequest("GET", "/status?format=json&repo=baz", nil))
+	var got []jobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []jobStatus{{Org: "foo", Repo: "baz", Number: 3, TargetBranch: "release-1.0", Requestor: "wiseguy", State: jobStateFailed, Attempts: 3, Reason: "<push failed>", CreatedAt: now.Add(time.Minute), UpdatedAt: now}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected status (-want +got):\n%s", diff)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeStatus(rec, httptest.NewRequest("GET", "/status", nil))
+	html := rec.Body.String()
+	for _, want := range []string{`<a href="https://github.com/foo/bar/pull/2">foo/bar#2</a>`, `<a href="https://github.com/other/fork/pull/5">other/fork#5</a>`, "foo/baz", "&lt;push failed&gt;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected status page to contain %q, got:\n%s", want, html)
+		}
+	}
+}
+
+func TestPendingJobs(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ghc := &fghc{
+		prs:      []github.PullRequest{{Number: 2, Base: github.PullRequestBranch{Ref: "master"}, User: github.User{Login: "developer"}}},
+		isMember: true,
+		branches: []github.Branch{{Name: "master"}, {Name: "release-1.0"}},
+	}
+	s := &Server{ghc: ghc, jobs: js, allowAll: true, log: logrus.WithField("test", t.Name())}
+
+	ic := github.IssueCommentEvent{
+		Action: github.IssueCommentActionCreated,
+		Repo:   github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"},
+		Issue:  github.Issue{Number: 2, State: "open", PullRequest: &struct{}{}},
+		Comment: github.IssueComment{
+			Body: "/cherrypick release-1.0",
+			User: github.User{Login: "wiseguy"},
+		},
+	}
+	if _, err := s.handleIssueComment(s.log, ic); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobs := js.list()
+	if len(jobs) != 1 || jobs[0].State != jobStatePending || jobs[0].Requestor != "wiseguy" || jobs[0].TargetBranch != "release-1.0" {
+		t.Fatalf("expected one pending job, got %+v", jobs)
+	}
+
+	pre := github.PullRequestEvent{
+		Action: github.PullRequestActionClosed,
+		PullRequest: github.PullRequest{
+			Number: 2,
+			Base:   github.PullRequestBranch{Ref: "master", Repo: github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"}},
+		},
+	}
+	if _, err := s.handlePullRequest(s.log, pre); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jobs := js.list(); len(jobs) != 0 {
+		t.Errorf("expected pending jobs of closed PR to be removed, got %+v", jobs)
+	}
+}
+
+func TestSkippedPendingJobs(t *testing.T) {
+	t.Parallel()
+	js, err := newJobStore("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	if err := js.put(cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "release-1.0", Requestor: "wiseguy", State: jobStatePending, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The request is not found anymore when the PR merges, e.g. because the comment was deleted.
+	s := &Server{ghc: &fghc{}, jobs: js, allowAll: true, log: logrus.WithField("test", t.Name())}
+
+	mergeSHA := "abcdef"
+	pre := github.PullRequestEvent{
+		Action: github.PullRequestActionClosed,
+		PullRequest: github.PullRequest{
+			Number:   2,
+			Merged:   true,
+			MergeSHA: &mergeSHA,
+			Base:     github.PullRequestBranch{Ref: "master", Repo: github.Repo{Owner: github.User{Login: "foo"}, Name: "bar"}},
+		},
+	}
+	if _, err := s.handlePullRequest(s.log, pre); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobs := js.list()
+	if len(jobs) != 1 || jobs[0].State != jobStateFailed || jobs[0].Reason == "" {
+		t.Fatalf("expected skipped pending job to fail, got %+v", jobs)
+	}
+
+	if err := js.prune(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if jobs := js.list(); len(jobs) != 0 {
+		t.Errorf("expected failed pending job to be pruned, got %+v", jobs)
+	}
+}
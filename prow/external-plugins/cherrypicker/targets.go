@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// repoTargetRe matches cherry-pick targets in another repository, e.g. `other-org/other-repo:release-v1.2`.
+var repoTargetRe = regexp.MustCompile(`^([\w.-]+)/([\w.-]+):(\S+)$`)
+
+// cherryPickTarget is the branch a PR is cherry-picked onto. Org and repo are only set if the
+// branch belongs to another repository than the PR.
+type cherryPickTarget struct {
+	org    string
+	repo   string
+	branch string
+}
+
+// parseTarget parses the target of a cherrypick command on a PR of org/repo, which is either a
+// branch of the same repository or a branch of another repository as `other-org/other-repo:branch`.
+func parseTarget(org, repo, target string) cherryPickTarget {
+	match := repoTargetRe.FindStringSubmatch(target)
+	if match == nil {
+		return cherryPickTarget{branch: target}
+	}
+	if strings.EqualFold(match[1], org) && strings.EqualFold(match[2], repo) {
+		return cherryPickTarget{branch: match[3]}
+	}
+	return cherryPickTarget{org: match[1], repo: match[2], branch: match[3]}
+}
+
+// crossRepo returns true if the target belongs to another repository than the PR.
+func (t cherryPickTarget) crossRepo() bool {
+	return t.org != ""
+}
+
+// orgRepo returns the repository of the target for a PR of org/repo.
+func (t cherryPickTarget) orgRepo(org, repo string) (string, string) {
+	if t.crossRepo() {
+		return t.org, t.repo
+	}
+	return org, repo
+}
+
+// withBranch returns the given branch of the target repository as used in cherrypick commands.
+func (t cherryPickTarget) withBranch(branch string) string {
+	if t.crossRepo() {
+		return fmt.Sprintf("%s/%s:%s", t.org, t.repo, branch)
+	}
+	return branch
+}
+
+func (t cherryPickTarget) String() string {
+	return t.withBranch(t.branch)
+}
+
+// cherryPickTarget returns the target of the job.
+func (j *cherryPickJob) cherryPickTarget() cherryPickTarget {
+	return cherryPickTarget{org: j.TargetOrg, repo: j.TargetRepo, branch: j.TargetBranch}
+}
+
+// target returns the target of the job as used in cherrypick commands.
+func (j *cherryPickJob) target() string {
+	return j.cherryPickTarget().String()
+}
+
+// targetOrgRepo returns the repository the job cherry-picks onto.
+func (j *cherryPickJob) targetOrgRepo() (string, string) {
+	return j.cherryPickTarget().orgRepo(j.Org, j.Repo)
+}
+
+// originRef returns a reference to the original PR, which can be used in the target repository.
+func (j *cherryPickJob) originRef() string {
+	if j.TargetOrg != "" {
+		return fmt.Sprintf("%s/%s#%d", j.Org, j.Repo, j.Number)
+	}
+	return fmt.Sprintf("#%d", j.Number)
+}
+
+// targetRef returns a reference to the given PR of the target repository, which can be used on the
+// original PR.
+func (j *cherryPickJob) targetRef(num int) string {
+	if j.TargetOrg != "" {
+		return fmt.Sprintf("%s/%s#%d", j.TargetOrg, j.TargetRepo, num)
+	}
+	return fmt.Sprintf("#%d", num)
+}
+
+// newBranch returns the name of the branch of the cherry-pick. It contains the original repository
+// if the job cherry-picks onto another repository, so that cherry-picks of PRs of different
+// repositories do not clash in the fork of the target repository.
+func (j *cherryPickJob) newBranch() string {
+	if j.TargetOrg != "" {
+		return fmt.Sprintf("cherry-pick-%s-%s-%d-to-%s", j.Org, j.Repo, j.Number, j.TargetBranch)
+	}
+	return fmt.Sprintf(cherryPickBranchFmt, j.Number, j.TargetBranch)
+}
+
+// untrustedTargets checks whether the requestor may cherry-pick into the repositories of the targets
+// in other repositories. It returns a description per target the requestor is not trusted for.
+func (s *Server) untrustedTargets(org, repo, requestor string, commands cherrypickCommands) (map[string]string, error) {
+	untrusted := make(map[string]string)
+	for target := range commands {
+		t := parseTarget(org, repo, target)
+		if !t.crossRepo() {
+			continue
+		}
+		ok, err := s.trustedFunc(t.org, t.repo)(requestor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check trust of %s in %s/%s: %w", requestor, t.org, t.repo, err)
+		}
+		if !ok {
+			untrusted[target] = fmt.Sprintf("@%s may not cherry-pick into `%s/%s`, only its org members or collaborators may", requestor, t.org, t.repo)
+		}
+	}
+	return untrusted, nil
+}
+
+// crossRepoBody qualifies the reference to the original PR in the body of a cherry-pick PR, if the
+// PR is opened in another repository.
+func (j *cherryPickJob) crossRepoBody(data bodyData) bodyData {
+	if j.TargetOrg != "" {
+		data.Body = strings.Replace(data.Body, fmt.Sprintf("cherry-pick of #%d", j.Number), "cherry-pick of "+j.originRef(), 1)
+	}
+	return data
+}
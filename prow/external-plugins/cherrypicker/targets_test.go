@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTarget(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		target   string
+		expected cherryPickTarget
+	}{
+		{target: "release-v1.2", expected: cherryPickTarget{branch: "release-v1.2"}},
+		{target: "release/v1.2", expected: cherryPickTarget{branch: "release/v1.2"}},
+		{target: "baz/qux:release-v1.2", expected: cherryPickTarget{org: "baz", repo: "qux", branch: "release-v1.2"}},
+		{target: "Foo/bar:release-v1.2", expected: cherryPickTarget{branch: "release-v1.2"}},
+	}
+	for _, tc := range testCases {
+		if diff := cmp.Diff(tc.expected, parseTarget("foo", "bar", tc.target), cmp.AllowUnexported(cherryPickTarget{})); diff != "" {
+			t.Errorf("%s: unexpected target (-want +got):\n%s", tc.target, diff)
+		}
+	}
+}
+
+func TestCrossRepoJob(t *testing.T) {
+	t.Parallel()
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetOrg: "baz", TargetRepo: "qux", TargetBranch: "release-v1.2"}
+	if got, want := job.target(), "baz/qux:release-v1.2"; got != want {
+		t.Errorf("expected target %q, got %q", want, got)
+	}
+	if got, want := job.newBranch(), "cherry-pick-foo-bar-2-to-release-v1.2"; got != want {
+		t.Errorf("expected branch %q, got %q", want, got)
+	}
+	if got, want := job.targetRef(5), "baz/qux#5"; got != want {
+		t.Errorf("expected reference %q, got %q", want, got)
+	}
+	body := job.crossRepoBody(bodyData{Body: "This is an automated cherry-pick of #2"}).Body
+	if got, want := body, "This is an automated cherry-pick of foo/bar#2"; got != want {
+		t.Errorf("expected body %q, got %q", want, got)
+	}
+
+	job.TargetOrg, job.TargetRepo = "", ""
+	if got, want := job.newBranch(), "cherry-pick-2-to-release-v1.2"; got != want {
+		t.Errorf("expected branch %q, got %q", want, got)
+	}
+	if got, want := job.targetRef(5), "#5"; got != want {
+		t.Errorf("expected reference %q, got %q", want, got)
+	}
+}
+
+func TestUntrustedTargets(t *testing.T) {
+	t.Parallel()
+	s := &Server{ghc: &fghc{}}
+	commands := cherrypickCommands{"release-v1.2": nil, "baz/qux:release-v1.2": nil}
+	untrusted, err := s.untrustedTargets("foo", "bar", "someone", commands)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"baz/qux:release-v1.2": "@someone may not cherry-pick into `baz/qux`, only its org members or collaborators may"}
+	if diff := cmp.Diff(expected, untrusted); diff != "" {
+		t.Errorf("unexpected untrusted targets (-want +got):\n%s", diff)
+	}
+
+	s.ghc = &fghc{isMember: true}
+	untrusted, err = s.untrustedTargets("foo", "bar", "someone", commands)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(untrusted) > 0 {
+		t.Errorf("expected no untrusted targets, got %v", untrusted)
+	}
+}
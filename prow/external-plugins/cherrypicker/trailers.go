@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/git/v2"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// trailerRe matches a trailer line of a commit message, e.g. `Signed-off-by: Jane Doe <jane@example.com>`.
+var trailerRe = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+
+// commitRewrite describes how the cherry-picked commits are rewritten before they are pushed.
+type commitRewrite struct {
+	// signOff adds a Signed-off-by trailer of the bot.
+	signOff bool
+	// resetAuthor makes the bot the author of the commits instead of keeping the original authors.
+	resetAuthor bool
+	// coAuthors are added as Co-authored-by trailers, unless they authored the commit.
+	coAuthors []github.CommitAuthor
+	// cherryPickedFrom are the original commits, which are referenced by a
+	// `(cherry picked from commit <sha>)` line. A single commit is referenced by all rewritten commits.
+	cherryPickedFrom []string
+}
+
+func (cr commitRewrite) empty() bool {
+	return !cr.signOff && !cr.resetAuthor && len(cr.coAuthors) == 0 && len(cr.cherryPickedFrom) == 0
+}
+
+// commitRewrite returns how the cherry-picked commits of the job are rewritten according to the
+// settings of the target repository, which the commits are pushed to.
+func (s *Server) commitRewrite(logger logrus.FieldLogger, r git.RepoClient, job *cherryPickJob) (commitRewrite, error) {
+	settings := s.settings(job.targetOrgRepo())
+	cr := commitRewrite{
+		signOff:     settings.signOff,
+		resetAuthor: settings.resetAuthor,
+	}
+
+	if settings.coAuthors {
+		commits, err := s.ghc.ListPRCommits(job.Org, job.Repo, job.Number)
+		if err != nil {
+			return cr, fmt.Errorf("failed to list commits of %s/%s#%d: %w", job.Org, job.Repo, job.Number, err)
+		}
+		seen := sets.New[string]()
+		for _, commit := range commits {
+			author := commit.Commit.Author
+			if author.Email == "" || seen.Has(strings.ToLower(author.Email)) {
+				continue
+			}
+			seen.Insert(strings.ToLower(author.Email))
+			cr.coAuthors = append(cr.coAuthors, author)
+		}
+	}
+
+	if settings.cherryPickedFrom && job.MergeSHA != "" {
+		cr.cherryPickedFrom = []string{job.MergeSHA}
+		// The commits of rebase merges are referenced individually.
+		if job.MergeMethod == github.MergeRebase && job.Commits > 1 {
+			out, err := runGit(r.Directory(), "rev-list", "--reverse", fmt.Sprintf("%s~%d..%s", job.MergeSHA, job.Commits, job.MergeSHA))
+			if err != nil {
+				logger.WithError(err).Warn("Failed to list rebased commits, referencing the merge commit instead.")
+			} else {
+				cr.cherryPickedFrom = strings.Fields(out)
+			}
+		}
+	}
+	return cr, nil
+}
+
+// rewriteCommits rewrites the commits between base and HEAD of the repository in dir. The commits
+// are cherry-picked onto base again and amended with the trailers, so that the result has the same
+// trees. The committer and the sign-off are taken from the git configuration of the repository.
+func rewriteCommits(dir, base string, cr commitRewrite) error {
+	if cr.empty() {
+		return nil
+	}
+	out, err := runGit(dir, "rev-list", "--reverse", base+"..HEAD")
+	if err != nil {
+		return err
+	}
+	commits := strings.Fields(out)
+	if len(commits) == 0 {
+		return nil
+	}
+
+	msgFile, err := os.CreateTemp("", "cherry-pick-message")
+	if err != nil {
+		return fmt.Errorf("failed to create commit message file: %w", err)
+	}
+	msgFile.Close()
+	defer os.Remove(msgFile.Name())
+
+	if _, err := runGit(dir, "reset", "--hard", base); err != nil {
+		return err
+	}
+	for i, commit := range commits {
+		if _, err := runGit(dir, "cherry-pick", "--allow-empty", "--keep-redundant-commits", commit); err != nil {
+			return err
+		}
+		message, err := runGit(dir, "log", "-1", "--format=%B", commit)
+		if err != nil {
+			return err
+		}
+		authorEmail, err := runGit(dir, "log", "-1", "--format=%ae", commit)
+		if err != nil {
+			return err
+		}
+
+		var origin string
+		switch {
+		case len(cr.cherryPickedFrom) == len(commits):
+			origin = cr.cherryPickedFrom[i]
+		case len(cr.cherryPickedFrom) > 0:
+			origin = cr.cherryPickedFrom[len(cr.cherryPickedFrom)-1]
+		}
+		if err := os.WriteFile(msgFile.Name(), []byte(rewriteMessage(message, origin)), 0o600); err != nil {
+			return fmt.Errorf("failed to write commit message: %w", err)
+		}
+
+		args := []string{"interpret-trailers", "--in-place", "--if-exists", "addIfDifferent"}
+		for _, coAuthor := range cr.coAuthors {
+			if !cr.resetAuthor && strings.EqualFold(coAuthor.Email, strings.TrimSpace(authorEmail)) {
+				continue
+			}
+			args = append(args, "--trailer", fmt.Sprintf("Co-authored-by: %s <%s>", coAuthor.Name, coAuthor.Email))
+		}
+		if _, err := runGit(dir, append(args, msgFile.Name())...); err != nil {
+			return err
+		}
+
+		args = []string{"commit", "--amend", "--allow-empty", "--no-verify", "-F", msgFile.Name()}
+		if cr.signOff {
+			args = append(args, "--signoff")
+		}
+		if cr.resetAuthor {
+			args = append(args, "--reset-author")
+		}
+		if _, err := runGit(dir, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteMessage appends the reference to the original commit to the commit message like
+// `git cherry-pick -x`, unless it is already there.
+func rewriteMessage(message, origin string) string {
+	message = strings.TrimRight(message, "\n") + "\n"
+	if origin == "" {
+		return message
+	}
+	line := fmt.Sprintf("(cherry picked from commit %s)", origin)
+	if strings.Contains(message, line) {
+		return message
+	}
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	if last := lines[len(lines)-1]; len(lines) > 1 && (trailerRe.MatchString(last) || strings.HasPrefix(last, "(cherry picked from commit ")) {
+		// Keep the trailer block together.
+		return message + line + "\n"
+	}
+	return message + "\n" + line + "\n"
+}
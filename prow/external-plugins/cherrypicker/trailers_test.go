@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestRewriteMessage(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		message  string
+		origin   string
+		expected string
+	}{
+		{
+			name:     "no origin",
+			message:  "Fix X\n\n",
+			expected: "Fix X\n",
+		},
+		{
+			name:     "title only",
+			message:  "Fix X\n",
+			origin:   "abc",
+			expected: "Fix X\n\n(cherry picked from commit abc)\n",
+		},
+		{
+			name:     "trailers",
+			message:  "Fix X\n\nSigned-off-by: Jane Doe <jane@example.com>\n",
+			origin:   "abc",
+			expected: "Fix X\n\nSigned-off-by: Jane Doe <jane@example.com>\n(cherry picked from commit abc)\n",
+		},
+		{
+			name:     "already referenced",
+			message:  "Fix X\n\n(cherry picked from commit abc)\n",
+			origin:   "abc",
+			expected: "Fix X\n\n(cherry picked from commit abc)\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if diff := cmp.Diff(tc.expected, rewriteMessage(tc.message, tc.origin)); diff != "" {
+				t.Errorf("unexpected message (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCommitRewriteTargetSettings(t *testing.T) {
+	t.Parallel()
+	signOff := true
+	s := &Server{config: &configAgent{config: &pluginConfig{Orgs: map[string]orgConfig{
+		"other": {Repos: map[string]overrides{"fork": {SignOff: &signOff}}},
+	}}}}
+	log := logrus.WithField("test", t.Name())
+
+	job := &cherryPickJob{Org: "foo", Repo: "bar", Number: 2, TargetBranch: "release-1.0"}
+	cr, err := s.commitRewrite(log, nil, job)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cr.signOff {
+		t.Error("expected commits in foo/bar not to be signed off")
+	}
+
+	// The commits are pushed to the target repository, hence its settings apply.
+	job.TargetOrg, job.TargetRepo = "other", "fork"
+	if cr, err = s.commitRewrite(log, nil, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cr.signOff {
+		t.Error("expected commits in other/fork to be signed off")
+	}
+}
+
+func TestRewriteCommits(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	mustGit(t, dir, "init", "-b", "master")
+	mustGit(t, dir, "config", "user.email", "ci-robot@example.com")
+	mustGit(t, dir, "config", "user.name", "ci-robot")
+	commitFile(t, dir, "file", "a\n")
+	base := mustGit(t, dir, "rev-parse", "HEAD")
+
+	for _, file := range []string{"one", "two"} {
+		commitFile(t, dir, file, file+"\n")
+		mustGit(t, dir, "commit", "--amend", "--no-edit", "--author", "Jane Doe <jane@example.com>")
+	}
+	tree := mustGit(t, dir, "rev-parse", "HEAD^{tree}")
+
+	cr := commitRewrite{
+		signOff: true,
+		coAuthors: []github.CommitAuthor{
+			{Name: "Jane Doe", Email: "jane@example.com"},
+			{Name: "John Doe", Email: "john@example.com"},
+		},
+		cherryPickedFrom: []string{"aaa", "bbb"},
+	}
+	if err := rewriteCommits(dir, base, cr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mustGit(t, dir, "rev-parse", "HEAD^{tree}"); got != tree {
+		t.Errorf("expected tree %s to be kept, got %s", tree, got)
+	}
+	if got := mustGit(t, dir, "log", "--format=%an", base+"..HEAD"); got != "Jane Doe\nJane Doe" {
+		t.Errorf("expected original authors to be kept, got %q", got)
+	}
+	expected := "change two\n\n(cherry picked from commit bbb)\nCo-authored-by: John Doe <john@example.com>\nSigned-off-by: ci-robot <ci-robot@example.com>"
+	if diff := cmp.Diff(expected, mustGit(t, dir, "log", "-1", "--format=%B")); diff != "" {
+		t.Errorf("unexpected commit message (-want +got):\n%s", diff)
+	}
+	if got := mustGit(t, dir, "log", "-1", "--format=%B", "HEAD~1"); got != "change one\n\n(cherry picked from commit aaa)\nCo-authored-by: John Doe <john@example.com>\nSigned-off-by: ci-robot <ci-robot@example.com>" {
+		t.Errorf("unexpected commit message of first commit: %q", got)
+	}
+
+	if err := rewriteCommits(dir, base, commitRewrite{resetAuthor: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := mustGit(t, dir, "log", "--format=%an", base+"..HEAD"); got != "ci-robot\nci-robot" {
+		t.Errorf("expected bot to be the author, got %q", got)
+	}
+}
@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// trustCache caches the org members and repository collaborators. The entries expire after the TTL.
+// Member, membership and organization events invalidate them earlier, but they are not guaranteed to
+// be delivered, so the TTL is the only reliable invalidation.
+type trustCache struct {
+	ttl time.Duration
+	// Used for unit testing
+	now func() time.Time
+
+	lock sync.Mutex
+	// org -> members, org/repo -> collaborators
+	entries map[string]trustEntry
+}
+
+type trustEntry struct {
+	logins  []string
+	expires time.Time
+}
+
+func newTrustCache(ttl time.Duration) *trustCache {
+	return &trustCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]trustEntry),
+	}
+}
+
+// get returns the cached logins of the key or lists and caches them if they are missing or expired.
+// A nil cache always lists the logins.
+func (c *trustCache) get(key string, list func() ([]string, error)) ([]string, error) {
+	if c == nil {
+		return list()
+	}
+
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	c.lock.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.logins, nil
+	}
+
+	logins, err := list()
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[key] = trustEntry{logins: logins, expires: c.now().Add(c.ttl)}
+	return logins, nil
+}
+
+// invalidate removes the cached members of the org and the collaborators of the repository. If repo
+// is empty, the collaborators of all repositories of the org are removed.
+func (c *trustCache) invalidate(org, repo string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, org)
+	if repo != "" {
+		delete(c.entries, org+"/"+repo)
+		return
+	}
+	for key := range c.entries {
+		if strings.HasPrefix(key, org+"/") {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// trustEvent holds the fields of member, membership and organization events which identify the
+// changed org or repository.
+type trustEvent struct {
+	Action string       `json:"action"`
+	Repo   *github.Repo `json:"repository,omitempty"`
+	Org    *struct {
+		Login string `json:"login"`
+	} `json:"organization,omitempty"`
+}
+
+// handleTrustEvent invalidates the cached trusted users affected by a member, membership or
+// organization event.
+func (s *Server) handleTrustEvent(log logrus.FieldLogger, payload []byte) error {
+	var event trustEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	var org, repo string
+	switch {
+	case event.Repo != nil:
+		org, repo = event.Repo.Owner.Login, event.Repo.Name
+	case event.Org != nil:
+		org = event.Org.Login
+	default:
+		return nil
+	}
+	log.WithFields(logrus.Fields{
+		github.OrgLogField:  org,
+		github.RepoLogField: repo,
+		"action":            event.Action,
+	}).Debug("Invalidating cached trusted users.")
+	s.trustedUsers.invalidate(org, repo)
+	return nil
+}
+
+// containsLogin returns true if login is in logins. GitHub logins are case-insensitive.
+func containsLogin(logins []string, login string) bool {
+	for _, l := range logins {
+		if strings.EqualFold(l, login) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestTrustCache(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	c := newTrustCache(time.Minute)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	list := func() ([]string, error) {
+		calls++
+		return []string{"wiseguy"}, nil
+	}
+	get := func(key string) {
+		t.Helper()
+		if logins, err := c.get(key, list); err != nil || len(logins) != 1 {
+			t.Fatalf("expected cached logins without error, got %v, %v", logins, err)
+		}
+	}
+
+	get("foo/bar")
+	get("foo/bar")
+	if calls != 1 {
+		t.Errorf("expected logins to be listed once, got %d calls", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	get("foo/bar")
+	if calls != 2 {
+		t.Errorf("expected expired logins to be listed again, got %d calls", calls)
+	}
+
+	get("foo")
+	get("foo/baz")
+	get("other/bar")
+	calls = 0
+	c.invalidate("foo", "")
+	for _, key := range []string{"foo", "foo/bar", "foo/baz", "other/bar"} {
+		get(key)
+	}
+	if calls != 3 {
+		t.Errorf("expected the org and its repos to be invalidated, got %d calls", calls)
+	}
+
+	calls = 0
+	c.invalidate("foo", "bar")
+	for _, key := range []string{"foo", "foo/bar", "foo/baz"} {
+		get(key)
+	}
+	if calls != 2 {
+		t.Errorf("expected the org and the repo to be invalidated, got %d calls", calls)
+	}
+}
+
+func TestHandleTrustEvent(t *testing.T) {
+	t.Parallel()
+	ghc := &fghc{orgMembers: []github.TeamMember{{Login: "wiseguy"}}}
+	s := &Server{ghc: ghc, log: logrus.StandardLogger(), trustedUsers: newTrustCache(time.Hour)}
+
+	if ok, err := s.isTrustedUser("foo", "bar", "WiseGuy"); err != nil || !ok {
+		t.Fatalf("expected collaborator to be trusted, got %t, %v", ok, err)
+	}
+	ghc.orgMembers = nil
+	if ok, _ := s.isTrustedUser("foo", "bar", "wiseguy"); !ok {
+		t.Error("expected cached collaborator to be trusted")
+	}
+
+	payload := []byte(`{"action": "removed", "repository": {"name": "bar", "owner": {"login": "foo"}}}`)
+	if err := s.handleEvent("member", "guid", payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := s.isTrustedUser("foo", "bar", "wiseguy"); ok {
+		t.Error("expected removed collaborator not to be trusted")
+	}
+
+	ghc.orgMembers = []github.TeamMember{{Login: "wiseguy"}}
+	s.onlyOrgMembers = true
+	if ok, _ := s.isTrustedUser("foo", "bar", "wiseguy"); !ok {
+		t.Error("expected org member to be trusted")
+	}
+	ghc.orgMembers = nil
+	if err := s.handleTrustEvent(logrus.WithField("test", t.Name()), []byte(`{"action": "member_removed", "organization": {"login": "foo"}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := s.isTrustedUser("foo", "bar", "wiseguy"); ok {
+		t.Error("expected removed org member not to be trusted")
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// errQueueFull is returned by submit if all workers are busy and the queue is full.
+	errQueueFull = errors.New("event queue is full")
+	// errStopped is returned by submit once the worker pool is stopped.
+	errStopped = errors.New("worker pool is stopped")
+)
+
+// workerPool handles webhook events with a fixed number of workers. Events which arrive while all
+// workers are busy wait in a bounded queue.
+type workerPool struct {
+	lock    sync.RWMutex
+	stopped bool
+	queue   chan func()
+	// pending tracks the queued and running tasks.
+	pending sync.WaitGroup
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	p := &workerPool{queue: make(chan func(), queueSize)}
+	for range workers {
+		go func() {
+			for task := range p.queue {
+				task()
+				p.pending.Done()
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues the task. It fails if the queue is full or the pool is stopped.
+func (p *workerPool) submit(task func()) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.stopped {
+		return errStopped
+	}
+	p.pending.Add(1)
+	select {
+	case p.queue <- task:
+		return nil
+	default:
+		p.pending.Done()
+		return errQueueFull
+	}
+}
+
+// stop stops accepting new tasks and waits until all queued and running tasks are done or the
+// context is cancelled.
+func (p *workerPool) stop(ctx context.Context) error {
+	p.lock.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestLock serializes the cherry-picks of the same PR onto the same branch. It is removed from
+// the lock map as soon as no cherry-pick holds or waits for it.
+type requestLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockRequest locks the given cherry-pick request and returns the function to unlock it.
+func (s *Server) lockRequest(req cherryPickRequest) func() {
+	s.mapLock.Lock()
+	lock, ok := s.lockMap[req]
+	if !ok {
+		if s.lockMap == nil {
+			s.lockMap = map[cherryPickRequest]*requestLock{}
+		}
+		lock = &requestLock{}
+		s.lockMap[req] = lock
+	}
+	lock.refs++
+	s.mapLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		s.mapLock.Lock()
+		defer s.mapLock.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(s.lockMap, req)
+		}
+	}
+}
+
+// submit handles the event with the worker pool, or in a new goroutine if there is none.
+func (s *Server) submit(handle func()) error {
+	if s.workers == nil {
+		go handle()
+		return nil
+	}
+	return s.workers.submit(handle)
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWorkerPool(t *testing.T) {
+	t.Parallel()
+	p := newWorkerPool(2, 1)
+
+	release := make(chan struct{})
+	var running, done atomic.Int32
+	task := func() {
+		running.Add(1)
+		<-release
+		done.Add(1)
+	}
+	// Occupy both workers, so that the next task is queued.
+	for i := range int32(2) {
+		if err := p.submit(task); err != nil {
+			t.Fatalf("unexpected error submitting task %d: %v", i, err)
+		}
+		for running.Load() <= i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if err := p.submit(task); err != nil {
+		t.Fatalf("unexpected error queueing task: %v", err)
+	}
+	if err := p.submit(task); !errors.Is(err, errQueueFull) {
+		t.Fatalf("expected %v, got %v", errQueueFull, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected stop to time out while tasks are running, got %v", err)
+	}
+	if err := p.submit(task); !errors.Is(err, errStopped) {
+		t.Fatalf("expected %v, got %v", errStopped, err)
+	}
+
+	close(release)
+	if err := p.stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := done.Load(); got != 3 {
+		t.Errorf("expected all 3 submitted tasks to be drained, got %d", got)
+	}
+}
+
+func TestLockRequest(t *testing.T) {
+	t.Parallel()
+	s := &Server{}
+	req := cherryPickRequest{org: "foo", repo: "bar", pr: 2, targetBranch: "stage"}
+
+	unlock := s.lockRequest(req)
+	acquired := make(chan func())
+	go func() {
+		acquired <- s.lockRequest(req)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected second lock to wait for the first one")
+	case <-time.After(10 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+
+	if len(s.lockMap) != 0 {
+		t.Errorf("expected idle lock to be evicted, got %d entries", len(s.lockMap))
+	}
+}
+
+func TestHandleEventRejectsUnqueuedEvents(t *testing.T) {
+	t.Parallel()
+	// Without workers and queue, every event is rejected.
+	s := &Server{workers: newWorkerPool(0, 0), log: logrus.WithField("test", t.Name())}
+	if err := s.handleEvent("pull_request", "guid", []byte(`{}`)); !errors.Is(err, errQueueFull) {
+		t.Fatalf("expected %v, got %v", errQueueFull, err)
+	}
+
+	if err := s.workers.stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.handleEvent("issue_comment", "guid", []byte(`{}`)); !errors.Is(err, errStopped) {
+		t.Fatalf("expected %v, got %v", errStopped, err)
+	}
+}
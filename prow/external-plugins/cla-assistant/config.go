@@ -0,0 +1,398 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// Types of CLA providers.
+const (
+	providerClaAssistant   string = "cla-assistant"
+	providerStatus         string = "status"
+	providerSignatureStore string = "signature-store"
+	providerAllowlist      string = "allowlist"
+)
+
+// pluginConfig is the configuration file of the plugin. The settings of an org override the default
+// settings and the settings of a repository override the settings of its org.
+type pluginConfig struct {
+	Default overrides            `json:"default,omitempty"`
+	Orgs    map[string]orgConfig `json:"orgs,omitempty"`
+}
+
+type orgConfig struct {
+	overrides
+	Repos map[string]overrides `json:"repos,omitempty"`
+}
+
+// overrides are the settings which can be configured per org and repository.
+type overrides struct {
+	// Provider determines whether the authors of a PR signed the CLA. Defaults to cla-assistant.io.
+	Provider *providerConfig `json:"provider,omitempty"`
+	// Labels are the labels of the CLA states. Defaults to `cla: yes` and `cla: no`.
+	Labels *labelsConfig `json:"labels,omitempty"`
+	// Comments are the templates of the comments in response to /cla.
+	Comments *commentsConfig `json:"comments,omitempty"`
+	// RemoveLabelsOnClose removes the CLA labels when a PR is closed. Defaults to false.
+	RemoveLabelsOnClose *bool `json:"removeLabelsOnClose,omitempty"`
+}
+
+// labelsConfig configures the labels of the CLA states. Empty labels default to `cla: yes` and `cla: no`.
+type labelsConfig struct {
+	// Yes is the label of PRs whose authors signed the CLA.
+	Yes string `json:"yes,omitempty"`
+	// No is the label of PRs with authors who did not sign the CLA.
+	No string `json:"no,omitempty"`
+}
+
+func (l *labelsConfig) validate() error {
+	if l.Yes != "" && strings.EqualFold(l.Yes, l.No) {
+		return fmt.Errorf("yes and no labels must differ, both are %q", l.Yes)
+	}
+	return nil
+}
+
+// commentsConfig configures the comments in response to /cla as Go templates with .Provider, .Org,
+// .Repo and .Number. Empty templates default to the English comments of the plugin.
+type commentsConfig struct {
+	// RecheckSucceeded is posted when the provider was asked to recheck the PR.
+	RecheckSucceeded string `json:"recheckSucceeded,omitempty"`
+	// RecheckFailed is posted when the provider could not be reached.
+	RecheckFailed string `json:"recheckFailed,omitempty"`
+}
+
+func (c *commentsConfig) validate() error {
+	if _, err := template.New("recheckSucceeded").Parse(c.RecheckSucceeded); err != nil {
+		return fmt.Errorf("invalid recheckSucceeded: %w", err)
+	}
+	if _, err := template.New("recheckFailed").Parse(c.RecheckFailed); err != nil {
+		return fmt.Errorf("invalid recheckFailed: %w", err)
+	}
+	return nil
+}
+
+// providerConfig configures the CLA provider of an org or repository.
+type providerConfig struct {
+	// Type is one of cla-assistant, status, signature-store or allowlist.
+	Type string `json:"type"`
+	// URL is the base URL of cla-assistant.io or of the signature store.
+	URL string `json:"url,omitempty"`
+	// Context is the commit status context of the cla-assistant and status providers. Defaults to
+	// license/cla for the cla-assistant provider.
+	Context string `json:"context,omitempty"`
+	// CheckRun is the name of the check run of the cla-assistant and status providers, for CLA services
+	// which report via check runs instead of commit statuses.
+	CheckRun string `json:"checkRun,omitempty"`
+	// RecheckURL is the URL requested by the status provider to recheck a PR, as Go template with
+	// .Org, .Repo and .Number. If empty, /cla does not trigger the provider.
+	RecheckURL string `json:"recheckURL,omitempty"`
+	// Path is the allowlist file, which lists the GitHub logins of the signers one per line.
+	Path string `json:"path,omitempty"`
+}
+
+func (p *providerConfig) validate() error {
+	switch p.Type {
+	case providerClaAssistant:
+	case providerStatus:
+		if p.Context == "" && p.CheckRun == "" {
+			return fmt.Errorf("context or checkRun must be set for provider type %s", p.Type)
+		}
+		if _, err := template.New("recheckURL").Parse(p.RecheckURL); err != nil {
+			return fmt.Errorf("invalid recheckURL: %w", err)
+		}
+	case providerSignatureStore:
+		if p.URL == "" {
+			return fmt.Errorf("url must be set for provider type %s", p.Type)
+		}
+	case providerAllowlist:
+		if p.Path == "" {
+			return fmt.Errorf("path must be set for provider type %s", p.Type)
+		}
+	default:
+		return fmt.Errorf("unknown provider type %q", p.Type)
+	}
+	return nil
+}
+
+func (o overrides) validate() error {
+	if o.Provider != nil {
+		if err := o.Provider.validate(); err != nil {
+			return fmt.Errorf("invalid provider: %w", err)
+		}
+	}
+	if o.Labels != nil {
+		if err := o.Labels.validate(); err != nil {
+			return fmt.Errorf("invalid labels: %w", err)
+		}
+	}
+	if o.Comments != nil {
+		if err := o.Comments.validate(); err != nil {
+			return fmt.Errorf("invalid comments: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *pluginConfig) validate() error {
+	if err := c.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for org, orgConfig := range c.Orgs {
+		if err := orgConfig.overrides.validate(); err != nil {
+			return fmt.Errorf("orgs.%s: %w", org, err)
+		}
+		for repo, repoConfig := range orgConfig.Repos {
+			if err := repoConfig.validate(); err != nil {
+				return fmt.Errorf("orgs.%s.repos.%s: %w", org, repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+// settings returns the effective settings of the given repository.
+func (c *pluginConfig) settings(org, repo string) overrides {
+	var settings overrides
+	apply := func(o overrides) {
+		if o.Provider != nil {
+			settings.Provider = o.Provider
+		}
+		if o.Labels != nil {
+			settings.Labels = o.Labels
+		}
+		if o.Comments != nil {
+			settings.Comments = o.Comments
+		}
+		if o.RemoveLabelsOnClose != nil {
+			settings.RemoveLabelsOnClose = o.RemoveLabelsOnClose
+		}
+	}
+	apply(c.Default)
+	if orgConfig, ok := c.Orgs[org]; ok {
+		apply(orgConfig.overrides)
+		if repoConfig, ok := orgConfig.Repos[repo]; ok {
+			apply(repoConfig)
+		}
+	}
+	return settings
+}
+
+// loadAllowlists reads the allowlist files of all allowlist providers of the configuration. It returns the
+// logins of every file by its path.
+func loadAllowlists(c *pluginConfig) (map[string]sets.Set[string], error) {
+	allowlists := map[string]sets.Set[string]{}
+	load := func(o overrides) error {
+		if o.Provider == nil || o.Provider.Type != providerAllowlist {
+			return nil
+		}
+		if _, ok := allowlists[o.Provider.Path]; ok {
+			return nil
+		}
+		logins, err := readAllowlist(o.Provider.Path)
+		if err != nil {
+			return err
+		}
+		allowlists[o.Provider.Path] = logins
+		return nil
+	}
+	if err := load(c.Default); err != nil {
+		return nil, err
+	}
+	for _, orgConfig := range c.Orgs {
+		if err := load(orgConfig.overrides); err != nil {
+			return nil, err
+		}
+		for _, repoConfig := range orgConfig.Repos {
+			if err := load(repoConfig); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return allowlists, nil
+}
+
+// configAgent loads the configuration file and the allowlist files of its providers, and reloads them if they
+// change.
+type configAgent struct {
+	path string
+
+	lock   sync.RWMutex
+	raw    []byte
+	config *pluginConfig
+	// allowlists maps the paths of the allowlist files to the lower case logins listed in them.
+	allowlists map[string]sets.Set[string]
+}
+
+// newConfigAgent creates a configAgent and loads the configuration file at path.
+func newConfigAgent(path string) (*configAgent, error) {
+	ca := &configAgent{path: path}
+	if _, err := ca.reload(); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// reload loads the configuration file and its allowlist files again. It returns true if the configuration
+// or one of the allowlists changed. An invalid configuration or an unreadable allowlist is rejected and the
+// previous configuration and allowlists are kept.
+func (ca *configAgent) reload() (bool, error) {
+	raw, err := os.ReadFile(ca.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config %s: %w", ca.path, err)
+	}
+
+	ca.lock.RLock()
+	c, previous := ca.config, ca.allowlists
+	configChanged := c == nil || !bytes.Equal(raw, ca.raw)
+	ca.lock.RUnlock()
+
+	if configChanged {
+		c = &pluginConfig{}
+		if err := yaml.UnmarshalStrict(raw, c); err != nil {
+			return false, fmt.Errorf("failed to parse config %s: %w", ca.path, err)
+		}
+		if err := c.validate(); err != nil {
+			return false, fmt.Errorf("invalid config %s: %w", ca.path, err)
+		}
+	}
+	allowlists, err := loadAllowlists(c)
+	if err != nil {
+		return false, fmt.Errorf("invalid config %s: %w", ca.path, err)
+	}
+	if !configChanged && maps.EqualFunc(allowlists, previous, sets.Set[string].Equal) {
+		return false, nil
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	ca.raw, ca.config, ca.allowlists = raw, c, allowlists
+	return true, nil
+}
+
+// get returns the current configuration.
+func (ca *configAgent) get() *pluginConfig {
+	if ca == nil {
+		return &pluginConfig{}
+	}
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	return ca.config
+}
+
+// allowlist returns the logins of the allowlist file at path.
+func (ca *configAgent) allowlist(path string) sets.Set[string] {
+	if ca == nil {
+		return nil
+	}
+	ca.lock.RLock()
+	defer ca.lock.RUnlock()
+	return ca.allowlists[path]
+}
+
+// usesClaAssistant returns true if the CLA provider of the given repository is cla-assistant.io.
+func (c *claAssistantPlugin) usesClaAssistant(org, repo string) bool {
+	pc := c.config.get().settings(org, repo).Provider
+	return pc == nil || pc.Type == providerClaAssistant
+}
+
+// labels returns the labels of the CLA states of the given repository.
+func (c *claAssistantPlugin) labels(org, repo string) (yes, no string) {
+	yes, no = labelClaYes, labelClaNo
+	if lc := c.config.get().settings(org, repo).Labels; lc != nil {
+		if lc.Yes != "" {
+			yes = lc.Yes
+		}
+		if lc.No != "" {
+			no = lc.No
+		}
+	}
+	return yes, no
+}
+
+// recheckComment renders the comment in response to /cla of the given repository. The default comment is
+// used if no template is configured or the template cannot be rendered.
+func (c *claAssistantPlugin) recheckComment(org, repo string, number int, provider claProvider, succeeded bool) string {
+	tmpl := ""
+	if cc := c.config.get().settings(org, repo).Comments; cc != nil {
+		tmpl = cc.RecheckFailed
+		if succeeded {
+			tmpl = cc.RecheckSucceeded
+		}
+	}
+	if tmpl != "" {
+		t, err := template.New("comment").Parse(tmpl)
+		if err == nil {
+			var b strings.Builder
+			err = t.Execute(&b, struct {
+				Provider string
+				Org      string
+				Repo     string
+				Number   int
+			}{provider.name(), org, repo, number})
+			if err == nil {
+				return b.String()
+			}
+		}
+		c.log.WithError(err).Warnf("Could not render comment template of %s/%s, using default comment.", org, repo)
+	}
+	if succeeded {
+		return fmt.Sprintf("Successfully reached out to %s to initialize recheck of PR #%v", provider.name(), number)
+	}
+	return fmt.Sprintf("Could not reach out to %s for rechecking PR #%v", provider.name(), number)
+}
+
+// provider returns the CLA provider of the given repository.
+func (c *claAssistantPlugin) provider(org, repo string) claProvider {
+	pc := c.config.get().settings(org, repo).Provider
+	if pc == nil {
+		pc = &providerConfig{Type: providerClaAssistant}
+	}
+
+	switch pc.Type {
+	case providerStatus:
+		displayName := pc.Context
+		if displayName == "" {
+			displayName = pc.CheckRun
+		}
+		p := &statusProvider{displayName: displayName, context: pc.Context, checkRun: pc.CheckRun, ghc: c.ghc, hc: c.hc, maxRetryTime: c.maxRetryTime}
+		if pc.RecheckURL != "" {
+			p.recheckURL = recheckURLTemplate(pc.RecheckURL)
+		}
+		return p
+	case providerSignatureStore:
+		return &signerProvider{displayName: "the CLA signature store", ghc: c.ghc, signed: signatureStoreSigned(c.hc, pc.URL)}
+	case providerAllowlist:
+		return &signerProvider{displayName: "the CLA allowlist", ghc: c.ghc, signed: allowlistSigned(c.config.allowlist(pc.Path))}
+	default:
+		baseURL, context := c.baseURL, claGithubContext
+		if pc.URL != "" {
+			baseURL = pc.URL
+		}
+		if pc.Context != "" {
+			context = pc.Context
+		}
+		return &statusProvider{
+			displayName:  "cla-assistant.io",
+			context:      context,
+			checkRun:     pc.CheckRun,
+			ghc:          c.ghc,
+			hc:           c.hc,
+			maxRetryTime: c.maxRetryTime,
+			recheckURL: func(org, repo string, number int) (string, error) {
+				return baseURL + fmt.Sprintf(claAssistantURLPath, org, repo, number), nil
+			},
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestConfigValidate(t *testing.T) {
+	type testCase struct {
+		name          string
+		provider      providerConfig
+		errorExpected bool
+	}
+
+	tests := []testCase{
+		{name: "cla-assistant", provider: providerConfig{Type: providerClaAssistant}},
+		{name: "status", provider: providerConfig{Type: providerStatus, Context: "EasyCLA", RecheckURL: "https://cla.example.com/{{.Org}}/{{.Repo}}/{{.Number}}"}},
+		{name: "status without context and check run", provider: providerConfig{Type: providerStatus}, errorExpected: true},
+		{name: "status with check run", provider: providerConfig{Type: providerStatus, CheckRun: "EasyCLA"}},
+		{name: "status with invalid recheck URL", provider: providerConfig{Type: providerStatus, Context: "EasyCLA", RecheckURL: "{{.Org"}, errorExpected: true},
+		{name: "signature store without URL", provider: providerConfig{Type: providerSignatureStore}, errorExpected: true},
+		{name: "allowlist without path", provider: providerConfig{Type: providerAllowlist}, errorExpected: true},
+		{name: "unknown type", provider: providerConfig{Type: "easycla"}, errorExpected: true},
+	}
+	invalidOverrides := map[string]overrides{
+		"equal labels":             {Labels: &labelsConfig{Yes: "cla", No: "CLA"}},
+		"invalid comment template": {Comments: &commentsConfig{RecheckFailed: "{{.Provider"}},
+		"invalid provider":         {Provider: &providerConfig{Type: providerAllowlist}},
+	}
+	for name, o := range invalidOverrides {
+		c := pluginConfig{Orgs: map[string]orgConfig{testOwner: {overrides: o}}}
+		assert.Error(t, c.validate(), name)
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				c := pluginConfig{Orgs: map[string]orgConfig{testOwner: {Repos: map[string]overrides{testRepo: {Provider: &test.provider}}}}}
+				err := c.validate()
+				if test.errorExpected {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+				}
+			})
+	}
+}
+
+// testConfigAgent returns a config agent with the given configuration and its allowlists.
+func testConfigAgent(t *testing.T, c *pluginConfig) *configAgent {
+	t.Helper()
+	allowlists, err := loadAllowlists(c)
+	assert.NoError(t, err)
+	return &configAgent{config: c, allowlists: allowlists}
+}
+
+func TestConfigAgent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	allowlist := filepath.Join(dir, "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\n"), 0600))
+	assert.NoError(t, os.WriteFile(path, []byte(`
+default:
+  provider:
+    type: cla-assistant
+orgs:
+  TestOrg:
+    provider:
+      type: signature-store
+      url: https://cla.example.com
+    repos:
+      test-repo:
+        provider:
+          type: allowlist
+          path: `+allowlist+`
+`), 0600))
+
+	ca, err := newConfigAgent(path)
+	assert.NoError(t, err)
+	assert.Equal(t, providerAllowlist, ca.get().settings(testOwner, testRepo).Provider.Type)
+	assert.Equal(t, providerSignatureStore, ca.get().settings(testOwner, "other-repo").Provider.Type)
+	assert.Equal(t, providerClaAssistant, ca.get().settings("other-org", testRepo).Provider.Type)
+	assert.True(t, ca.allowlist(allowlist).Has("alice"))
+
+	// A changed allowlist is reloaded together with the unchanged config.
+	changed, err := ca.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\nbob\n"), 0600))
+	changed, err = ca.reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, ca.allowlist(allowlist).Has("bob"))
+
+	// An unreadable allowlist is rejected and the previous allowlist is kept.
+	assert.NoError(t, os.Remove(allowlist))
+	changed, err = ca.reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.True(t, ca.allowlist(allowlist).Has("bob"))
+
+	// An invalid config is rejected and the previous config is kept.
+	assert.NoError(t, os.WriteFile(path, []byte("default:\n  provider:\n    type: unknown\n"), 0600))
+	changed, err = ca.reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, providerAllowlist, ca.get().settings(testOwner, testRepo).Provider.Type)
+}
+
+func TestConfiguredLabelsAndComments(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestConfiguredLabelsAndComments", pluginName)
+	removeLabelsOnClose := true
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = &configAgent{config: &pluginConfig{
+		Orgs: map[string]orgConfig{
+			testOwner: {
+				overrides: overrides{
+					Labels: &labelsConfig{Yes: "cla: signed"},
+					Comments: &commentsConfig{
+						RecheckSucceeded: "CLA-Prüfung von {{.Org}}/{{.Repo}}#{{.Number}} bei {{.Provider}} angestoßen",
+						RecheckFailed:    "{{.Unknown}}",
+					},
+					RemoveLabelsOnClose: &removeLabelsOnClose,
+				},
+			},
+		},
+	}}
+	ingestDataIntoFakeClient(p.fakeClient)
+
+	yes, no := p.plugin.labels(testOwner, testRepo)
+	assert.Equal(t, "cla: signed", yes)
+	assert.Equal(t, labelClaNo, no)
+
+	// The configured label is added.
+	se := github.StatusEvent{
+		Repo:    github.Repo{Owner: github.User{Login: testOwner}, Name: testRepo},
+		Context: claGithubContext,
+		State:   github.StatusSuccess,
+		SHA:     shaWithPR,
+	}
+	assert.NoError(t, p.plugin.handleStatusEvent(ctx, log, &se))
+	number := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPR)]
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, number, "cla: signed"))
+
+	// The comment is rendered from the template, an invalid template falls back to the default comment.
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, 1, true))
+	assert.Contains(t, p.fakeClient.IssueCommentsAdded, fmt.Sprintf("%s/%s#1:CLA-Prüfung von %s/%s#1 bei cla-assistant.io angestoßen", testOwner, testRepo, testOwner, testRepo))
+	assert.Equal(t, "Could not reach out to cla-assistant.io for rechecking PR #1", p.plugin.recheckComment(testOwner, testRepo, 1, p.plugin.provider(testOwner, testRepo), false))
+
+	// Both CLA labels are removed from closed PRs, other labels are kept.
+	pe := github.PullRequestEvent{
+		Action: github.PullRequestActionClosed,
+		Number: 42,
+		PullRequest: github.PullRequest{
+			Number: 42,
+			Labels: []github.Label{{Name: "cla: signed"}, {Name: labelClaNo}, {Name: "lgtm"}},
+		},
+		Repo: github.Repo{Owner: github.User{Login: testOwner}, Name: testRepo},
+	}
+	assert.NoError(t, p.plugin.handlePullRequestEvent(ctx, log, &pe))
+	assert.Equal(t, []string{
+		testLabelString(testOwner, testRepo, 42, "cla: signed"),
+		testLabelString(testOwner, testRepo, 42, labelClaNo),
+	}, p.fakeClient.IssueLabelsRemoved)
+
+	// Labels are kept by default.
+	p.fakeClient.IssueLabelsRemoved = nil
+	pe.Repo.Owner.Login = "other-org"
+	assert.NoError(t, p.plugin.handlePullRequestEvent(ctx, log, &pe))
+	assert.Nil(t, p.fakeClient.IssueLabelsRemoved)
+}
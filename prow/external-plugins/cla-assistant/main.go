@@ -33,6 +33,7 @@ type options struct {
 	logLevel               string
 
 	updatePeriod time.Duration
+	configPath   string
 
 	webhookSecretFile string
 }
@@ -53,6 +54,7 @@ func gatherOptions() options {
 	fs.IntVar(&o.port, "port", 8080, "Port HTTP server listens on.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
 	fs.DurationVar(&o.updatePeriod, "update-period", time.Hour*1, "Period duration for periodic scans of all PRs.")
+	fs.StringVar(&o.configPath, "config-path", "", "Path to the YAML configuration file with per-org and per-repo settings, e.g. the CLA provider. The file is reloaded when it changes.")
 	fs.StringVar(&o.webhookSecretFile, "hmac-secret-file", "/etc/webhook/hmac", "Path to the file containing the GitHub HMAC secret.")
 	fs.StringVar(&o.logLevel, "log-level", "debug", fmt.Sprintf("Log level is one of %v.", logrus.AllLevels))
 
@@ -100,6 +102,20 @@ func main() {
 	}
 
 	cla := newClaAssistantPlugin(githubClient, log)
+	if o.configPath != "" {
+		cla.config, err = newConfigAgent(o.configPath)
+		if err != nil {
+			log.WithError(err).Fatal("Error loading config.")
+		}
+		interrupts.TickLiteral(func() {
+			changed, err := cla.config.reload()
+			if err != nil {
+				log.WithError(err).Error("Error reloading config, keeping previous config.")
+			} else if changed {
+				log.Info("Reloaded config.")
+			}
+		}, time.Minute)
+	}
 
 	hs := newHttpServer(secret.GetTokenGenerator(o.webhookSecretFile), cla, log)
 
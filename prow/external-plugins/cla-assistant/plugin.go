@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -13,7 +14,6 @@ import (
 	"strings"
 	"time"
 
-	backoff "github.com/cenkalti/backoff/v7"
 	githubql "github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/prow/pkg/config"
@@ -41,7 +41,10 @@ type githubClient interface {
 	AddLabel(org, repo string, number int, label string) error
 	RemoveLabel(org, repo string, number int, label string) error
 	CreateComment(org, repo string, number int, comment string) error
+	EditComment(org, repo string, id int, comment string) error
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
 	ListStatuses(org, repo, ref string) ([]github.Status, error)
+	ListCheckRuns(org, repo, ref string) (*github.CheckRunList, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetRepos(org string, isUser bool) ([]github.Repo, error)
 	QueryWithGitHubAppsSupport(ctx context.Context, q any, vars map[string]any, org string) error
@@ -54,11 +57,15 @@ type claAssistantPlugin struct {
 	log          *logrus.Entry
 	baseURL      string
 	maxRetryTime time.Duration
+	config       *configAgent
+	reconciler   *reconciler
+	rateLimit    *rateLimitClient
 }
 
 func newClaAssistantPlugin(ghc githubClient, log *logrus.Entry) *claAssistantPlugin {
 	hc := &http.Client{Timeout: time.Second * 15}
-	return &claAssistantPlugin{ghc: ghc, hc: hc, log: log, baseURL: claAssistantBaseURL, maxRetryTime: time.Minute * 1}
+	rateLimit := newRateLimitClient(ghc)
+	return &claAssistantPlugin{ghc: rateLimit, hc: hc, log: log, baseURL: claAssistantBaseURL, maxRetryTime: time.Minute * 1, reconciler: newReconciler(), rateLimit: rateLimit}
 }
 
 func (c *claAssistantPlugin) handleIssueCommentEvent(ctx context.Context, l *logrus.Entry, ice *github.IssueCommentEvent) error {
@@ -86,7 +93,7 @@ func (c *claAssistantPlugin) handleIssueCommentEvent(ctx context.Context, l *log
 		return nil
 	}
 
-	l.Infof("Calling cla-assistant.io to initialize recheck of PR %v.", ice.Issue.Number)
+	l.Infof("Initializing CLA recheck of PR %v.", ice.Issue.Number)
 
 	return c.enforceClaRecheck(ctx, ice.Repo.Owner.Login, ice.Repo.Name, ice.Issue.Number, true)
 }
@@ -112,7 +119,7 @@ func (c *claAssistantPlugin) handleReviewCommentEvent(ctx context.Context, l *lo
 		return nil
 	}
 
-	l.Infof("Calling cla-assistant.io to initialize recheck of PR %v.", rce.PullRequest.Number)
+	l.Infof("Initializing CLA recheck of PR %v.", rce.PullRequest.Number)
 
 	return c.enforceClaRecheck(ctx, rce.Repo.Owner.Login, rce.Repo.Name, rce.PullRequest.Number, true)
 }
@@ -138,11 +145,37 @@ func (c *claAssistantPlugin) handleReviewEvent(ctx context.Context, l *logrus.En
 		return nil
 	}
 
-	l.Infof("Calling cla-assistant.io to initialize recheck of PR %v.", pre.PullRequest.Number)
+	l.Infof("Initializing CLA recheck of PR %v.", pre.PullRequest.Number)
 
 	return c.enforceClaRecheck(ctx, pre.Repo.Owner.Login, pre.Repo.Name, pre.PullRequest.Number, true)
 }
 
+func (c *claAssistantPlugin) handlePullRequestEvent(ctx context.Context, l *logrus.Entry, pe *github.PullRequestEvent) error {
+	org := pe.Repo.Owner.Login
+	repo := pe.Repo.Name
+
+	l.Debugf("Pull request %v of org/repo %s/%s with action %v received", pe.Number, org, repo, pe.Action)
+
+	if pe.Action == github.PullRequestActionClosed {
+		return c.removeClaLabels(l, org, repo, pe.Number, pe.PullRequest.Labels)
+	}
+
+	// Only consider new commits of open PRs.
+	if pe.Action != github.PullRequestActionOpened && pe.Action != github.PullRequestActionReopened && pe.Action != github.PullRequestActionSynchronize {
+		return nil
+	}
+
+	// Providers which report a commit status or check run are handled by status and check events.
+	provider := c.provider(org, repo)
+	if !checksSignatures(provider) {
+		return nil
+	}
+
+	l.Infof("Checking CLA signatures of PR %v.", pe.Number)
+
+	return c.updateClaLabels(ctx, l, provider, org, repo, pe.Number)
+}
+
 func (c *claAssistantPlugin) handleStatusEvent(ctx context.Context, l *logrus.Entry, se *github.StatusEvent) error {
 	org := se.Repo.Owner.Login
 	repo := se.Repo.Name
@@ -153,10 +186,16 @@ func (c *claAssistantPlugin) handleStatusEvent(ctx context.Context, l *logrus.En
 		return fmt.Errorf("invalid status event delivered with empty state/context")
 	}
 
-	// Status for context license/cla are arriving quite unreliably.
+	claContext := c.provider(org, repo).statusContext()
+	if claContext == "" {
+		// The provider does not report via commit statuses.
+		return nil
+	}
+
+	// Status for the CLA context are arriving quite unreliably.
 	// Thus, extract it from status list of the commit when status events with different contextes arrive.
 	var claStatus github.Status
-	if se.Context == claGithubContext {
+	if se.Context == claContext {
 		claStatus.Context = se.Context
 		claStatus.Description = se.Description
 		claStatus.State = se.State
@@ -167,14 +206,14 @@ func (c *claAssistantPlugin) handleStatusEvent(ctx context.Context, l *logrus.En
 			return err
 		}
 		for _, s := range status {
-			if s.Context == claGithubContext {
+			if s.Context == claContext {
 				claStatus = s
 				break
 			}
 		}
 	}
 
-	if claStatus.Context != claGithubContext {
+	if claStatus.Context != claContext {
 		// No CLA status, nothing to do
 		return nil
 	}
@@ -185,9 +224,69 @@ func (c *claAssistantPlugin) handleStatusEvent(ctx context.Context, l *logrus.En
 	}
 
 	l.Info("Handling status event")
+
+	return c.ensureClaLabelsForCommit(ctx, l, org, repo, se.SHA, claStatus.State)
+}
+
+func (c *claAssistantPlugin) handleCheckRunEvent(ctx context.Context, l *logrus.Entry, cre *github.CheckRunEvent) error {
+	org := cre.Repo.Owner.Login
+	repo := cre.Repo.Name
+
+	l.Debugf("Check run %q for org/repo %s/%s in status %v with conclusion %v received", cre.CheckRun.Name, org, repo, cre.CheckRun.Status, cre.CheckRun.Conclusion)
+
+	checkRunName := c.provider(org, repo).checkRunName()
+	if checkRunName == "" || cre.CheckRun.Name != checkRunName {
+		// Not the CLA check run, nothing to do
+		return nil
+	}
+
+	claState := checkRunState(cre.CheckRun)
+	if claState == github.StatusPending {
+		// do nothing and wait for the check run to complete.
+		return nil
+	}
+
+	l.Info("Handling check run event")
+
+	return c.ensureClaLabelsForCommit(ctx, l, org, repo, cre.CheckRun.HeadSHA, claState)
+}
+
+func (c *claAssistantPlugin) handleCheckSuiteEvent(ctx context.Context, l *logrus.Entry, cse *github.CheckSuiteEvent) error {
+	org := cse.Repo.Owner.Login
+	repo := cse.Repo.Name
+
+	l.Debugf("Check suite for org/repo %s/%s with action %v received", org, repo, cse.Action)
+
+	if cse.Action != "completed" {
+		return nil
+	}
+
+	checkRunName := c.provider(org, repo).checkRunName()
+	if checkRunName == "" {
+		return nil
+	}
+
+	// Like status events, check run events are not delivered reliably for all apps.
+	// Thus, extract the CLA check run from the check runs of the commit when a suite completes.
+	checkRuns, err := c.ghc.ListCheckRuns(org, repo, cse.CheckSuite.HeadSHA)
+	if err != nil {
+		return err
+	}
+	claState := findCheckRunState(checkRuns, checkRunName)
+	if claState == "" || claState == github.StatusPending {
+		return nil
+	}
+
+	l.Info("Handling check suite event")
+
+	return c.ensureClaLabelsForCommit(ctx, l, org, repo, cse.CheckSuite.HeadSHA, claState)
+}
+
+// ensureClaLabelsForCommit ensures the CLA labels of the open PRs whose head is the given commit.
+func (c *claAssistantPlugin) ensureClaLabelsForCommit(ctx context.Context, l *logrus.Entry, org, repo, sha, claState string) error {
 	l.Info("Searching for PRs matching the commit.")
 
-	pullRequests, err := c.search(ctx, l, fmt.Sprintf("%s repo:%s/%s type:pr state:open", se.SHA, org, repo), org)
+	pullRequests, err := c.search(ctx, l, fmt.Sprintf("%s repo:%s/%s type:pr state:open", sha, org, repo), org)
 	if err != nil {
 		return fmt.Errorf("error searching for issues matching commit: %w", err)
 	}
@@ -196,32 +295,63 @@ func (c *claAssistantPlugin) handleStatusEvent(ctx context.Context, l *logrus.En
 
 	for _, pullRequest := range pullRequests {
 		// Check if this is the latest commit in the PR.
-		if string(pullRequest.HeadRefOID) != se.SHA {
+		if string(pullRequest.HeadRefOID) != sha {
 			l.Info("Event is not for PR HEAD, skipping.")
 			continue
 		}
-		err := c.ensureClaLabels(l, org, repo, claStatus.State, pullRequest)
+		err := c.ensureClaLabels(ctx, l, org, repo, claState, pullRequest)
 		if err != nil {
 			l.WithError(err).Errorf("Error ensuring cla labels for PR #%v", pullRequest.Number)
 		}
 	}
 	return nil
+}
+
+// removeClaLabels removes the CLA labels of a closed PR, if configured for the repository.
+func (c *claAssistantPlugin) removeClaLabels(l *logrus.Entry, org, repo string, number int, labels []github.Label) error {
+	if remove := c.config.get().settings(org, repo).RemoveLabelsOnClose; remove == nil || !*remove {
+		return nil
+	}
 
+	labelYes, labelNo := c.labels(org, repo)
+	for _, label := range labels {
+		if label.Name != labelYes && label.Name != labelNo {
+			continue
+		}
+		l.Infof("Removing %s label from closed PR #%v.", label.Name, number)
+		if err := c.ghc.RemoveLabel(org, repo, number, label.Name); err != nil {
+			return fmt.Errorf("could not remove %s label from PR #%v: %w", label.Name, number, err)
+		}
+	}
+	return nil
 }
 
-func (c *claAssistantPlugin) ensureClaLabels(l *logrus.Entry, org, repo, claState string, pullRequest pullRequest) error {
+func (c *claAssistantPlugin) ensureClaLabels(ctx context.Context, l *logrus.Entry, org, repo, claState string, pullRequest pullRequest) error {
 	pl := l.WithField("pr", pullRequest.Number)
-	hasClaYes := pullRequest.hasLabel(labelClaYes)
-	hasClaNo := pullRequest.hasLabel(labelClaNo)
+	labelYes, labelNo := c.labels(org, repo)
+	hasClaYes := pullRequest.hasLabel(labelYes)
+	hasClaNo := pullRequest.hasLabel(labelNo)
+
+	// Explain missing signatures, and update the explanation once the CLA is signed. The explanation is only
+	// refreshed if the label changes or the PR got a new head, as it requires additional queries.
+	explain := claState == github.StatusFailure || claState == github.StatusError || (claState == github.StatusSuccess && hasClaNo)
+	sha := string(pullRequest.HeadRefOID)
+	if explain && (!labelsUpToDate(claState, hasClaYes, hasClaNo) || c.reconciler.explainedHead(org, repo, int(pullRequest.Number)) != sha) {
+		if err := c.ensureSignaturesComment(ctx, pl, org, repo, claState, pullRequest); err != nil {
+			pl.WithError(err).Warningf("Could not explain missing signatures of PR #%v.", int(pullRequest.Number))
+		} else {
+			c.reconciler.setExplainedHead(org, repo, int(pullRequest.Number), sha)
+		}
+	}
 	if hasClaYes && claState == github.StatusSuccess {
 		// Nothing to update.
-		pl.Infof("PR #%v has up-to-date %q label.", int(pullRequest.Number), labelClaYes)
+		pl.Infof("PR #%v has up-to-date %q label.", int(pullRequest.Number), labelYes)
 		return nil
 	}
 
 	if hasClaNo && (claState == github.StatusFailure || claState == github.StatusError) {
 		// Nothing to update.
-		pl.Infof("PR #%v has up-to-date %q label.", int(pullRequest.Number), labelClaNo)
+		pl.Infof("PR #%v has up-to-date %q label.", int(pullRequest.Number), labelNo)
 		return nil
 	}
 
@@ -231,16 +361,16 @@ func (c *claAssistantPlugin) ensureClaLabels(l *logrus.Entry, org, repo, claStat
 	if claState == github.StatusSuccess {
 		if hasClaNo {
 			// Remove "CLA no" label
-			err := c.ghc.RemoveLabel(org, repo, number, labelClaNo)
+			err := c.ghc.RemoveLabel(org, repo, number, labelNo)
 			if err != nil {
-				pl.WithError(err).Warningf("Could not remove %s label from PR #%v.", labelClaNo, int(pullRequest.Number))
+				pl.WithError(err).Warningf("Could not remove %s label from PR #%v.", labelNo, int(pullRequest.Number))
 				return err
 			}
 		}
 		// Add "CLA yes" label
-		err := c.ghc.AddLabel(org, repo, number, labelClaYes)
+		err := c.ghc.AddLabel(org, repo, number, labelYes)
 		if err != nil {
-			pl.WithError(err).Warningf("Could not add %s label from PR #%v.", labelClaYes, int(pullRequest.Number))
+			pl.WithError(err).Warningf("Could not add %s label from PR #%v.", labelYes, int(pullRequest.Number))
 			return err
 		}
 		return nil
@@ -249,16 +379,16 @@ func (c *claAssistantPlugin) ensureClaLabels(l *logrus.Entry, org, repo, claStat
 	// If we end up here, the github status is a failure/error, so a potential CLA yes label needs to be removed.
 	if hasClaYes {
 		// Remove "CLA yes" label
-		err := c.ghc.RemoveLabel(org, repo, number, labelClaYes)
+		err := c.ghc.RemoveLabel(org, repo, number, labelYes)
 		if err != nil {
-			pl.WithError(err).Warningf("Could not remove %s label from PR #%v.", labelClaYes, int(pullRequest.Number))
+			pl.WithError(err).Warningf("Could not remove %s label from PR #%v.", labelYes, int(pullRequest.Number))
 			return err
 		}
 	}
 	// Add "CLA no" label
-	err := c.ghc.AddLabel(org, repo, number, labelClaNo)
+	err := c.ghc.AddLabel(org, repo, number, labelNo)
 	if err != nil {
-		pl.WithError(err).Warningf("Could not add %s label from PR #%v.", labelClaNo, int(pullRequest.Number))
+		pl.WithError(err).Warningf("Could not add %s label from PR #%v.", labelNo, int(pullRequest.Number))
 		return err
 	}
 	return nil
@@ -279,9 +409,9 @@ func (c *claAssistantPlugin) handleAllPRs(ctx context.Context, l *logrus.Entry,
 			continue
 		}
 		for _, r := range orgRepos {
-			// cla-assistant.io can only be used for public repositories, so skip private ones.
+			// cla-assistant.io can only be used for public repositories, so skip private ones which use it.
 			// Archived repositories are also skipped, as they are read-only.
-			if r.Private || r.Archived {
+			if (r.Private && c.usesClaAssistant(o, r.Name)) || r.Archived {
 				continue
 			}
 
@@ -292,6 +422,9 @@ func (c *claAssistantPlugin) handleAllPRs(ctx context.Context, l *logrus.Entry,
 		}
 	}
 
+	// Start with the repository at which the last check stopped, so that the following ones are not starved.
+	slices.Sort(repos)
+	repos = c.reconciler.rotate(repos)
 	l.Infof("Checking repositories %v", repos)
 
 	for _, r := range repos {
@@ -310,50 +443,24 @@ func (c *claAssistantPlugin) handleAllPRs(ctx context.Context, l *logrus.Entry,
 			},
 		)
 
-		pullRequests, err := c.search(ctx, l, fmt.Sprintf("repo:%s/%s type:pr state:open", org, repo), org)
-		if err != nil {
-			lr.WithError(err).Error("Error searching open PRs")
-			continue
-		}
-
-		lr.Infof("Found %d PRs.", len(pullRequests))
-
-		for _, pullRequest := range pullRequests {
-
-			status, err := c.ghc.ListStatuses(org, repo, string(pullRequest.HeadRefOID))
-			if err != nil {
-				return err
-			}
-			var claStatus github.Status
-			for _, s := range status {
-				if s.Context == claGithubContext {
-					claStatus = s
-					break
-				}
-			}
-
-			if claStatus.Context != claGithubContext || claStatus.State == github.StatusPending {
-				lr.WithField("pr", pullRequest.Number).Infof("No cla status for PR #%v found. Calling cla-assistant.io to initialize recheck.", pullRequest.Number)
-				err := c.enforceClaRecheck(ctx, org, repo, int(pullRequest.Number), false)
-				if err != nil {
-					lr.WithField("pr", pullRequest.Number).WithError(err).Errorf("Error reaching out to cla-assistant.io for PR #%v", pullRequest.Number)
-				}
-			}
-
-			err = c.ensureClaLabels(lr, org, repo, claStatus.State, pullRequest)
-			if err != nil {
-				lr.WithField("pr", pullRequest.Number).WithError(err).Errorf("Error ensuring cla labels for PR #%v", pullRequest.Number)
+		if err := c.reconcileRepo(ctx, lr, org, repo); err != nil {
+			if errors.As(err, &rateLimitError{}) {
+				lr.WithError(err).Warn("Stopping periodic check of PRs")
+				c.reconciler.setNext(r)
+				return nil
 			}
+			lr.WithError(err).Error("Error searching open PRs")
 		}
 	}
 
+	c.reconciler.setNext("")
 	return nil
 }
 
 func (c *claAssistantPlugin) helpProvider([]config.OrgRepo) (*pluginhelp.PluginHelp, error) {
 	var ph pluginhelp.PluginHelp
 
-	ph.Description = `CLA assistant plugin attaches CLA labels to PRs according to results from cla-assistant.io. \n
+	ph.Description = `CLA assistant plugin attaches CLA labels to PRs according to results from cla-assistant.io or the CLA provider configured for the repository. \n
 						Additionally it can force rechecking the CLA status by /cla command.`
 
 	ph.AddCommand(pluginhelp.Command{
@@ -367,63 +474,76 @@ func (c *claAssistantPlugin) helpProvider([]config.OrgRepo) (*pluginhelp.PluginH
 	return &ph, nil
 }
 
-func (c *claAssistantPlugin) createClaAssistantURI(org, repo string, pullRequestNumber int) string {
-	path := fmt.Sprintf(claAssistantURLPath, org, repo, pullRequestNumber)
-	return c.baseURL + path
-}
-
 func (c *claAssistantPlugin) enforceClaRecheck(ctx context.Context, org string, repo string, pullRequestNumber int, createResultComment bool) error {
-	_, err := backoff.Retry(
-		ctx,
-		func() (int, error) {
-			resp, err := c.hc.Get(c.createClaAssistantURI(org, repo, pullRequestNumber))
-			if err != nil {
-				return 0, err
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-				return resp.StatusCode, nil
-			}
-			return resp.StatusCode, fmt.Errorf("error reaching out to cla-assistant.io for rechecking PR %v - HTTP status code %v", pullRequestNumber, resp.StatusCode)
-		},
-		backoff.WithBackOff(backoff.NewExponentialBackOff()),
-		backoff.WithMaxElapsedTime(c.maxRetryTime),
-	)
+	provider := c.provider(org, repo)
+	err := provider.recheck(ctx, org, repo, pullRequestNumber)
+	if err == nil && checksSignatures(provider) {
+		// The provider does not report a status, hence the labels are updated right away.
+		err = c.updateClaLabels(ctx, c.log, provider, org, repo, pullRequestNumber)
+	}
 
 	if !createResultComment {
 		return err
 	}
 
 	if err == nil {
-		c.log.Infof("Successfully reached out to cla-assistant.io to initialize recheck of PR %v", pullRequestNumber)
+		c.log.Infof("Successfully reached out to %s to initialize recheck of PR %v", provider.name(), pullRequestNumber)
 		err := c.ghc.CreateComment(
 			org,
 			repo,
 			pullRequestNumber,
-			fmt.Sprintf("Successfully reached out to cla-assistant.io to initialize recheck of PR #%v", pullRequestNumber),
+			c.recheckComment(org, repo, pullRequestNumber, provider, true),
 		)
 		if err != nil {
 			c.log.WithError(err).Warningf(
-				"Successfully reached out to cla-assistant.io to initialize recheck of PR #%v, but response comment could not be created", pullRequestNumber)
+				"Successfully reached out to %s to initialize recheck of PR #%v, but response comment could not be created", provider.name(), pullRequestNumber)
 		}
 	} else {
 		err := c.ghc.CreateComment(
 			org,
 			repo,
 			pullRequestNumber,
-			fmt.Sprintf("Could not reach out to cla-assistant.io for rechecking PR #%v", pullRequestNumber),
+			c.recheckComment(org, repo, pullRequestNumber, provider, false),
 		)
 		if err != nil {
 			c.log.WithError(err).Errorf(
-				"Could not reach out to cla-assistant.io for rechecking PR #%v and response comment could not be created", pullRequestNumber)
+				"Could not reach out to %s for rechecking PR #%v and response comment could not be created", provider.name(), pullRequestNumber)
 		}
 	}
 
 	return err
 }
 
+// updateClaLabels ensures the CLA labels of the PR according to the state reported by the provider.
+func (c *claAssistantPlugin) updateClaLabels(ctx context.Context, l *logrus.Entry, provider claProvider, org, repo string, number int) error {
+	pr, err := c.ghc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	pullRequest := pullRequestFromGitHub(pr)
+	claState, err := provider.state(ctx, org, repo, pullRequest)
+	if err != nil {
+		return err
+	}
+	return c.ensureClaLabels(ctx, l, org, repo, claState, pullRequest)
+}
+
 func (c *claAssistantPlugin) search(ctx context.Context, log *logrus.Entry, q, org string) ([]pullRequest, error) {
 	var ret []pullRequest
+	err := searchPages(ctx, c, log, q, org, func(pullRequests []pullRequest) error {
+		ret = append(ret, pullRequests...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// searchPages calls handlePage with every page of the search results. The search is stopped if handlePage
+// returns an error. The fields which are queried for every PR are the fields of PR.
+func searchPages[PR any](ctx context.Context, c *claAssistantPlugin, log *logrus.Entry, q, org string, handlePage func(pullRequests []PR) error) error {
+	var found int
 	vars := map[string]any{
 		"query":        githubql.String(q),
 		"searchCursor": (*githubql.String)(nil),
@@ -434,14 +554,19 @@ func (c *claAssistantPlugin) search(ctx context.Context, log *logrus.Entry, q, o
 	var pageCount int
 	for {
 		pageCount++
-		sq := searchQuery{}
+		sq := searchQuery[PR]{}
 		if err := c.ghc.QueryWithGitHubAppsSupport(ctx, &sq, vars, org); err != nil {
-			return nil, err
+			return err
 		}
 		totalCost += int(sq.RateLimit.Cost)
 		remaining = int(sq.RateLimit.Remaining)
+		pullRequests := make([]PR, 0, len(sq.Search.Nodes))
 		for _, n := range sq.Search.Nodes {
-			ret = append(ret, n.PullRequest)
+			pullRequests = append(pullRequests, n.PullRequest)
+		}
+		found += len(pullRequests)
+		if err := handlePage(pullRequests); err != nil {
+			return err
 		}
 		if !sq.Search.PageInfo.HasNextPage {
 			break
@@ -451,14 +576,14 @@ func (c *claAssistantPlugin) search(ctx context.Context, log *logrus.Entry, q, o
 	log = log.WithFields(logrus.Fields{
 		"query":          q,
 		"duration":       time.Since(requestStart).String(),
-		"pr_found_count": len(ret),
+		"pr_found_count": found,
 		"search_pages":   pageCount,
 		"cost":           totalCost,
 		"remaining":      remaining,
 	})
 	log.Debug("Finished query")
 
-	return ret, nil
+	return nil
 }
 
 // See: https://developer.github.com/v4/object/pullrequest/.
@@ -483,6 +608,21 @@ type pullRequest struct {
 	State     githubql.PullRequestState
 }
 
+// pullRequestFromGitHub converts a PR of the REST API to the fields of the GraphQL API used by the plugin.
+func pullRequestFromGitHub(pr *github.PullRequest) pullRequest {
+	var p pullRequest
+	p.Number = githubql.Int(pr.Number)
+	p.Author.Login = githubql.String(pr.User.Login)
+	p.HeadRefOID = githubql.String(pr.Head.SHA)
+	p.Repository.Name = githubql.String(pr.Base.Repo.Name)
+	p.Repository.Owner.Login = githubql.String(pr.Base.Repo.Owner.Login)
+	for _, l := range pr.Labels {
+		p.Labels.Nodes = append(p.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(l.Name)})
+	}
+	p.State = githubql.PullRequestState(strings.ToUpper(pr.State))
+	return p
+}
+
 func (p *pullRequest) hasLabel(label string) bool {
 	for _, l := range p.Labels.Nodes {
 		if string(l.Name) == label {
@@ -492,8 +632,9 @@ func (p *pullRequest) hasLabel(label string) bool {
 	return false
 }
 
+// searchQuery searches PRs and queries the fields of PR for each of them.
 // See: https://developer.github.com/v4/query/.
-type searchQuery struct {
+type searchQuery[PR any] struct {
 	RateLimit struct {
 		Cost      githubql.Int
 		Remaining githubql.Int
@@ -504,7 +645,7 @@ type searchQuery struct {
 			EndCursor   githubql.String
 		}
 		Nodes []struct {
-			PullRequest pullRequest `graphql:"... on PullRequest"`
+			PullRequest PR `graphql:"... on PullRequest"`
 		}
 	} `graphql:"search(type: ISSUE, first: 100, after: $searchCursor, query: $query)"`
 }
@@ -677,6 +677,36 @@ func TestHandleStatusEvent(t *testing.T) {
 	}
 }
 
+func TestHandleAllPRsSkipsPrivateReposOfClaAssistant(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestHandleAllPRsSkipsPrivateReposOfClaAssistant", pluginName)
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	ingestDataIntoFakeClient(p.fakeClient)
+	p.fakeClient.repos = []github.Repo{
+		{Owner: github.User{Login: "kubernetes"}, Name: "kubernetes", Private: true},
+		{Owner: github.User{Login: "kubernetes"}, Name: "community", Private: true},
+	}
+	p.plugin.config = &configAgent{config: &pluginConfig{Orgs: map[string]orgConfig{
+		"kubernetes": {Repos: map[string]overrides{
+			"community": {Provider: &providerConfig{Type: providerStatus, Context: claGithubContext}},
+		}},
+	}}}
+	config := &plugins.Configuration{
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			"kubernetes": {{Name: pluginName}},
+		},
+	}
+
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	signed := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", "kubernetes", "community", shaWithPR)]
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString("kubernetes", "community", signed, labelClaYes))
+	for _, label := range p.fakeClient.IssueLabelsAdded {
+		assert.False(t, strings.HasPrefix(label, "kubernetes/kubernetes#"), "expected private repository with cla-assistant to be skipped, got label %s", label)
+	}
+}
+
 func TestHandleAllPRs(t *testing.T) {
 	ctx := context.Background()
 	log := logrus.StandardLogger().WithField("TestHandleIssueCommentEvent", pluginName)
@@ -892,24 +922,67 @@ type claAssistantTestPlugin struct {
 
 // Github fake client
 func newFakeClient() *fakeClient {
-	f := fakeClient{*fakegithub.NewFakeClient()}
+	f := fakeClient{FakeClient: *fakegithub.NewFakeClient(), rateLimitRemaining: 5000}
 	return &f
 }
 
 type fakeClient struct {
 	fakegithub.FakeClient
+	rateLimitRemaining int
+	// contextsPageSize is the page size of the commit statuses and check runs of a commit, if set.
+	contextsPageSize int
+	// repos are returned by GetRepos, if set.
+	repos []github.Repo
+}
+
+func (f *fakeClient) GetRepos(org string, isUser bool) ([]github.Repo, error) {
+	if f.repos != nil {
+		return f.repos, nil
+	}
+	return f.FakeClient.GetRepos(org, isUser)
 }
 
 func (f *fakeClient) QueryWithGitHubAppsSupport(_ context.Context, q any, vars map[string]any, _ string) error {
 
-	sq, ok := q.(*searchQuery)
-	if !ok {
+	switch q := q.(type) {
+	case *commitsQuery:
+		return f.queryCommits(q, vars)
+	case *commitContextsQuery:
+		return f.queryCommitContexts(q, vars)
+	case *searchQuery[pullRequest]:
+		pullRequests, err := f.search(vars)
+		if err != nil {
+			return err
+		}
+		for _, pr := range pullRequests {
+			q.Search.Nodes = append(q.Search.Nodes, struct {
+				PullRequest pullRequest "graphql:\"... on PullRequest\""
+			}{PullRequest: pr.pullRequest})
+		}
+		q.RateLimit.Remaining = githubql.Int(f.rateLimitRemaining)
+	case *searchQuery[reconcilePullRequest]:
+		pullRequests, err := f.search(vars)
+		if err != nil {
+			return err
+		}
+		for _, pr := range pullRequests {
+			q.Search.Nodes = append(q.Search.Nodes, struct {
+				PullRequest reconcilePullRequest "graphql:\"... on PullRequest\""
+			}{PullRequest: pr})
+		}
+		q.RateLimit.Remaining = githubql.Int(f.rateLimitRemaining)
+	default:
 		return fmt.Errorf("Query type not implemented")
 	}
+	return nil
+}
 
+// search returns the PRs of the search query with the statuses and check runs of their heads, and counts the
+// query against the rate limit.
+func (f *fakeClient) search(vars map[string]any) ([]reconcilePullRequest, error) {
 	query, ok := vars["query"].(githubql.String)
 	if !ok {
-		return fmt.Errorf("No query string")
+		return nil, fmt.Errorf("No query string")
 	}
 
 	queryList := strings.Split(string(query), " ")
@@ -932,7 +1005,7 @@ func (f *fakeClient) QueryWithGitHubAppsSupport(_ context.Context, q any, vars m
 	}
 
 	if owner == "" || repo == "" {
-		return fmt.Errorf("Query does not contain owner and repo")
+		return nil, fmt.Errorf("Query does not contain owner and repo")
 	}
 
 	var prNumbers []int
@@ -958,6 +1031,7 @@ func (f *fakeClient) QueryWithGitHubAppsSupport(_ context.Context, q any, vars m
 		}
 	}
 
+	var pullRequests []reconcilePullRequest
 	for _, prNumber := range prNumbers {
 		pr, err := f.GetPullRequest(owner, repo, prNumber)
 		if err != nil {
@@ -969,25 +1043,118 @@ func (f *fakeClient) QueryWithGitHubAppsSupport(_ context.Context, q any, vars m
 			labelNodes = append(labelNodes, struct{ Name githubql.String }{Name: githubql.String(l.Name)})
 		}
 
-		sq.Search.Nodes = append(sq.Search.Nodes,
-			struct {
-				PullRequest pullRequest "graphql:\"... on PullRequest\""
-			}{PullRequest: pullRequest{
-				Number:     githubql.Int(pr.Number),
-				Author:     struct{ Login githubql.String }{Login: "Test-Author"},
-				HeadRefOID: githubql.String(pr.Head.SHA),
-				Repository: struct {
-					Name  githubql.String
-					Owner struct{ Login githubql.String }
-				}{Name: (githubql.String)(repo), Owner: struct{ Login githubql.String }{Login: (githubql.String)(owner)}},
-				Labels: struct {
-					Nodes []struct{ Name githubql.String }
-				}{labelNodes},
-				Mergeable: githubql.MergeableStateMergeable,
-				State:     githubql.PullRequestStateOpen,
-			},
-			})
+		var node reconcilePullRequest
+		node.pullRequest = pullRequest{
+			Number:     githubql.Int(pr.Number),
+			Author:     struct{ Login githubql.String }{Login: "Test-Author"},
+			HeadRefOID: githubql.String(pr.Head.SHA),
+			Repository: struct {
+				Name  githubql.String
+				Owner struct{ Login githubql.String }
+			}{Name: (githubql.String)(repo), Owner: struct{ Login githubql.String }{Login: (githubql.String)(owner)}},
+			Labels: struct {
+				Nodes []struct{ Name githubql.String }
+			}{labelNodes},
+			Mergeable: githubql.MergeableStateMergeable,
+			State:     githubql.PullRequestStateOpen,
+		}
+		if rollup := f.statusCheckRollup(string(pr.Head.SHA), 0); rollup != nil {
+			node.Commits.Nodes = make([]struct {
+				Commit struct{ StatusCheckRollup *statusCheckRollup }
+			}, 1)
+			node.Commits.Nodes[0].Commit.StatusCheckRollup = rollup
+		}
+		pullRequests = append(pullRequests, node)
+	}
+	f.rateLimitRemaining--
+	return pullRequests, nil
+}
+
+// statusCheckRollup returns the page of the created statuses and check runs of the commit which starts at the
+// given index, or nil if there are none.
+func (f *fakeClient) statusCheckRollup(sha string, start int) *statusCheckRollup {
+	var contexts []statusCheckContext
+	for _, s := range f.CreatedStatuses[sha] {
+		var c statusCheckContext
+		c.StatusContext.Context = githubql.String(s.Context)
+		c.StatusContext.State = githubql.StatusState(strings.ToUpper(s.State))
+		contexts = append(contexts, c)
+	}
+	for _, r := range f.CheckRuns.CheckRuns {
+		if r.HeadSHA != sha {
+			continue
+		}
+		var c statusCheckContext
+		c.CheckRun.Name = githubql.String(r.Name)
+		c.CheckRun.Status = githubql.CheckStatusState(strings.ToUpper(r.Status))
+		c.CheckRun.Conclusion = githubql.CheckConclusionState(strings.ToUpper(r.Conclusion))
+		contexts = append(contexts, c)
+	}
+	if contexts == nil {
+		return nil
+	}
+
+	rollup := &statusCheckRollup{}
+	end := len(contexts)
+	if f.contextsPageSize > 0 && start+f.contextsPageSize < end {
+		end = start + f.contextsPageSize
+		rollup.Contexts.PageInfo.HasNextPage = true
+		rollup.Contexts.PageInfo.EndCursor = githubql.String(strconv.Itoa(end))
+	}
+	rollup.Contexts.Nodes = contexts[start:end]
+	return rollup
+}
+
+// queryCommitContexts answers a commitContextsQuery with the next page of the statuses and check runs.
+func (f *fakeClient) queryCommitContexts(q *commitContextsQuery, vars map[string]any) error {
+	sha, _ := vars["sha"].(githubql.GitObjectID)
+	cursor, _ := vars["contextsCursor"].(githubql.String)
+	start, err := strconv.Atoi(string(cursor))
+	if err != nil {
+		return fmt.Errorf("invalid contexts cursor %q", cursor)
+	}
+	if rollup := f.statusCheckRollup(string(sha), start); rollup != nil {
+		q.Repository.Object.Commit.StatusCheckRollup = &struct {
+			Contexts statusCheckContexts "graphql:\"contexts(first: 100, after: $contextsCursor)\""
+		}{Contexts: rollup.Contexts}
+	}
+	f.rateLimitRemaining--
+	q.RateLimit.Remaining = githubql.Int(f.rateLimitRemaining)
+	return nil
+}
+
+// queryCommits answers a commitsQuery with the commits of the CommitMap. Co-authors are taken from the
+// Co-authored-by trailers of the commit messages.
+func (f *fakeClient) queryCommits(cq *commitsQuery, vars map[string]any) error {
+	owner, _ := vars["owner"].(githubql.String)
+	repo, _ := vars["name"].(githubql.String)
+	number, _ := vars["number"].(githubql.Int)
+
+	actor := func(login, name, email string) gitActor {
+		a := gitActor{Name: githubql.String(name), Email: githubql.String(email)}
+		if login != "" {
+			a.User = &struct{ Login githubql.String }{Login: githubql.String(login)}
+		}
+		return a
+	}
+
+	for _, c := range f.CommitMap[createCommitMapKey(string(owner), string(repo), int(number))] {
+		commit := prCommit{AbbreviatedOID: githubql.String(c.SHA), Committer: actor(c.Committer.Login, c.Commit.Committer.Name, c.Commit.Committer.Email)}
+		commit.Authors.Nodes = append(commit.Authors.Nodes, actor(c.Author.Login, c.Commit.Author.Name, c.Commit.Author.Email))
+		for _, line := range strings.Split(c.Commit.Message, "\n") {
+			if coAuthor, ok := strings.CutPrefix(line, "Co-authored-by: "); ok {
+				name, email, _ := strings.Cut(strings.TrimSuffix(coAuthor, ">"), " <")
+				commit.Authors.Nodes = append(commit.Authors.Nodes, actor("", name, email))
+			}
+		}
+		if len(commit.Authors.Nodes) > maxCommitAuthors {
+			commit.Authors.Nodes = commit.Authors.Nodes[:maxCommitAuthors]
+			commit.Authors.PageInfo.HasNextPage = true
+		}
+		cq.Repository.PullRequest.Commits.Nodes = append(cq.Repository.PullRequest.Commits.Nodes, struct{ Commit prCommit }{commit})
 	}
+	f.rateLimitRemaining--
+	cq.RateLimit.Remaining = githubql.Int(f.rateLimitRemaining)
 	return nil
 }
 
@@ -0,0 +1,328 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v7"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// claProvider determines whether the authors of a PR signed the CLA.
+type claProvider interface {
+	// name is the name of the provider used in comments, e.g. cla-assistant.io.
+	name() string
+	// statusContext is the commit status context in which the provider reports the CLA state. It is
+	// empty if the provider does not report commit statuses.
+	statusContext() string
+	// checkRunName is the name of the check run in which the provider reports the CLA state. It is
+	// empty if the provider does not report check runs.
+	checkRunName() string
+	// recheck asks the provider to check the CLA signatures of the PR again.
+	recheck(ctx context.Context, org, repo string, number int) error
+	// state returns the CLA state of the head of the PR, which is one of the github.Status* states.
+	// It is empty if the provider did not report a state yet.
+	state(ctx context.Context, org, repo string, pr pullRequest) (string, error)
+	// checkAuthors marks the commit authors of the PR who did not sign the CLA, as far as the provider
+	// knows them. It returns a hint on the missing signatures reported by the provider, if any.
+	checkAuthors(ctx context.Context, org, repo string, pr pullRequest, authors []*commitAuthor) (string, error)
+}
+
+// checksSignatures returns true if the plugin checks the signatures itself, because the provider
+// reports neither commit statuses nor check runs.
+func checksSignatures(p claProvider) bool {
+	return p.statusContext() == "" && p.checkRunName() == ""
+}
+
+// checkRunState normalizes the state of a check run to the github.Status* states of commit statuses.
+func checkRunState(run github.CheckRun) string {
+	if run.Status != "completed" {
+		return github.StatusPending
+	}
+	switch run.Conclusion {
+	case "success", "neutral", "skipped":
+		return github.StatusSuccess
+	case "failure", "action_required":
+		return github.StatusFailure
+	case "stale":
+		// GitHub marks check runs as stale if they did not complete in time, the CLA service may still report.
+		return github.StatusPending
+	default:
+		return github.StatusError
+	}
+}
+
+// findCheckRun returns the check run with the given name, or nil if there is no such check run.
+func findCheckRun(checkRuns *github.CheckRunList, name string) *github.CheckRun {
+	if checkRuns == nil {
+		return nil
+	}
+	for i := range checkRuns.CheckRuns {
+		if checkRuns.CheckRuns[i].Name == name {
+			return &checkRuns.CheckRuns[i]
+		}
+	}
+	return nil
+}
+
+// findCheckRunState returns the normalized state of the check run with the given name, or an empty
+// state if there is no such check run.
+func findCheckRunState(checkRuns *github.CheckRunList, name string) string {
+	if run := findCheckRun(checkRuns, name); run != nil {
+		return checkRunState(*run)
+	}
+	return ""
+}
+
+// statusProvider is a CLA service which reports the CLA state as commit status or check run, e.g.
+// cla-assistant.io or EasyCLA.
+type statusProvider struct {
+	displayName  string
+	context      string
+	checkRun     string
+	ghc          githubClient
+	hc           *http.Client
+	maxRetryTime time.Duration
+	// recheckURL returns the URL which triggers a recheck of the PR. It is nil if the service cannot be
+	// triggered.
+	recheckURL func(org, repo string, number int) (string, error)
+}
+
+func (p *statusProvider) name() string {
+	return p.displayName
+}
+
+func (p *statusProvider) statusContext() string {
+	return p.context
+}
+
+func (p *statusProvider) checkRunName() string {
+	return p.checkRun
+}
+
+func (p *statusProvider) recheck(ctx context.Context, org, repo string, number int) error {
+	if p.recheckURL == nil {
+		return nil
+	}
+	uri, err := p.recheckURL(org, repo, number)
+	if err != nil {
+		return err
+	}
+	_, err = backoff.Retry(
+		ctx,
+		func() (int, error) {
+			resp, err := p.hc.Get(uri)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+				return resp.StatusCode, nil
+			}
+			return resp.StatusCode, fmt.Errorf("error reaching out to %s for rechecking PR %v - HTTP status code %v", p.displayName, number, resp.StatusCode)
+		},
+		backoff.WithBackOff(backoff.NewExponentialBackOff()),
+		backoff.WithMaxElapsedTime(p.maxRetryTime),
+	)
+	return err
+}
+
+// state returns the state of the commit status of the PR head. If there is no commit status, the
+// state of the check run is returned.
+func (p *statusProvider) state(_ context.Context, org, repo string, pr pullRequest) (string, error) {
+	if p.context != "" {
+		statuses, err := p.ghc.ListStatuses(org, repo, string(pr.HeadRefOID))
+		if err != nil {
+			return "", err
+		}
+		for _, s := range statuses {
+			if s.Context == p.context {
+				return s.State, nil
+			}
+		}
+	}
+	if p.checkRun != "" {
+		checkRuns, err := p.ghc.ListCheckRuns(org, repo, string(pr.HeadRefOID))
+		if err != nil {
+			return "", err
+		}
+		return findCheckRunState(checkRuns, p.checkRun), nil
+	}
+	return "", nil
+}
+
+// checkAuthors does not mark any authors, as the service only reports the state of the PR. Instead, it
+// returns the description and link of the commit status or check run.
+func (p *statusProvider) checkAuthors(_ context.Context, org, repo string, pr pullRequest, _ []*commitAuthor) (string, error) {
+	var description, link string
+	if p.context != "" {
+		statuses, err := p.ghc.ListStatuses(org, repo, string(pr.HeadRefOID))
+		if err != nil {
+			return "", err
+		}
+		for _, s := range statuses {
+			if s.Context == p.context {
+				description, link = s.Description, s.TargetURL
+				break
+			}
+		}
+	}
+	if description == "" && link == "" && p.checkRun != "" {
+		checkRuns, err := p.ghc.ListCheckRuns(org, repo, string(pr.HeadRefOID))
+		if err != nil {
+			return "", err
+		}
+		if run := findCheckRun(checkRuns, p.checkRun); run != nil {
+			description, link = run.Output.Title, run.DetailsURL
+		}
+	}
+
+	var hint []string
+	if description != "" {
+		hint = append(hint, fmt.Sprintf("%s reports: %s", p.displayName, description))
+	}
+	if link != "" {
+		hint = append(hint, fmt.Sprintf("See [details](%s) to sign the CLA.", link))
+	}
+	return strings.Join(hint, " "), nil
+}
+
+// signerProvider checks the CLA signatures of the commit authors of a PR against a list of signers,
+// e.g. an allowlist file or a self-hosted signature store.
+type signerProvider struct {
+	displayName string
+	ghc         githubClient
+	// signed returns true if the GitHub user signed the CLA.
+	signed func(ctx context.Context, login string) (bool, error)
+}
+
+func (p *signerProvider) name() string {
+	return p.displayName
+}
+
+func (p *signerProvider) statusContext() string {
+	return ""
+}
+
+func (p *signerProvider) checkRunName() string {
+	return ""
+}
+
+// recheck does nothing, as the signatures are checked whenever the state is requested.
+func (p *signerProvider) recheck(context.Context, string, string, int) error {
+	return nil
+}
+
+// state checks the signatures of all authors, co-authors and committers of the commits of the PR, which
+// are the same persons the comment on missing signatures lists. Persons whose email address is not
+// linked to a GitHub account cannot have signed the CLA.
+func (p *signerProvider) state(ctx context.Context, org, repo string, pr pullRequest) (string, error) {
+	authors, err := commitAuthors(ctx, p.ghc, org, repo, int(pr.Number))
+	if err != nil {
+		return "", err
+	}
+	if _, err := p.checkAuthors(ctx, org, repo, pr, authors); err != nil {
+		return "", err
+	}
+	for _, a := range authors {
+		if a.login == "" || a.unsigned {
+			return github.StatusFailure, nil
+		}
+	}
+	return github.StatusSuccess, nil
+}
+
+// checkAuthors marks the authors with a GitHub account who did not sign the CLA.
+func (p *signerProvider) checkAuthors(ctx context.Context, _, _ string, _ pullRequest, authors []*commitAuthor) (string, error) {
+	for _, a := range authors {
+		if a.login == "" {
+			continue
+		}
+		ok, err := p.signed(ctx, a.login)
+		if err != nil {
+			return "", err
+		}
+		a.unsigned = !ok
+	}
+	return "", nil
+}
+
+// readAllowlist reads the allowlist file at path and returns the listed logins in lower case. The file
+// lists one GitHub login per line, lines starting with `#` are ignored.
+func readAllowlist(path string) (sets.Set[string], error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CLA allowlist: %w", err)
+	}
+	logins := sets.New[string]()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		logins.Insert(strings.ToLower(strings.TrimPrefix(line, "@")))
+	}
+	return logins, scanner.Err()
+}
+
+// allowlistSigned returns a function which checks whether a user is one of the logins of an allowlist.
+func allowlistSigned(logins sets.Set[string]) func(context.Context, string) (bool, error) {
+	return func(_ context.Context, login string) (bool, error) {
+		return logins.Has(strings.ToLower(login)), nil
+	}
+}
+
+// signatureStoreSigned returns a function which asks the signature store at baseURL whether a user
+// signed the CLA. The store responds to `GET <baseURL>/signatures/<login>` with 200 if the user signed
+// the CLA and with 404 otherwise.
+func signatureStoreSigned(hc *http.Client, baseURL string) func(context.Context, string) (bool, error) {
+	return func(ctx context.Context, login string) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/signatures/"+url.PathEscape(login), nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := hc.Do(req)
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return true, nil
+		case http.StatusNotFound:
+			return false, nil
+		default:
+			return false, fmt.Errorf("error reaching out to CLA signature store for %s - HTTP status code %v", login, resp.StatusCode)
+		}
+	}
+}
+
+// recheckURLTemplate returns a function which renders the recheck URL template of a status provider.
+func recheckURLTemplate(tmpl string) func(org, repo string, number int) (string, error) {
+	t := template.Must(template.New("recheckURL").Parse(tmpl))
+	return func(org, repo string, number int) (string, error) {
+		var b strings.Builder
+		if err := t.Execute(&b, struct {
+			Org    string
+			Repo   string
+			Number int
+		}{org, repo, number}); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+}
@@ -0,0 +1,336 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestSignerProvider(t *testing.T) {
+	ctx := context.Background()
+	allowlist := filepath.Join(t.TempDir(), "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("# Signers of the CLA\n@alice\nBob\n"), 0600))
+	signers, err := readAllowlist(allowlist)
+	assert.NoError(t, err)
+	store := newSignatureStoreTestServer("alice", "bob")
+	defer store.Close()
+
+	alice := signaturesTestCommit("1111111", "alice", "alice@example.com")
+	bob := signaturesTestCommit("2222222", "bob", "bob@example.com")
+	coAuthored := signaturesTestCommit("3333333", "alice", "alice@example.com")
+	coAuthored.Commit.Message = "Fix typo\n\nCo-authored-by: Carol <carol@example.com>"
+	committed := signaturesTestCommit("4444444", "alice", "alice@example.com")
+	committed.Committer = github.User{Login: "carol"}
+	committed.Commit.Committer = github.CommitAuthor{Email: "carol@example.com"}
+
+	type testCase struct {
+		name    string
+		signed  func(context.Context, string) (bool, error)
+		commits []github.RepositoryCommit
+		state   string
+	}
+
+	tests := []testCase{
+		{
+			name:    "allowlist with all authors signed",
+			signed:  allowlistSigned(signers),
+			commits: []github.RepositoryCommit{alice, bob},
+			state:   github.StatusSuccess,
+		},
+		{
+			name:    "allowlist with unsigned author",
+			signed:  allowlistSigned(signers),
+			commits: []github.RepositoryCommit{alice, signaturesTestCommit("5555555", "carol", "carol@example.com")},
+			state:   github.StatusFailure,
+		},
+		{
+			name:    "allowlist with unlinked author",
+			signed:  allowlistSigned(signers),
+			commits: []github.RepositoryCommit{alice, signaturesTestCommit("6666666", "", "dave@example.com")},
+			state:   github.StatusFailure,
+		},
+		{
+			name:    "allowlist with unlinked co-author",
+			signed:  allowlistSigned(signers),
+			commits: []github.RepositoryCommit{alice, coAuthored},
+			state:   github.StatusFailure,
+		},
+		{
+			name:    "allowlist with unsigned committer",
+			signed:  allowlistSigned(signers),
+			commits: []github.RepositoryCommit{committed},
+			state:   github.StatusFailure,
+		},
+		{
+			name:    "signature store with all authors signed",
+			signed:  signatureStoreSigned(store.Client(), store.URL),
+			commits: []github.RepositoryCommit{alice, bob},
+			state:   github.StatusSuccess,
+		},
+		{
+			name:    "signature store with unsigned committer",
+			signed:  signatureStoreSigned(store.Client(), store.URL),
+			commits: []github.RepositoryCommit{alice, committed},
+			state:   github.StatusFailure,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				ghc := newFakeClient()
+				ghc.CommitMap[createCommitMapKey(testOwner, testRepo, 1)] = test.commits
+				p := &signerProvider{displayName: "test", ghc: ghc, signed: test.signed}
+
+				state, err := p.state(ctx, testOwner, testRepo, pullRequest{Number: 1})
+				assert.NoError(t, err)
+				assert.Equal(t, test.state, state)
+			})
+	}
+}
+
+func TestSignatureStoreError(t *testing.T) {
+	store := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer store.Close()
+
+	_, err := signatureStoreSigned(store.Client(), store.URL)(context.Background(), "alice")
+	assert.Error(t, err)
+}
+
+func TestEnforceClaRecheckWithProviders(t *testing.T) {
+	ctx := context.Background()
+	allowlist := filepath.Join(t.TempDir(), "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\n"), 0600))
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = testConfigAgent(t, &pluginConfig{
+		Orgs: map[string]orgConfig{
+			testOwner: {
+				overrides: overrides{Provider: &providerConfig{Type: providerStatus, Context: "EasyCLA", RecheckURL: p.http.server.URL + "/recheck/{{.Org}}/{{.Repo}}/{{.Number}}"}},
+				Repos: map[string]overrides{
+					"allowlisted": {Provider: &providerConfig{Type: providerAllowlist, Path: allowlist}},
+				},
+			},
+		},
+	})
+
+	// The status provider calls the recheck URL.
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, 1, true))
+	assert.Equal(t, []string{"/recheck/TestOrg/test-repo/1"}, p.http.urisReached)
+	assert.Equal(t, "EasyCLA", p.plugin.provider(testOwner, testRepo).statusContext())
+
+	// The allowlist provider updates the labels right away.
+	number, err := p.fakeClient.CreatePullRequest(testOwner, "allowlisted", "Title", "Body", "HEAD", "BASE", true)
+	assert.NoError(t, err)
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, "allowlisted", number)] = []github.RepositoryCommit{signaturesTestCommit("1111111", "alice", "alice@example.com"), signaturesTestCommit("2222222", "", "bob@example.com")}
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, "allowlisted", number, true))
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, "allowlisted", number, labelClaNo))
+	assert.True(t, slices.ContainsFunc(p.fakeClient.IssueCommentsAdded, func(c string) bool {
+		return strings.Contains(c, "Successfully reached out to the CLA allowlist")
+	}))
+	assert.Len(t, p.http.urisReached, 1)
+}
+
+func TestHandlePullRequestEventWithSignatureStore(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestHandlePullRequestEventWithSignatureStore", pluginName)
+	store := newSignatureStoreTestServer("alice")
+	defer store.Close()
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = &configAgent{config: &pluginConfig{
+		Default: overrides{Provider: &providerConfig{Type: providerSignatureStore, URL: store.URL}},
+	}}
+
+	number, err := p.fakeClient.CreatePullRequest(testOwner, testRepo, "Title", "Body", "HEAD", "BASE", true)
+	assert.NoError(t, err)
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, number)] = []github.RepositoryCommit{signaturesTestCommit("1111111", "alice", "alice@example.com")}
+
+	pe := github.PullRequestEvent{
+		Action: github.PullRequestActionSynchronize,
+		Number: number,
+		Repo:   github.Repo{Owner: github.User{Login: testOwner}, Name: testRepo},
+	}
+	assert.NoError(t, p.plugin.handlePullRequestEvent(ctx, log, &pe))
+	assert.Equal(t, []string{testLabelString(testOwner, testRepo, number, labelClaYes)}, p.fakeClient.IssueLabelsAdded)
+	assert.False(t, p.http.serverReached)
+}
+
+func TestCheckRunState(t *testing.T) {
+	tests := []struct {
+		status     string
+		conclusion string
+		state      string
+	}{
+		{status: "queued", state: github.StatusPending},
+		{status: "in_progress", state: github.StatusPending},
+		{status: "completed", conclusion: "success", state: github.StatusSuccess},
+		{status: "completed", conclusion: "neutral", state: github.StatusSuccess},
+		{status: "completed", conclusion: "failure", state: github.StatusFailure},
+		{status: "completed", conclusion: "action_required", state: github.StatusFailure},
+		{status: "completed", conclusion: "stale", state: github.StatusPending},
+		{status: "completed", conclusion: "timed_out", state: github.StatusError},
+		{status: "completed", conclusion: "cancelled", state: github.StatusError},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.state, checkRunState(github.CheckRun{Status: test.status, Conclusion: test.conclusion}), "%s/%s", test.status, test.conclusion)
+	}
+}
+
+// noCheckRunsClient returns no check run list, like GitHub for refs without check suites.
+type noCheckRunsClient struct {
+	githubClient
+}
+
+func (noCheckRunsClient) ListCheckRuns(string, string, string) (*github.CheckRunList, error) {
+	return nil, nil
+}
+
+func TestStatusProviderWithoutCheckRuns(t *testing.T) {
+	ctx := context.Background()
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	provider := &statusProvider{displayName: "EasyCLA", checkRun: "EasyCLA", ghc: noCheckRunsClient{p.fakeClient}}
+	pr := pullRequest{Number: 1, HeadRefOID: "abcdef"}
+
+	state, err := provider.state(ctx, testOwner, testRepo, pr)
+	assert.NoError(t, err)
+	assert.Empty(t, state)
+	hint, err := provider.checkAuthors(ctx, testOwner, testRepo, pr, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, hint)
+}
+
+func TestHandleCheckRunEvent(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestHandleCheckRunEvent", pluginName)
+
+	type testCase struct {
+		name               string
+		checkRun           github.CheckRun
+		expectedLabelAdded string
+	}
+
+	tests := []testCase{
+		{
+			name:               "cla check run - success",
+			checkRun:           github.CheckRun{Name: "EasyCLA", Status: "completed", Conclusion: "success", HeadSHA: shaWithPR},
+			expectedLabelAdded: labelClaYes,
+		},
+		{
+			name:               "cla check run - action required",
+			checkRun:           github.CheckRun{Name: "EasyCLA", Status: "completed", Conclusion: "action_required", HeadSHA: shaWithPR},
+			expectedLabelAdded: labelClaNo,
+		},
+		{
+			name:     "cla check run - in progress",
+			checkRun: github.CheckRun{Name: "EasyCLA", Status: "in_progress", HeadSHA: shaWithPR},
+		},
+		{
+			name:     "different check run",
+			checkRun: github.CheckRun{Name: "build", Status: "completed", Conclusion: "success", HeadSHA: shaWithPR},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				p := newClaAssistantTestPlugin()
+				defer p.http.server.Close()
+				p.plugin.config = &configAgent{config: &pluginConfig{
+					Default: overrides{Provider: &providerConfig{Type: providerStatus, CheckRun: "EasyCLA"}},
+				}}
+				ingestDataIntoFakeClient(p.fakeClient)
+
+				cre := github.CheckRunEvent{
+					Action:   "completed",
+					CheckRun: test.checkRun,
+					Repo:     github.Repo{Owner: github.User{Login: testOwner}, Name: testRepo},
+				}
+				assert.NoError(t, p.plugin.handleCheckRunEvent(ctx, log, &cre))
+				if test.expectedLabelAdded != "" {
+					number := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPR)]
+					assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, number, test.expectedLabelAdded))
+				} else {
+					assert.Nil(t, p.fakeClient.IssueLabelsAdded)
+				}
+			})
+	}
+}
+
+func TestHandleCheckSuiteEvent(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestHandleCheckSuiteEvent", pluginName)
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = &configAgent{config: &pluginConfig{
+		Default: overrides{Provider: &providerConfig{Type: providerStatus, CheckRun: "EasyCLA"}},
+	}}
+	ingestDataIntoFakeClient(p.fakeClient)
+	p.fakeClient.CheckRuns = &github.CheckRunList{CheckRuns: []github.CheckRun{
+		{Name: "build", Status: "completed", Conclusion: "success", HeadSHA: shaWithPRAndYesLabel},
+		{Name: "EasyCLA", Status: "completed", Conclusion: "failure", HeadSHA: shaWithPRAndYesLabel},
+	}}
+
+	cse := github.CheckSuiteEvent{
+		Action:     "completed",
+		CheckSuite: github.CheckSuite{HeadSHA: shaWithPRAndYesLabel},
+		Repo:       github.Repo{Owner: github.User{Login: testOwner}, Name: testRepo},
+	}
+	assert.NoError(t, p.plugin.handleCheckSuiteEvent(ctx, log, &cse))
+	number := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPRAndYesLabel)]
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, number, labelClaNo))
+	assert.Contains(t, p.fakeClient.IssueLabelsRemoved, testLabelString(testOwner, testRepo, number, labelClaYes))
+
+	// The plugin reads check runs only if the provider reports via check runs.
+	state, err := p.plugin.provider(testOwner, testRepo).state(ctx, testOwner, testRepo, pullRequest{HeadRefOID: githubql.String(shaWithPRAndYesLabel)})
+	assert.NoError(t, err)
+	assert.Equal(t, github.StatusFailure, state)
+	p.plugin.config = nil
+	state, err = p.plugin.provider(testOwner, testRepo).state(ctx, testOwner, testRepo, pullRequest{HeadRefOID: githubql.String(shaWithPRAndYesLabel)})
+	assert.NoError(t, err)
+	assert.Equal(t, github.StatusSuccess, state)
+}
+
+// newSignatureStoreTestServer starts a local stand-in of a CLA signature store, in which the given
+// users signed the CLA.
+func newSignatureStoreTestServer(signers ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, ok := strings.CutPrefix(r.URL.Path, "/signatures/")
+		if !ok || !slices.Contains(signers, login) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "%s signed the CLA", login)
+	}))
+}
+
+func testCommit(login, email string) github.RepositoryCommit {
+	return github.RepositoryCommit{
+		Author: github.User{Login: login},
+		Commit: github.GitCommit{Author: github.CommitAuthor{Email: email}},
+	}
+}
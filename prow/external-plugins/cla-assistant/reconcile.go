@@ -0,0 +1,361 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// minRateLimitRemaining is the number of GraphQL rate limit points which the periodic reconciliation leaves
+// for event handling. Once fewer points remain, the reconciliation is continued in the next period.
+const minRateLimitRemaining = 500
+
+// rateLimitQuery is a GraphQL query which also queries the remaining rate limit points.
+type rateLimitQuery interface {
+	rateLimitRemaining() int
+}
+
+func (q *searchQuery[PR]) rateLimitRemaining() int     { return int(q.RateLimit.Remaining) }
+func (q *commitsQuery) rateLimitRemaining() int        { return int(q.RateLimit.Remaining) }
+func (q *commitContextsQuery) rateLimitRemaining() int { return int(q.RateLimit.Remaining) }
+
+// rateLimitClient remembers the remaining GraphQL rate limit points of the last query, so that the periodic
+// reconciliation also accounts for the queries of event handling and of checking signatures.
+type rateLimitClient struct {
+	githubClient
+	remaining atomic.Int64
+}
+
+func newRateLimitClient(ghc githubClient) *rateLimitClient {
+	return &rateLimitClient{githubClient: ghc}
+}
+
+func (c *rateLimitClient) QueryWithGitHubAppsSupport(ctx context.Context, q any, vars map[string]any, org string) error {
+	if err := c.githubClient.QueryWithGitHubAppsSupport(ctx, q, vars, org); err != nil {
+		return err
+	}
+	if rq, ok := q.(rateLimitQuery); ok {
+		c.remaining.Store(int64(rq.rateLimitRemaining()))
+	}
+	return nil
+}
+
+// rateLimitRemaining returns the remaining GraphQL rate limit points reported by the last query.
+func (c *rateLimitClient) rateLimitRemaining() int {
+	return int(c.remaining.Load())
+}
+
+// reconciler remembers the PR heads which were reconciled already, so that the periodic reconciliation
+// only rechecks PRs whose head changed, and the repository at which the last reconciliation stopped. It
+// also remembers the PR heads whose missing signatures were explained, so that the explanation is not
+// refreshed on every failed check.
+type reconciler struct {
+	lock sync.Mutex
+	// heads maps "org/repo" to the PR numbers and their last reconciled head SHAs.
+	heads map[string]map[int]string
+	// explained maps "org/repo" to the PR numbers and the head SHAs whose missing signatures were explained last.
+	explained map[string]map[int]string
+	// next is the "org/repo" at which the next reconciliation starts, so that repositories after the one at
+	// which a reconciliation stopped are not starved. It is empty if all repositories were reconciled.
+	next string
+}
+
+func newReconciler() *reconciler {
+	return &reconciler{heads: map[string]map[int]string{}, explained: map[string]map[int]string{}}
+}
+
+// reconciledHeads returns a copy of the last reconciled heads of the PRs of the repository.
+func (r *reconciler) reconciledHeads(org, repo string) map[int]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	heads := map[int]string{}
+	for number, sha := range r.heads[org+"/"+repo] {
+		heads[number] = sha
+	}
+	return heads
+}
+
+// setReconciledHeads replaces the reconciled heads of the repository, which drops the PRs which were closed.
+// The explained heads of the closed PRs are dropped as well.
+func (r *reconciler) setReconciledHeads(org, repo string, heads map[int]string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.heads[org+"/"+repo] = heads
+	for number := range r.explained[org+"/"+repo] {
+		if _, ok := heads[number]; !ok {
+			delete(r.explained[org+"/"+repo], number)
+		}
+	}
+}
+
+// rotate returns the repositories starting with the one at which the last reconciliation stopped.
+func (r *reconciler) rotate(repos []string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	i := slices.Index(repos, r.next)
+	if i <= 0 {
+		return repos
+	}
+	return append(slices.Clone(repos[i:]), repos[:i]...)
+}
+
+// setNext remembers the repository at which the next reconciliation starts.
+func (r *reconciler) setNext(orgRepo string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.next = orgRepo
+}
+
+// explainedHead returns the head SHA of the PR whose missing signatures were explained last.
+func (r *reconciler) explainedHead(org, repo string, number int) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.explained[org+"/"+repo][number]
+}
+
+// setExplainedHead remembers the head SHA of the PR whose missing signatures were explained.
+func (r *reconciler) setExplainedHead(org, repo string, number int, sha string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.explained[org+"/"+repo] == nil {
+		r.explained[org+"/"+repo] = map[int]string{}
+	}
+	r.explained[org+"/"+repo][number] = sha
+}
+
+// rateLimitError is returned when the reconciliation is stopped to preserve the GraphQL rate limit.
+type rateLimitError struct {
+	remaining int
+}
+
+func (e rateLimitError) Error() string {
+	return fmt.Sprintf("only %d GraphQL rate limit points remaining, continuing in the next period", e.remaining)
+}
+
+// reconcileRepo ensures the CLA labels of the open PRs of the repository. The first page of the commit
+// statuses and check runs of the PR heads is part of the search results, so that there is usually a single
+// GraphQL query per page of PRs.
+// Providers are only asked to recheck a PR, and signatures are only checked, if the head of the PR changed
+// since the last reconciliation. As checking and explaining signatures need further GraphQL queries, the
+// rate limit is checked before every PR which is not up to date.
+func (c *claAssistantPlugin) reconcileRepo(ctx context.Context, l *logrus.Entry, org, repo string) error {
+	provider := c.provider(org, repo)
+	labelYes, labelNo := c.labels(org, repo)
+	previous := c.reconciler.reconciledHeads(org, repo)
+	heads := map[int]string{}
+
+	var found int
+	err := searchPages(ctx, c, l, fmt.Sprintf("repo:%s/%s type:pr state:open", org, repo), org, func(pullRequests []reconcilePullRequest) error {
+		found += len(pullRequests)
+		for _, pullRequest := range pullRequests {
+			number := int(pullRequest.Number)
+			sha := string(pullRequest.HeadRefOID)
+			headChanged := previous[number] != sha
+			pl := l.WithField("pr", number)
+
+			// Signatures are only checked, and providers are only asked to recheck, if the head changed. Labels
+			// which do not match the state reported by a provider are updated in any case.
+			var claState string
+			upToDate := !headChanged
+			if !checksSignatures(provider) {
+				var err error
+				claState, err = c.rollupState(ctx, org, repo, &pullRequest, provider)
+				if errors.As(err, &rateLimitError{}) {
+					return err
+				} else if err != nil {
+					pl.WithError(err).Errorf("Error querying the cla status of PR #%v", number)
+					continue
+				}
+				if claState != "" && claState != github.StatusPending {
+					upToDate = upToDate && labelsUpToDate(claState, pullRequest.hasLabel(labelYes), pullRequest.hasLabel(labelNo))
+				}
+			}
+			if upToDate {
+				heads[number] = sha
+				continue
+			}
+
+			if remaining := c.rateLimit.rateLimitRemaining(); remaining < minRateLimitRemaining {
+				return rateLimitError{remaining: remaining}
+			}
+
+			if checksSignatures(provider) {
+				var err error
+				claState, err = provider.state(ctx, org, repo, pullRequest.pullRequest)
+				if err != nil {
+					pl.WithError(err).Errorf("Error checking CLA signatures of PR #%v", number)
+					continue
+				}
+			} else if claState == "" || claState == github.StatusPending {
+				pl.Infof("No cla status for PR #%v found. Calling %s to initialize recheck.", number, provider.name())
+				if err := c.enforceClaRecheck(ctx, org, repo, number, false); err != nil {
+					pl.WithError(err).Errorf("Error reaching out to %s for PR #%v", provider.name(), number)
+					continue
+				}
+			}
+
+			if err := c.ensureClaLabels(ctx, l, org, repo, claState, pullRequest.pullRequest); err != nil {
+				pl.WithError(err).Errorf("Error ensuring cla labels for PR #%v", number)
+				continue
+			}
+			heads[number] = sha
+		}
+
+		if remaining := c.rateLimit.rateLimitRemaining(); remaining < minRateLimitRemaining {
+			return rateLimitError{remaining: remaining}
+		}
+		return nil
+	})
+	if err != nil {
+		// Keep the heads of the PRs which were not reached.
+		for number, sha := range previous {
+			if _, ok := heads[number]; !ok {
+				heads[number] = sha
+			}
+		}
+	}
+	c.reconciler.setReconciledHeads(org, repo, heads)
+
+	l.Infof("Found %d PRs.", found)
+	return err
+}
+
+// labelsUpToDate returns true if the CLA labels of a PR match the CLA state.
+func labelsUpToDate(claState string, hasClaYes, hasClaNo bool) bool {
+	switch claState {
+	case github.StatusSuccess:
+		return hasClaYes && !hasClaNo
+	case github.StatusFailure, github.StatusError:
+		return hasClaNo && !hasClaYes
+	}
+	return false
+}
+
+// reconcilePullRequest is a PR in the search results of the reconciliation together with the first page of
+// the commit statuses and check runs of its head. Searches for events do not query them.
+type reconcilePullRequest struct {
+	pullRequest
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *statusCheckRollup
+			}
+		}
+	} `graphql:"commits(last: 1)"`
+}
+
+// rollupState returns the CLA state of the head of the PR from its commit statuses and check runs. Only the
+// first page of them is part of the search results, further pages are queried if the provider did not report
+// a state on the first page. It is empty if the provider did not report a state yet.
+func (c *claAssistantPlugin) rollupState(ctx context.Context, org, repo string, p *reconcilePullRequest, provider claProvider) (string, error) {
+	if len(p.Commits.Nodes) == 0 || p.Commits.Nodes[0].Commit.StatusCheckRollup == nil {
+		return "", nil
+	}
+	contexts := p.Commits.Nodes[0].Commit.StatusCheckRollup.Contexts
+	for {
+		if state := contexts.claState(provider); state != "" || !bool(contexts.PageInfo.HasNextPage) {
+			return state, nil
+		}
+		if remaining := c.rateLimit.rateLimitRemaining(); remaining < minRateLimitRemaining {
+			return "", rateLimitError{remaining: remaining}
+		}
+		q := commitContextsQuery{}
+		vars := map[string]any{
+			"owner":          githubql.String(org),
+			"name":           githubql.String(repo),
+			"sha":            githubql.GitObjectID(p.HeadRefOID),
+			"contextsCursor": contexts.PageInfo.EndCursor,
+		}
+		if err := c.ghc.QueryWithGitHubAppsSupport(ctx, &q, vars, org); err != nil {
+			return "", fmt.Errorf("failed to query the statuses of %s: %w", p.HeadRefOID, err)
+		}
+		if q.Repository.Object.Commit.StatusCheckRollup == nil {
+			return "", nil
+		}
+		contexts = q.Repository.Object.Commit.StatusCheckRollup.Contexts
+	}
+}
+
+// claState returns the CLA state reported by the provider in the commit statuses and check runs. It is empty
+// if they do not contain the status context or check run of the provider.
+func (c *statusCheckContexts) claState(provider claProvider) string {
+	if statusContext := provider.statusContext(); statusContext != "" {
+		for _, n := range c.Nodes {
+			if string(n.StatusContext.Context) == statusContext {
+				if n.StatusContext.State == githubql.StatusStateExpected {
+					return github.StatusPending
+				}
+				return strings.ToLower(string(n.StatusContext.State))
+			}
+		}
+	}
+	if checkRunName := provider.checkRunName(); checkRunName != "" {
+		for _, n := range c.Nodes {
+			if string(n.CheckRun.Name) == checkRunName {
+				return checkRunState(github.CheckRun{
+					Status:     strings.ToLower(string(n.CheckRun.Status)),
+					Conclusion: strings.ToLower(string(n.CheckRun.Conclusion)),
+				})
+			}
+		}
+	}
+	return ""
+}
+
+// statusCheckRollup are the first page of the commit statuses and check runs of a commit.
+// See: https://docs.github.com/en/graphql/reference/objects#statuscheckrollup.
+type statusCheckRollup struct {
+	Contexts statusCheckContexts `graphql:"contexts(first: 100)"`
+}
+
+// statusCheckContexts is a page of the commit statuses and check runs of a commit.
+type statusCheckContexts struct {
+	PageInfo struct {
+		HasNextPage githubql.Boolean
+		EndCursor   githubql.String
+	}
+	Nodes []statusCheckContext
+}
+
+// commitContextsQuery queries the further pages of the commit statuses and check runs of a commit.
+type commitContextsQuery struct {
+	RateLimit struct {
+		Cost      githubql.Int
+		Remaining githubql.Int
+	}
+	Repository struct {
+		Object struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					Contexts statusCheckContexts `graphql:"contexts(first: 100, after: $contextsCursor)"`
+				}
+			} `graphql:"... on Commit"`
+		} `graphql:"object(oid: $sha)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
+// statusCheckContext is a commit status or a check run of a commit.
+// See: https://docs.github.com/en/graphql/reference/unions#statuscheckrollupcontext.
+type statusCheckContext struct {
+	StatusContext struct {
+		Context githubql.String
+		State   githubql.StatusState
+	} `graphql:"... on StatusContext"`
+	CheckRun struct {
+		Name       githubql.String
+		Status     githubql.CheckStatusState
+		Conclusion githubql.CheckConclusionState
+	} `graphql:"... on CheckRun"`
+}
@@ -0,0 +1,198 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/prow/pkg/github"
+	"sigs.k8s.io/prow/pkg/plugins"
+)
+
+func TestRollupState(t *testing.T) {
+	contextProvider := &statusProvider{context: claGithubContext}
+	checkRunProvider := &statusProvider{checkRun: "EasyCLA"}
+
+	status := func(context string, state githubql.StatusState) statusCheckContext {
+		var c statusCheckContext
+		c.StatusContext.Context = githubql.String(context)
+		c.StatusContext.State = state
+		return c
+	}
+	checkRun := func(name string, status githubql.CheckStatusState, conclusion githubql.CheckConclusionState) statusCheckContext {
+		var c statusCheckContext
+		c.CheckRun.Name = githubql.String(name)
+		c.CheckRun.Status = status
+		c.CheckRun.Conclusion = conclusion
+		return c
+	}
+
+	type testCase struct {
+		name     string
+		provider claProvider
+		contexts []statusCheckContext
+		state    string
+	}
+
+	tests := []testCase{
+		{name: "no rollup", provider: contextProvider},
+		{name: "status success", provider: contextProvider, contexts: []statusCheckContext{status("tide", githubql.StatusStatePending), status(claGithubContext, githubql.StatusStateSuccess)}, state: github.StatusSuccess},
+		{name: "status expected", provider: contextProvider, contexts: []statusCheckContext{status(claGithubContext, githubql.StatusStateExpected)}, state: github.StatusPending},
+		{name: "other status", provider: contextProvider, contexts: []statusCheckContext{status("tide", githubql.StatusStateFailure)}},
+		{name: "check run failure", provider: checkRunProvider, contexts: []statusCheckContext{checkRun("EasyCLA", githubql.CheckStatusStateCompleted, githubql.CheckConclusionStateActionRequired)}, state: github.StatusFailure},
+		{name: "check run in progress", provider: checkRunProvider, contexts: []statusCheckContext{checkRun("EasyCLA", githubql.CheckStatusStateInProgress, "")}, state: github.StatusPending},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name,
+			func(t *testing.T) {
+				contexts := statusCheckContexts{Nodes: test.contexts}
+				assert.Equal(t, test.state, contexts.claState(test.provider))
+			})
+	}
+}
+
+func TestReconcilerDropsClosedPRs(t *testing.T) {
+	r := newReconciler()
+	r.setReconciledHeads(testOwner, testRepo, map[int]string{1: "aaaa", 2: "bbbb"})
+	r.setExplainedHead(testOwner, testRepo, 1, "aaaa")
+	r.setExplainedHead(testOwner, testRepo, 2, "bbbb")
+
+	// PR #2 was closed.
+	r.setReconciledHeads(testOwner, testRepo, map[int]string{1: "aaaa"})
+	assert.Equal(t, map[int]string{1: "aaaa"}, r.reconciledHeads(testOwner, testRepo))
+	assert.Equal(t, "aaaa", r.explainedHead(testOwner, testRepo, 1))
+	assert.Equal(t, map[int]string{1: "aaaa"}, r.explained[testOwner+"/"+testRepo])
+}
+
+func TestReconcileOnlyChangedHeads(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestReconcileOnlyChangedHeads", pluginName)
+	config := &plugins.Configuration{
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			fmt.Sprintf("%s/%s", testOwner, testRepo): {{Name: pluginName}},
+		},
+	}
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	ingestDataIntoFakeClient(p.fakeClient)
+	pending := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPRClaStatusPending)]
+	signed := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPR)]
+
+	// The first reconciliation rechecks the pending PR and labels the PRs.
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Equal(t, []string{fmt.Sprintf("/check/%s/%s?pullRequest=%d", testOwner, testRepo, pending)}, p.http.urisReached)
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, signed, labelClaYes))
+
+	// The labels are up to date now and the heads did not change, so nothing is done.
+	for _, pr := range p.fakeClient.PullRequests {
+		if pr.Number == signed {
+			pr.Labels = append(pr.Labels, github.Label{Name: labelClaYes})
+		}
+		if pr.Number == pending {
+			pr.Labels = append(pr.Labels, github.Label{Name: labelClaNo})
+		}
+	}
+	p.http.urisReached = nil
+	p.fakeClient.IssueLabelsAdded = nil
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Nil(t, p.http.urisReached)
+	assert.Nil(t, p.fakeClient.IssueLabelsAdded)
+
+	// A new head of the pending PR triggers a recheck.
+	p.fakeClient.PullRequests[pending].Head.SHA = "pppp5678"
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Equal(t, []string{fmt.Sprintf("/check/%s/%s?pullRequest=%d", testOwner, testRepo, pending)}, p.http.urisReached)
+
+	// Once the provider reports the state of the head, the labels are updated without another recheck.
+	p.http.urisReached = nil
+	assert.NoError(t, p.fakeClient.CreateStatus(testOwner, testRepo, "pppp5678", github.Status{Context: claGithubContext, State: github.StatusSuccess}))
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Nil(t, p.http.urisReached)
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, pending, labelClaYes))
+	assert.Contains(t, p.fakeClient.IssueLabelsRemoved, testLabelString(testOwner, testRepo, pending, labelClaNo))
+}
+
+func TestReconcilePagedContexts(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestReconcilePagedContexts", pluginName)
+	config := &plugins.Configuration{
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			fmt.Sprintf("%s/%s", testOwner, testRepo): {{Name: pluginName}},
+		},
+	}
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	ingestDataIntoFakeClient(p.fakeClient)
+	pending := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPRClaStatusPending)]
+	signed := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPR)]
+
+	// The status of the CLA is only on the third page of the statuses of the signed PR.
+	p.fakeClient.contextsPageSize = 1
+	p.fakeClient.CreatedStatuses[shaWithPR] = append([]github.Status{{Context: "tide"}, {Context: "build"}}, p.fakeClient.CreatedStatuses[shaWithPR]...)
+
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Equal(t, []string{fmt.Sprintf("/check/%s/%s?pullRequest=%d", testOwner, testRepo, pending)}, p.http.urisReached)
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, signed, labelClaYes))
+}
+
+func TestReconcileRateLimit(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestReconcileRateLimit", pluginName)
+	config := &plugins.Configuration{
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			testOwner + "/first":  {{Name: pluginName}},
+			testOwner + "/second": {{Name: pluginName}},
+		},
+	}
+	allowlist := filepath.Join(t.TempDir(), "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\n"), 0600))
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = testConfigAgent(t, &pluginConfig{
+		Default: overrides{Provider: &providerConfig{Type: providerAllowlist, Path: allowlist}},
+	})
+	numbers := map[string]int{}
+	for _, repo := range []string{"first", "second"} {
+		number, err := p.fakeClient.CreatePullRequest(testOwner, repo, "Title", "Body", "HEAD", "BASE", true)
+		assert.NoError(t, err)
+		p.fakeClient.PullRequests[number].Head.SHA = "1111111"
+		p.fakeClient.CommitMap[createCommitMapKey(testOwner, repo, number)] = []github.RepositoryCommit{
+			signaturesTestCommit("1111111", "alice", "alice@example.com"),
+		}
+		numbers[repo] = number
+	}
+
+	// Without enough rate limit points, no signatures are checked.
+	p.fakeClient.rateLimitRemaining = minRateLimitRemaining
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Empty(t, p.fakeClient.IssueLabelsAdded)
+
+	// Every query costs a point, so the search and the signatures of a single PR can be checked per period.
+	p.fakeClient.rateLimitRemaining = minRateLimitRemaining + 1
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Equal(t, []string{testLabelString(testOwner, "first", numbers["first"], labelClaYes)}, p.fakeClient.IssueLabelsAdded)
+
+	// The next period starts with the repository at which the reconciliation stopped, which is up to date now.
+	p.fakeClient.rateLimitRemaining = minRateLimitRemaining + 1
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Len(t, p.fakeClient.IssueLabelsAdded, 1)
+
+	// The reconciliation stopped at the second repository, so it is not starved by the first one.
+	p.fakeClient.rateLimitRemaining = minRateLimitRemaining + 1
+	assert.NoError(t, p.plugin.handleAllPRs(ctx, log, config))
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, "second", numbers["second"], labelClaYes))
+}
@@ -99,6 +99,54 @@ func (s *httpServer) handleEvent(eventType, eventGUID string, payload []byte) er
 				l.WithError(err).Info("Error handling event.")
 			}
 		}()
+	case "pull_request":
+		var pe github.PullRequestEvent
+		if err := json.Unmarshal(payload, &pe); err != nil {
+			return err
+		}
+		l = l.WithFields(
+			logrus.Fields{
+				"org":  pe.Repo.Owner.Login,
+				"repo": pe.Repo.Name,
+			},
+		)
+		go func() {
+			if err := s.cla.handlePullRequestEvent(ctx, l, &pe); err != nil {
+				l.WithError(err).Info("Error handling event.")
+			}
+		}()
+	case "check_run":
+		var cre github.CheckRunEvent
+		if err := json.Unmarshal(payload, &cre); err != nil {
+			return err
+		}
+		l = l.WithFields(
+			logrus.Fields{
+				"org":  cre.Repo.Owner.Login,
+				"repo": cre.Repo.Name,
+			},
+		)
+		go func() {
+			if err := s.cla.handleCheckRunEvent(ctx, l, &cre); err != nil {
+				l.WithError(err).Info("Error handling event.")
+			}
+		}()
+	case "check_suite":
+		var cse github.CheckSuiteEvent
+		if err := json.Unmarshal(payload, &cse); err != nil {
+			return err
+		}
+		l = l.WithFields(
+			logrus.Fields{
+				"org":  cse.Repo.Owner.Login,
+				"repo": cse.Repo.Name,
+			},
+		)
+		go func() {
+			if err := s.cla.handleCheckSuiteEvent(ctx, l, &cse); err != nil {
+				l.WithError(err).Info("Error handling event.")
+			}
+		}()
 	case "status":
 		var se github.StatusEvent
 		if err := json.Unmarshal(payload, &se); err != nil {
@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+// signaturesCommentMarker identifies the comment which explains the missing signatures of a PR.
+const signaturesCommentMarker = "<!-- cla-assistant: missing signatures -->"
+
+// webFlowEmail is the committer email of commits created on github.com, which is not linked to an account.
+const webFlowEmail = "noreply@github.com"
+
+// Roles of the people involved in a commit.
+const (
+	roleAuthor    string = "author"
+	roleCoAuthor  string = "co-author"
+	roleCommitter string = "committer"
+)
+
+// commitAuthor is an author, co-author or committer of the commits of a PR.
+type commitAuthor struct {
+	name  string
+	email string
+	// login is the GitHub login of the author. It is empty if the email address is not linked to a
+	// GitHub account.
+	login string
+	roles []string
+	// commits are the abbreviated SHAs of the commits of the author.
+	commits []string
+	// unsigned is true if the provider knows that the author did not sign the CLA.
+	unsigned bool
+}
+
+func (a *commitAuthor) String() string {
+	if a.login != "" {
+		return "@" + a.login
+	}
+	if a.name != "" {
+		return fmt.Sprintf("%s <%s>", a.name, a.email)
+	}
+	return "<" + a.email + ">"
+}
+
+// commitAuthors returns the authors, co-authors and committers of the commits of the PR. Every person is
+// listed once, identified by the GitHub login or, if there is no linked account, by the email address.
+func commitAuthors(ctx context.Context, ghc githubClient, org, repo string, number int) ([]*commitAuthor, error) {
+	vars := map[string]any{
+		"owner":         githubql.String(org),
+		"name":          githubql.String(repo),
+		"number":        githubql.Int(number),
+		"commitsCursor": (*githubql.String)(nil),
+	}
+
+	var authors []*commitAuthor
+	add := func(actor gitActor, role, sha string) {
+		login := ""
+		if actor.User != nil {
+			login = string(actor.User.Login)
+		}
+		i := slices.IndexFunc(authors, func(a *commitAuthor) bool {
+			if login != "" {
+				return a.login == login
+			}
+			return a.login == "" && strings.EqualFold(a.email, string(actor.Email))
+		})
+		if i < 0 {
+			authors = append(authors, &commitAuthor{name: string(actor.Name), email: string(actor.Email), login: login})
+			i = len(authors) - 1
+		}
+		if !slices.Contains(authors[i].roles, role) {
+			authors[i].roles = append(authors[i].roles, role)
+		}
+		if !slices.Contains(authors[i].commits, sha) {
+			authors[i].commits = append(authors[i].commits, sha)
+		}
+	}
+
+	for {
+		cq := commitsQuery{}
+		if err := ghc.QueryWithGitHubAppsSupport(ctx, &cq, vars, org); err != nil {
+			return nil, err
+		}
+		commits := cq.Repository.PullRequest.Commits
+		for _, n := range commits.Nodes {
+			sha := string(n.Commit.AbbreviatedOID)
+			// GitHub does not page the authors of a commit within the commits of a PR, so a commit with more
+			// authors cannot be checked completely.
+			if n.Commit.Authors.PageInfo.HasNextPage {
+				return nil, fmt.Errorf("commit %s has more than %d authors", sha, maxCommitAuthors)
+			}
+			// The first author is the author of the commit, the others are co-authors from Co-authored-by trailers.
+			for i, author := range n.Commit.Authors.Nodes {
+				role := roleCoAuthor
+				if i == 0 {
+					role = roleAuthor
+				}
+				add(author, role, sha)
+			}
+			if !strings.EqualFold(string(n.Commit.Committer.Email), webFlowEmail) {
+				add(n.Commit.Committer, roleCommitter, sha)
+			}
+		}
+		if !commits.PageInfo.HasNextPage {
+			break
+		}
+		vars["commitsCursor"] = new(commits.PageInfo.EndCursor)
+	}
+	return authors, nil
+}
+
+// ensureSignaturesComment maintains a single comment on the PR which explains the missing signatures. If
+// all authors signed the CLA, an existing comment is updated accordingly.
+func (c *claAssistantPlugin) ensureSignaturesComment(ctx context.Context, l *logrus.Entry, org, repo, claState string, pullRequest pullRequest) error {
+	number := int(pullRequest.Number)
+	comments, err := c.ghc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return err
+	}
+	existing := slices.IndexFunc(comments, func(ic github.IssueComment) bool {
+		return strings.Contains(ic.Body, signaturesCommentMarker)
+	})
+
+	var body string
+	if claState == github.StatusSuccess {
+		if existing < 0 {
+			return nil
+		}
+		body = signaturesCommentMarker + "\nAll commit authors of this PR signed the CLA. Thank you!"
+	} else {
+		provider := c.provider(org, repo)
+		authors, err := commitAuthors(ctx, c.ghc, org, repo, number)
+		if err != nil {
+			return fmt.Errorf("error getting commit authors: %w", err)
+		}
+		hint, err := provider.checkAuthors(ctx, org, repo, pullRequest, authors)
+		if err != nil {
+			return fmt.Errorf("error checking commit authors: %w", err)
+		}
+		body = signaturesComment(provider.name(), authors, hint)
+	}
+
+	if existing < 0 {
+		l.Infof("Explaining missing signatures of PR #%v.", number)
+		return c.ghc.CreateComment(org, repo, number, body)
+	}
+	if comments[existing].Body == body {
+		return nil
+	}
+	l.Infof("Updating explanation of missing signatures of PR #%v.", number)
+	return c.ghc.EditComment(org, repo, comments[existing].ID, body)
+}
+
+// signaturesComment renders the comment which explains the missing signatures of a PR.
+func signaturesComment(providerName string, authors []*commitAuthor, hint string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\nThe CLA check of %s failed for this PR.", signaturesCommentMarker, providerName)
+	if hint != "" {
+		fmt.Fprintf(&b, " %s", hint)
+	}
+	b.WriteString("\n")
+
+	var unlinked, unsigned bool
+	var rows []string
+	for _, a := range authors {
+		var problem string
+		switch {
+		case a.login == "":
+			problem = "email address is not linked to a GitHub account"
+			unlinked = true
+		case a.unsigned:
+			problem = "did not sign the CLA"
+			unsigned = true
+		default:
+			continue
+		}
+		rows = append(rows, fmt.Sprintf("| %s | %s | %s | %s |", strings.ReplaceAll(a.String(), "|", `\|`), strings.Join(a.roles, ", "), strings.Join(a.commits, ", "), problem))
+	}
+	if len(rows) > 0 {
+		b.WriteString("\n| Person | Role | Commits | Problem |\n|---|---|---|---|\n")
+		b.WriteString(strings.Join(rows, "\n"))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nTo fix this:\n")
+	if unlinked {
+		b.WriteString("- Add the email addresses to the GitHub accounts of the persons in the [email settings](https://github.com/settings/emails), " +
+			"or rewrite the commits with an email address of their GitHub account, e.g. with " +
+			"`git rebase --exec 'git commit --amend --no-edit --reset-author' <base>`, and force-push the branch.\n")
+	}
+	if unsigned || !unlinked {
+		fmt.Fprintf(&b, "- Every author, co-author and committer needs to sign the CLA, which is checked by %s.\n", providerName)
+	}
+	b.WriteString("- Comment `/cla` to check the CLA again.\n")
+	return b.String()
+}
+
+// gitActor is the author or committer of a commit.
+type gitActor struct {
+	Name  githubql.String
+	Email githubql.String
+	User  *struct {
+		Login githubql.String
+	}
+}
+
+// maxCommitAuthors is the number of authors which are queried per commit, which is the maximum page size of
+// GitHub.
+const maxCommitAuthors = 100
+
+// prCommit is a commit of a PR.
+// See: https://docs.github.com/en/graphql/reference/objects#commit.
+type prCommit struct {
+	AbbreviatedOID githubql.String `graphql:"abbreviatedOid"`
+	// Authors are the author and the co-authors of the commit.
+	Authors struct {
+		PageInfo struct {
+			HasNextPage githubql.Boolean
+		}
+		Nodes []gitActor
+	} `graphql:"authors(first: 100)"`
+	Committer gitActor
+}
+
+// See: https://docs.github.com/en/graphql/reference/objects#pullrequest.
+type commitsQuery struct {
+	RateLimit struct {
+		Remaining githubql.Int
+	}
+	Repository struct {
+		PullRequest struct {
+			Commits struct {
+				PageInfo struct {
+					HasNextPage githubql.Boolean
+					EndCursor   githubql.String
+				}
+				Nodes []struct {
+					Commit prCommit
+				}
+			} `graphql:"commits(first: 100, after: $commitsCursor)"`
+		} `graphql:"pullRequest(number: $number)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2024 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/prow/pkg/github"
+)
+
+func TestCommitAuthors(t *testing.T) {
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+
+	alice := signaturesTestCommit("1111111", "alice", "alice@example.com")
+	coAuthored := signaturesTestCommit("2222222", "", "bob@example.com")
+	coAuthored.Commit.Message = "Fix typo\n\nCo-authored-by: Carol <carol@example.com>\nCo-authored-by: Bob <BOB@example.com>"
+	coAuthored.Committer = github.User{Login: "alice"}
+	webFlow := signaturesTestCommit("3333333", "alice", "alice@example.com")
+	webFlow.Commit.Committer = github.CommitAuthor{Name: "GitHub", Email: webFlowEmail}
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, 1)] = []github.RepositoryCommit{alice, coAuthored, webFlow}
+
+	authors, err := commitAuthors(context.Background(), p.fakeClient, testOwner, testRepo, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*commitAuthor{
+		{login: "alice", email: "alice@example.com", roles: []string{roleAuthor, roleCommitter}, commits: []string{"1111111", "2222222", "3333333"}},
+		{email: "bob@example.com", roles: []string{roleAuthor, roleCoAuthor}, commits: []string{"2222222"}},
+		{name: "Carol", email: "carol@example.com", roles: []string{roleCoAuthor}, commits: []string{"2222222"}},
+	}, authors)
+
+	// The authors of a commit are not truncated silently.
+	manyCoAuthors := signaturesTestCommit("4444444", "alice", "alice@example.com")
+	manyCoAuthors.Commit.Message = "Fix typo\n"
+	for i := range maxCommitAuthors {
+		manyCoAuthors.Commit.Message += fmt.Sprintf("\nCo-authored-by: Author %d <author-%d@example.com>", i, i)
+	}
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, 2)] = []github.RepositoryCommit{manyCoAuthors}
+	_, err = commitAuthors(context.Background(), p.fakeClient, testOwner, testRepo, 2)
+	assert.ErrorContains(t, err, "commit 4444444 has more than 100 authors")
+}
+
+func TestEnsureSignaturesCommentWithStatusProvider(t *testing.T) {
+	ctx := context.Background()
+	log := logrus.StandardLogger().WithField("TestEnsureSignaturesCommentWithStatusProvider", pluginName)
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	ingestDataIntoFakeClient(p.fakeClient)
+	assert.NoError(t, p.fakeClient.CreateStatus(testOwner, testRepo, shaWithPRAndNoLabel, github.Status{
+		Context:     claGithubContext,
+		State:       github.StatusFailure,
+		Description: "Contributor License Agreement is not signed yet.",
+		TargetURL:   "https://cla-assistant.io/TestOrg/test-repo?pullRequest=1",
+	}))
+	number := prNumberForOrgRepoSha[fmt.Sprintf("%s-%s-%s", testOwner, testRepo, shaWithPRAndNoLabel)]
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, number)] = []github.RepositoryCommit{
+		signaturesTestCommit("1111111", "alice", "alice@example.com"),
+		signaturesTestCommit("2222222", "", "bob@localhost"),
+	}
+	pr := pullRequest{Number: githubql.Int(number), HeadRefOID: githubql.String(shaWithPRAndNoLabel)}
+
+	assert.NoError(t, p.plugin.ensureSignaturesComment(ctx, log, testOwner, testRepo, github.StatusFailure, pr))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+	comment := p.fakeClient.IssueComments[number][0].Body
+	assert.True(t, strings.HasPrefix(comment, signaturesCommentMarker))
+	assert.Contains(t, comment, "cla-assistant.io reports: Contributor License Agreement is not signed yet. See [details](https://cla-assistant.io/TestOrg/test-repo?pullRequest=1) to sign the CLA.")
+	assert.Contains(t, comment, "| <bob@localhost> | author, committer | 2222222 | email address is not linked to a GitHub account |")
+	assert.NotContains(t, comment, "@alice")
+
+	// The comment is not edited as long as the explanation does not change.
+	assert.NoError(t, p.plugin.ensureSignaturesComment(ctx, log, testOwner, testRepo, github.StatusFailure, pr))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+	assert.Empty(t, p.fakeClient.IssueCommentsEdited)
+
+	// Once the CLA is signed, the comment is updated.
+	assert.NoError(t, p.plugin.ensureSignaturesComment(ctx, log, testOwner, testRepo, github.StatusSuccess, pr))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+	assert.Contains(t, p.fakeClient.IssueComments[number][0].Body, "All commit authors of this PR signed the CLA.")
+
+	// Without an explanation, nothing is posted on success.
+	assert.NoError(t, p.plugin.ensureSignaturesComment(ctx, log, testOwner, testRepo, github.StatusSuccess, pullRequest{Number: 4711}))
+	assert.Empty(t, p.fakeClient.IssueComments[4711])
+}
+
+func TestEnsureSignaturesCommentWithAllowlist(t *testing.T) {
+	ctx := context.Background()
+	allowlist := filepath.Join(t.TempDir(), "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\n"), 0600))
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = testConfigAgent(t, &pluginConfig{
+		Default: overrides{Provider: &providerConfig{Type: providerAllowlist, Path: allowlist}},
+	})
+
+	number, err := p.fakeClient.CreatePullRequest(testOwner, testRepo, "Title", "Body", "HEAD", "BASE", true)
+	assert.NoError(t, err)
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, number)] = []github.RepositoryCommit{
+		signaturesTestCommit("1111111", "alice", "alice@example.com"),
+		signaturesTestCommit("2222222", "carol", "carol@example.com"),
+	}
+
+	// The comment is posted together with the label.
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, number, false))
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, number, labelClaNo))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+	comment := p.fakeClient.IssueComments[number][0].Body
+	assert.Contains(t, comment, "| @carol | author, committer | 2222222 | did not sign the CLA |")
+	assert.Contains(t, comment, "needs to sign the CLA, which is checked by the CLA allowlist.")
+	assert.NotContains(t, comment, "@alice")
+
+	// Once carol signed the CLA, the comment is updated in place.
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\ncarol\n"), 0600))
+	p.plugin.config = testConfigAgent(t, p.plugin.config.get())
+	p.fakeClient.PullRequests[number].Labels = []github.Label{{Name: labelClaNo}}
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, number, false))
+	assert.Contains(t, p.fakeClient.IssueLabelsAdded, testLabelString(testOwner, testRepo, number, labelClaYes))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+	assert.Len(t, p.fakeClient.IssueCommentsEdited, 1)
+	assert.Contains(t, p.fakeClient.IssueComments[number][0].Body, "All commit authors of this PR signed the CLA.")
+}
+
+func TestEnsureClaLabelsExplainsOncePerHead(t *testing.T) {
+	ctx := context.Background()
+	allowlist := filepath.Join(t.TempDir(), "signers")
+	assert.NoError(t, os.WriteFile(allowlist, []byte("alice\n"), 0600))
+
+	p := newClaAssistantTestPlugin()
+	defer p.http.server.Close()
+	p.plugin.config = testConfigAgent(t, &pluginConfig{
+		Default: overrides{Provider: &providerConfig{Type: providerAllowlist, Path: allowlist}},
+	})
+
+	number, err := p.fakeClient.CreatePullRequest(testOwner, testRepo, "Title", "Body", "HEAD", "BASE", true)
+	assert.NoError(t, err)
+	p.fakeClient.PullRequests[number].Head.SHA = "1111111"
+	p.fakeClient.CommitMap[createCommitMapKey(testOwner, testRepo, number)] = []github.RepositoryCommit{
+		signaturesTestCommit("1111111", "carol", "carol@example.com"),
+	}
+
+	// The comment is posted together with the label.
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, number, false))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+
+	// As long as the label and the head do not change, the explanation is not refreshed.
+	p.fakeClient.PullRequests[number].Labels = []github.Label{{Name: labelClaNo}}
+	p.fakeClient.IssueComments[number] = nil
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, number, false))
+	assert.Empty(t, p.fakeClient.IssueComments[number])
+
+	// A new head refreshes the explanation.
+	p.fakeClient.PullRequests[number].Head.SHA = "2222222"
+	assert.NoError(t, p.plugin.enforceClaRecheck(ctx, testOwner, testRepo, number, false))
+	assert.Len(t, p.fakeClient.IssueComments[number], 1)
+}
+
+func signaturesTestCommit(sha, login, email string) github.RepositoryCommit {
+	c := testCommit(login, email)
+	c.SHA = sha
+	c.Committer = github.User{Login: login}
+	c.Commit.Committer = github.CommitAuthor{Email: email}
+	return c
+}